@@ -0,0 +1,137 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/config-parser/v2/types"
+)
+
+// handleAcceptTuning sets global "tune.maxaccept" from --tune-maxaccept and the "backlog"
+// of the binds of HTTP, HTTPS, SSL and TCP services frontends from --bind-backlog,
+// so that connection spikes are accepted faster. Both are HAProxy defaults when 0.
+func (c *HAProxyController) handleAcceptTuning() (needsReload bool, err error) {
+	if c.osArgs.TuneMaxAccept < -1 {
+		return false, fmt.Errorf("tune-maxaccept: incorrect value %d, expected -1 (unlimited) or more", c.osArgs.TuneMaxAccept)
+	}
+	if c.osArgs.BindBacklog < 0 {
+		return false, fmt.Errorf("bind-backlog: incorrect value %d", c.osArgs.BindBacklog)
+	}
+	lines := []string{}
+	if c.osArgs.TuneMaxAccept != 0 {
+		lines = append(lines, "tune.maxaccept "+strconv.Itoa(c.osArgs.TuneMaxAccept))
+	}
+	needsReload, err = c.sectionDirectivesSet(parser.Global, parser.GlobalSectionName, "tune.maxaccept", lines)
+	if err != nil {
+		return needsReload, err
+	}
+	backlog := ""
+	if c.osArgs.BindBacklog != 0 {
+		backlog = strconv.Itoa(c.osArgs.BindBacklog)
+	}
+	frontends, err := c.frontendsGet()
+	if err != nil {
+		return needsReload, err
+	}
+	for _, frontend := range frontends {
+		switch {
+		case frontend.Name == FrontendHTTP, frontend.Name == FrontendHTTPS, frontend.Name == FrontendSSL:
+		case strings.HasPrefix(frontend.Name, "tcp-"):
+		default:
+			continue
+		}
+		reload, errSet := c.setBindsBacklog(frontend.Name, backlog)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
+// setBindsBacklog sets or removes "backlog" on binds of a frontend. Like crt-list,
+// backlog is not part of bind model, it is set again after binds are edited.
+func (c *HAProxyController) setBindsBacklog(frontend string, backlog string) (changed bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	data, err := config.Get(parser.Frontends, frontend, "bind")
+	if err != nil {
+		return false, nil
+	}
+	binds := data.([]types.Bind)
+	for i, bind := range binds {
+		current := ""
+		bindParams := make([]params.BindOption, 0, len(bind.Params)+1)
+		for _, param := range bind.Params {
+			if p, ok := param.(*params.BindOptionValue); ok && p.Name == "backlog" {
+				current = p.Value
+				continue
+			}
+			bindParams = append(bindParams, param)
+		}
+		if current == backlog {
+			continue
+		}
+		if backlog != "" {
+			bindParams = append(bindParams, &params.BindOptionValue{Name: "backlog", Value: backlog})
+		}
+		binds[i].Params = bindParams
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	return true, config.Set(parser.Frontends, frontend, "bind", binds)
+}
+
+// handleTCPSmartOptions sets "option tcp-smart-accept" on HTTP, HTTPS and SSL passthrough
+// frontends from --tcp-smart-accept, and "option tcp-smart-connect" in defaults section,
+// used by backends, from --tcp-smart-connect. Both save a TCP packet per connection.
+// TCP services frontends are left out, their protocol may expect the server to speak first.
+func (c *HAProxyController) handleTCPSmartOptions() (needsReload bool, err error) {
+	lines := []string{}
+	if c.osArgs.TCPSmartAccept {
+		lines = append(lines, "option tcp-smart-accept")
+	}
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS, FrontendSSL} {
+		if _, errGet := c.frontendGet(frontend); errGet != nil {
+			continue
+		}
+		reload, errSet := c.sectionDirectivesSet(parser.Frontends, frontend, "option tcp-smart-accept", lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	lines = []string{}
+	if c.osArgs.TCPSmartConnect {
+		lines = append(lines, "option tcp-smart-connect")
+	}
+	reload, errSet := c.sectionDirectivesSet(parser.Defaults, parser.DefaultSectionName, "option tcp-smart-connect", lines)
+	if errSet != nil {
+		return needsReload, errSet
+	}
+	return needsReload || reload, err
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+func TestHandleAcceptTuning(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.TuneMaxAccept = 128
+	c.osArgs.BindBacklog = 4096
+	config := c.testSync(t, func() {
+		err := c.frontendCreate(models.Frontend{Name: "tcp-9000", Mode: "tcp", DefaultBackend: "default_backend"})
+		if err == nil {
+			err = c.frontendBindCreate("tcp-9000", models.Bind{Address: "0.0.0.0:9000", Name: "bind_1"})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reload, err := c.handleAcceptTuning(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if !testSectionHas(config, "global", "tune.maxaccept 128") {
+		t.Errorf("expected tune.maxaccept in global:\n%s", config)
+	}
+	for frontend, bind := range map[string]string{
+		"frontend http":     "bind 0.0.0.0:80 name bind_1 backlog 4096",
+		"frontend https":    "bind 0.0.0.0:443 name bind_1 backlog 4096",
+		"frontend tcp-9000": "bind 0.0.0.0:9000 name bind_1 backlog 4096",
+	} {
+		if !testSectionHas(config, frontend, bind) {
+			t.Errorf("%s: expected '%s':\n%s", frontend, bind, config)
+		}
+	}
+	if strings.Contains(strings.Join(testSection(config, "frontend stats"), "\n"), "backlog") {
+		t.Errorf("expected no backlog in frontend stats:\n%s", config)
+	}
+
+	// unchanged values
+	c.testSync(t, func() {
+		if reload, err := c.handleAcceptTuning(); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+
+	// back to HAProxy defaults
+	c.osArgs.TuneMaxAccept = 0
+	c.osArgs.BindBacklog = 0
+	config = c.testSync(t, func() {
+		if reload, err := c.handleAcceptTuning(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "tune.maxaccept") || strings.Contains(config, "backlog") {
+		t.Errorf("expected no accept tuning:\n%s", config)
+	}
+}
+
+func TestHandleAcceptTuningInvalid(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	for _, args := range [][2]int{{-2, 0}, {0, -1}} {
+		c.osArgs.TuneMaxAccept, c.osArgs.BindBacklog = args[0], args[1]
+		config := c.testSync(t, func() {
+			if _, err := c.handleAcceptTuning(); err == nil {
+				t.Errorf("%v: expected error", args)
+			}
+		})
+		if strings.Contains(config, "tune.maxaccept") || strings.Contains(config, "backlog") {
+			t.Errorf("%v: expected no accept tuning:\n%s", args, config)
+		}
+	}
+	c.osArgs.TuneMaxAccept, c.osArgs.BindBacklog = -1, 0
+	config := c.testSync(t, func() {
+		if _, err := c.handleAcceptTuning(); err != nil {
+			t.Error(err)
+		}
+	})
+	if !testSectionHas(config, "global", "tune.maxaccept -1") {
+		t.Errorf("expected unlimited tune.maxaccept:\n%s", config)
+	}
+}
+
+func TestHandleTCPSmartOptions(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.TCPSmartAccept = true
+	c.osArgs.TCPSmartConnect = true
+	config := c.testSync(t, func() {
+		err := c.frontendCreate(models.Frontend{Name: FrontendSSL, Mode: "tcp", DefaultBackend: "default_backend"})
+		if err == nil {
+			err = c.frontendCreate(models.Frontend{Name: "tcp-9000", Mode: "tcp", DefaultBackend: "default_backend"})
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reload, err := c.handleTCPSmartOptions(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	for _, frontend := range []string{"frontend http", "frontend https", "frontend ssl"} {
+		if !testSectionHas(config, frontend, "option tcp-smart-accept") {
+			t.Errorf("%s: expected option tcp-smart-accept:\n%s", frontend, config)
+		}
+	}
+	for _, frontend := range []string{"frontend stats", "frontend tcp-9000"} {
+		if testSectionHas(config, frontend, "option tcp-smart-accept") {
+			t.Errorf("%s: expected no option tcp-smart-accept:\n%s", frontend, config)
+		}
+	}
+	if !testSectionHas(config, "defaults", "option tcp-smart-connect") {
+		t.Errorf("expected option tcp-smart-connect in defaults:\n%s", config)
+	}
+	c.testSync(t, func() {
+		if reload, err := c.handleTCPSmartOptions(); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+
+	c.osArgs.TCPSmartAccept = false
+	config = c.testSync(t, func() {
+		if reload, err := c.handleTCPSmartOptions(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "tcp-smart-accept") || !testSectionHas(config, "defaults", "option tcp-smart-connect") {
+		t.Errorf("expected option tcp-smart-connect only:\n%s", config)
+	}
+	c.osArgs.TCPSmartConnect = false
+	config = c.testSync(t, func() {
+		if reload, err := c.handleTCPSmartOptions(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "tcp-smart") {
+		t.Errorf("expected no TCP smart options:\n%s", config)
+	}
+}
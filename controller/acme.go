@@ -0,0 +1,57 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+)
+
+const (
+	// acmeChallengePath is the path prefix of ACME HTTP-01 challenges
+	acmeChallengePath = "/.well-known/acme-challenge/"
+	// acmeSolverIngress is the name of the ingress of the use_backend rule to the solver,
+	// ingress names are lower case in kubernetes so it can not be a real one
+	acmeSolverIngress = "ACMESolver"
+)
+
+// handleACMESolver sends ACME HTTP-01 challenges of any host to --acme-solver-service.
+// The use_backend rule is evaluated before all others (see sortedUseBackendKeys)
+// and challenges are not redirected to HTTPS (see handleHTTPRedirect).
+func (c *HAProxyController) handleACMESolver() (needsReload bool, err error) {
+	solver := c.osArgs.ACMESolverService
+	if solver.Name == "" {
+		return false, nil
+	}
+	namespace, ok := c.cfg.Namespace[solver.Namespace]
+	if !ok {
+		return false, fmt.Errorf("acme solver service: invalid namespace '%s'", solver.Namespace)
+	}
+	service, ok := namespace.Services[solver.Name]
+	if !ok || len(service.Ports) == 0 {
+		return false, fmt.Errorf("acme solver service: service '%s/%s' does not exist", solver.Namespace, solver.Name)
+	}
+	ingress := &Ingress{
+		Namespace:   namespace.Name,
+		Name:        acmeSolverIngress,
+		Annotations: MapStringW{},
+		Rules:       map[string]*IngressRule{},
+	}
+	path := &IngressPath{
+		ServiceName:    service.Name,
+		ServicePortInt: service.Ports[0].Port,
+		Path:           acmeChallengePath,
+	}
+	return c.handlePath(namespace, ingress, &IngressRule{}, path)
+}
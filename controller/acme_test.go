@@ -0,0 +1,97 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+func TestHandleACMESolver(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testServiceEndpoints("cert-manager", "solver")
+	c.cfg.GetNamespace("cert-manager").Services["solver"].Status = ADDED
+	c.osArgs.ACMESolverService = utils.NamespaceValue{Namespace: "cert-manager", Name: "solver"}
+	c.addUseBackendRule("Rdefaultwebexample.com/.well-known", UseBackendRule{Host: "example.com", Path: "/.well-known", Backend: "default-app-80", Namespace: "default", Ingress: "web"}, FrontendHTTP, FrontendHTTPS)
+	c.addUseBackendRule("Rdefaultcatchall/", UseBackendRule{Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "catchall"}, FrontendHTTP, FrontendHTTPS)
+	config := c.testSync(t, func() {
+		if reload, err := c.handleACMESolver(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.handleHTTPRedirect(true); err != nil {
+			t.Fatal(err)
+		}
+		c.refreshBackendSwitching()
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !strings.Contains(config, "backend cert-manager-solver-80") {
+		t.Fatalf("expected solver backend:\n%s", config)
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		useBackend := []string{}
+		for _, line := range testSection(config, frontend) {
+			if strings.HasPrefix(line, "use_backend") {
+				useBackend = append(useBackend, line)
+			}
+		}
+		if len(useBackend) != 3 || !strings.HasPrefix(useBackend[0], "use_backend cert-manager-solver-80 ") ||
+			!strings.Contains(useBackend[0], "path_beg "+acmeChallengePath) || strings.Contains(useBackend[0], "req.hdr(host)") {
+			t.Errorf("%s: expected ACME challenges rule of any host first, got:\n%s", frontend, strings.Join(useBackend, "\n"))
+		}
+	}
+	expected := "http-request redirect scheme https code 302 if !{ ssl_fc } !{ path_beg " + acmeChallengePath + " }"
+	if !testSectionHas(config, "frontend http", expected) {
+		t.Errorf("expected '%s':\n%s", expected, config)
+	}
+}
+
+func TestHandleACMESolverMissingService(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.GetNamespace("cert-manager")
+	c.osArgs.ACMESolverService = utils.NamespaceValue{Namespace: "cert-manager", Name: "solver"}
+	c.testSync(t, func() {
+		if reload, err := c.handleACMESolver(); err == nil || reload {
+			t.Errorf("expected error without reload, got %t %v", reload, err)
+		}
+	})
+	c.osArgs.ACMESolverService = utils.NamespaceValue{Namespace: "unknown", Name: "solver"}
+	c.testSync(t, func() {
+		if _, err := c.handleACMESolver(); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+func TestHTTPRedirectWithoutACMESolver(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	config := c.testSync(t, func() {
+		if _, err := c.handleHTTPRedirect(true); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "frontend http", "http-request redirect scheme https code 302 if !{ ssl_fc }") {
+		t.Errorf("expected redirect of all paths:\n%s", config)
+	}
+}
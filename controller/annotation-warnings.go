@@ -0,0 +1,59 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// annotationWarnings keeps the annotation errors of ingresses reported during the current
+// and previous updates, so that a Warning event is recorded once for an error repeated by
+// consecutive updates. Other annotations of the ingress are applied regardless.
+type annotationWarnings struct {
+	current  map[string]struct{}
+	previous map[string]struct{}
+}
+
+// rotateAnnotationWarnings is called at the beginning of each update
+func (c *HAProxyController) rotateAnnotationWarnings() {
+	c.annotationWarnings.previous = c.annotationWarnings.current
+	c.annotationWarnings.current = map[string]struct{}{}
+}
+
+// annotationErr logs the error of an annotation applied for an ingress and records it
+// as an "InvalidAnnotation" Warning event on the ingress.
+func (c *HAProxyController) annotationErr(ingress *Ingress, err error) {
+	if err == nil {
+		return
+	}
+	utils.LogErr(err)
+	// ingresses generated by the controller (default service, ACME solver) have no events
+	if ingress == nil || c.cfg.Namespace[ingress.Namespace] == nil || c.cfg.Namespace[ingress.Namespace].Ingresses[ingress.Name] == nil {
+		return
+	}
+	name := ingress.Namespace + "/" + ingress.Name
+	key := name + " " + err.Error()
+	if c.annotationWarnings.current == nil {
+		c.annotationWarnings.current = map[string]struct{}{}
+	}
+	_, reported := c.annotationWarnings.previous[key]
+	if _, ok := c.annotationWarnings.current[key]; ok {
+		reported = true
+	}
+	c.annotationWarnings.current[key] = struct{}{}
+	if !reported {
+		c.recordIngressWarning(name, "InvalidAnnotation", err.Error())
+	}
+}
@@ -143,6 +143,7 @@ var defaultAnnotationValues = MapStringW{
 	"ssl-passthrough":           &StringW{Value: "false"},
 	"server-ssl":                &StringW{Value: "false"},
 	"servers-increment":         &StringW{Value: "42"},
+	"stick-on-expire":           &StringW{Value: "30m"},
 	"syslog-server":             &StringW{Value: "address:127.0.0.1, facility: local0, level: notice"},
 	"timeout-http-request":      &StringW{Value: "5s"},
 	"timeout-connect":           &StringW{Value: "5s"},
@@ -150,7 +151,9 @@ var defaultAnnotationValues = MapStringW{
 	"timeout-queue":             &StringW{Value: "5s"},
 	"timeout-server":            &StringW{Value: "50s"},
 	"timeout-tunnel":            &StringW{Value: "1h"},
+	"timeout-tunnel-auto":       &StringW{Value: "24h"},
 	"timeout-http-keep-alive":   &StringW{Value: "1m"},
+	"websocket-affinity":        &StringW{Value: "true"},
 	"whitelist":                 &StringW{Value: ""},
 	"whitelist-with-rate-limit": &StringW{Value: "false"},
 }
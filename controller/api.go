@@ -1,6 +1,11 @@
 package controller
 
 import (
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/config-parser/v2/types"
 	"github.com/haproxytech/models"
 )
 
@@ -184,3 +189,147 @@ func (c *HAProxyController) frontendTCPRequestRuleCreate(frontend string, rule m
 	c.ActiveTransactionHasChanges = true
 	return c.NativeAPI.Configuration.CreateTCPRequestRule("frontend", frontend, &rule, c.ActiveTransaction, 0)
 }
+
+// sectionDirectivesSet replaces all lines of a section starting with keyword by the given lines.
+// It is used for directives that are not (yet) supported by client-native models,
+// those are kept by config-parser as unprocessed lines of the section.
+// Lines of the config snippet (see sectionSnippetSet) belong to the snippet annotation and are kept.
+func (c *HAProxyController) sectionDirectivesSet(section parser.Section, sectionName string, keyword string, lines []string) (changed bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	current := []types.UnProcessed{}
+	if data, errGet := config.Get(section, sectionName, ""); errGet == nil {
+		current = data.([]types.UnProcessed)
+	}
+	result := make([]types.UnProcessed, 0, len(current)+len(lines))
+	oldLines := []string{}
+	inSnippet := false
+	for _, line := range current {
+		switch line.Value {
+		case configSnippetBegin:
+			inSnippet = true
+		case configSnippetEnd:
+			inSnippet = false
+		}
+		if !inSnippet && (line.Value == keyword || strings.HasPrefix(line.Value, keyword+" ")) {
+			oldLines = append(oldLines, line.Value)
+			continue
+		}
+		result = append(result, line)
+	}
+	if len(oldLines) == len(lines) {
+		changed = false
+		for i := range lines {
+			if lines[i] != oldLines[i] {
+				changed = true
+				break
+			}
+		}
+		if !changed {
+			return false, nil
+		}
+	}
+	for _, line := range lines {
+		result = append(result, types.UnProcessed{Value: line})
+	}
+	c.ActiveTransactionHasChanges = true
+	return true, config.Set(section, sectionName, "", result)
+}
+
+// backendDefaultServerSet replaces the options of "default-server" line of a backend
+// having one of names by the given options, other options are kept.
+// The line is owned by the controller and rebuilt from backendDefaultServers on every call
+// instead of the parsed configuration: config-parser drops options it does not know
+// (e.g. hash-key) and reads their value as a separate option, so that reading the line back
+// would turn it into an invalid one.
+func (c *HAProxyController) backendDefaultServerSet(backendName string, names []string, options []params.ServerOption) (changed bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	current := c.backendDefaultServers[backendName]
+	replaced := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		replaced[name] = struct{}{}
+	}
+	// options keep their position in the line so that it is stable across updates
+	result := make([]params.ServerOption, 0, len(current)+len(options))
+	inserted := false
+	for _, option := range current {
+		if _, ok := replaced[strings.Fields(option.String())[0]]; !ok {
+			result = append(result, option)
+		} else if !inserted {
+			result = append(result, options...)
+			inserted = true
+		}
+	}
+	if !inserted {
+		result = append(result, options...)
+	}
+	changed = serverOptionsString(current) != serverOptionsString(result)
+	if len(result) == 0 {
+		delete(c.backendDefaultServers, backendName)
+		if _, errGet := config.Get(parser.Backends, backendName, "default-server"); errGet != nil {
+			return changed, nil
+		}
+		return changed, config.Set(parser.Backends, backendName, "default-server", nil)
+	}
+	c.backendDefaultServers[backendName] = result
+	return changed, config.Set(parser.Backends, backendName, "default-server", []types.DefaultServer{{Params: result}})
+}
+
+func serverOptionsString(options []params.ServerOption) string {
+	values := make([]string, 0, len(options))
+	for _, option := range options {
+		values = append(values, option.String())
+	}
+	return strings.Join(values, " ")
+}
+
+const (
+	configSnippetBegin = "# config-snippet begin"
+	configSnippetEnd   = "# config-snippet end"
+)
+
+// sectionSnippetSet replaces the config snippet of a section by the given lines.
+// Snippet lines are kept between marker comments among unprocessed lines of the section
+// so they can be replaced or removed when the annotation changes.
+func (c *HAProxyController) sectionSnippetSet(section parser.Section, sectionName string, lines []string) (changed bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	current := []types.UnProcessed{}
+	if data, errGet := config.Get(section, sectionName, ""); errGet == nil {
+		current = data.([]types.UnProcessed)
+	}
+	result := make([]types.UnProcessed, 0, len(current)+len(lines)+2)
+	oldLines := []string{}
+	inSnippet := false
+	for _, line := range current {
+		switch {
+		case line.Value == configSnippetBegin:
+			inSnippet = true
+		case line.Value == configSnippetEnd:
+			inSnippet = false
+		case inSnippet:
+			oldLines = append(oldLines, line.Value)
+		default:
+			result = append(result, line)
+		}
+	}
+	if strings.Join(oldLines, "\n") == strings.Join(lines, "\n") {
+		return false, nil
+	}
+	if len(lines) > 0 {
+		result = append(result, types.UnProcessed{Value: configSnippetBegin})
+		for _, line := range lines {
+			result = append(result, types.UnProcessed{Value: line})
+		}
+		result = append(result, types.UnProcessed{Value: configSnippetEnd})
+	}
+	c.ActiveTransactionHasChanges = true
+	return true, config.Set(section, sectionName, "", result)
+}
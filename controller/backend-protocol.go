@@ -0,0 +1,38 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"github.com/haproxytech/config-parser/v2/params"
+)
+
+// handleBackendProtocol sets "default-server proto h2" in the backend of services with
+// "backend-protocol: h2" so that requests are sent to servers over HTTP/2, which gRPC
+// servers require. Other protocols use HTTP/1.1 which is upgraded for WebSocket.
+func (c *HAProxyController) handleBackendProtocol(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	options := []params.ServerOption{}
+	annProtocol, _ := GetValueFromAnnotations("backend-protocol", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annProtocol != nil && annProtocol.Status != DELETED && annProtocol.Value == "h2" && c.featureSupported("server-proto") {
+		options = append(options, &params.ServerOptionValue{Name: "proto", Value: "h2"})
+	}
+	needsReload, err = c.backendDefaultServerSet(backendName, []string{"proto"}, options)
+	if err != nil {
+		return false, err
+	}
+	if needsReload {
+		c.ActiveTransactionHasChanges = true
+	}
+	return needsReload, nil
+}
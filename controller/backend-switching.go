@@ -18,22 +18,33 @@ import (
 	"fmt"
 	"log"
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
 	"github.com/haproxytech/models"
+	corev1 "k8s.io/api/core/v1"
 )
 
 type UseBackendRules map[string]UseBackendRule
 
 type UseBackendRule struct {
-	Host      string
-	Path      string
-	Backend   string
-	Namespace string
+	Host          string
+	Path          string
+	Backend       string
+	Namespace     string
+	Ingress       string
+	CanaryBackend string
+	CanaryWeight  int64
+	EjectFallback bool
+	Eject5xxRate  int64
 }
 
 func (c *HAProxyController) addUseBackendRule(key string, rule UseBackendRule, frontends ...string) {
 	for _, frontendName := range frontends {
+		if _, ok := c.cfg.BackendSwitchingRules[frontendName]; !ok {
+			c.cfg.BackendSwitchingRules[frontendName] = UseBackendRules{}
+		}
 		c.cfg.BackendSwitchingRules[frontendName][key] = rule
 		c.cfg.BackendSwitchingStatus[frontendName] = struct{}{}
 	}
@@ -48,60 +59,79 @@ func (c *HAProxyController) deleteUseBackendRule(key string, frontends ...string
 
 //  Recreate use_backend rules
 func (c *HAProxyController) refreshBackendSwitching() (needsReload bool) {
-	if len(c.cfg.BackendSwitchingStatus) == 0 {
+	maxRules, annMaxRules := c.maxRulesPerFrontend()
+	decoded, annPathMatch := c.pathMatchDecoded()
+	annConflictMode, _ := GetValueFromAnnotations("tls-conflict-mode", c.cfg.ConfigMap.Annotations)
+	if len(c.cfg.BackendSwitchingStatus) == 0 && annMaxRules == EMPTY && annPathMatch == EMPTY && (annConflictMode == nil || annConflictMode.Status == EMPTY) {
 		return false
 	}
+	c.handleTLSConflicts()
 	frontends, err := c.frontendsGet()
 	if err != nil {
 		utils.PanicErr(err)
 		return false
 	}
+	if annMaxRules != EMPTY || annPathMatch != EMPTY {
+		for _, frontend := range frontends {
+			c.cfg.BackendSwitchingStatus[frontend.Name] = struct{}{}
+		}
+	}
 	// Active backend will hold backends in use
-	activeBackends := map[string]struct{}{"RateLimit": struct{}{}}
+	activeBackends := map[string]struct{}{"RateLimit": struct{}{}, connRateLimitBackend: struct{}{}, tlsHandshakeRateBackend: struct{}{}, eject5xxRateBackend: struct{}{}, fallbackBackend: struct{}{}}
+	for _, backend := range c.cfg.DefaultBackends {
+		activeBackends[backend] = struct{}{}
+	}
 	for _, frontend := range frontends {
 		activeBackends[frontend.DefaultBackend] = struct{}{}
 		useBackendRules, ok := c.cfg.BackendSwitchingRules[frontend.Name]
 		if !ok {
 			continue
 		}
-		sortedKeys := []string{}
-		for key, rule := range useBackendRules {
+		for _, rule := range useBackendRules {
 			activeBackends[rule.Backend] = struct{}{}
-			sortedKeys = append(sortedKeys, key)
+			if rule.CanaryBackend != "" {
+				activeBackends[rule.CanaryBackend] = struct{}{}
+			}
 		}
 		if _, ok := c.cfg.BackendSwitchingStatus[frontend.Name]; !ok {
 			// No need to refresh rules if the use_backend rules
 			// of the frontend were not updated
 			continue
 		}
+		// use_backend rules are in the last rules phase, and
 		// host/path are part of use_backend keys, so sorting keys will
 		// result in sorted use_backend rules where the longest path will match first.
 		// Example:
 		// use_backend service-abc if { req.hdr(host) -i example } { path_beg /a/b/c }
 		// use_backend service-ab  if { req.hdr(host) -i example } { path_beg /a/b }
 		// use_backend service-a   if { req.hdr(host) -i example } { path_beg /a }
-		sort.Strings(sortedKeys)
+		sortedKeys := sortedUseBackendKeys(useBackendRules)
 		c.backendSwitchingRuleDeleteAll(frontend.Name)
-		for _, key := range sortedKeys {
+		created, skipped := c.createdUseBackendKeys(frontend.Name, frontend.Mode, useBackendRules, sortedKeys, maxRules)
+		if len(skipped) > 0 {
+			kept := make([]string, 0, len(created))
+			for i := len(created) - 1; i >= 0; i-- {
+				kept = append(kept, useBackendRuleLabel(useBackendRules[created[i]]))
+			}
+			message := fmt.Sprintf("frontend %s exceeds max-rules-per-frontend (%d), skipping %d use_backend rules, kept: %s", frontend.Name, maxRules, len(skipped), strings.Join(kept, ", "))
+			log.Printf("WARNING: %s", message)
+			c.recordSkippedRulesWarning(useBackendRules, skipped, message)
+		}
+		if frontend.Mode == "tcp" {
+			for _, key := range sortedKeys {
+				if useBackendRules[key].Host == "" {
+					log.Println(fmt.Sprintf("Empty SNI for backend %s, SKIP", useBackendRules[key].Backend))
+				}
+			}
+		}
+		rulesCount := 0
+		for _, key := range created {
 			rule := useBackendRules[key]
 			var condTest string
 			switch frontend.Mode {
 			case "http":
-				if rule.Host != "" {
-					condTest = fmt.Sprintf("{ req.hdr(host) -i %s } ", rule.Host)
-				}
-				if rule.Path != "" {
-					condTest = fmt.Sprintf("%s{ path_beg %s }", condTest, rule.Path)
-				}
-				if condTest == "" {
-					log.Println("Both Host and Path are empty for frontend %s with backend %s, SKIP", frontend, rule.Backend)
-					continue
-				}
+				condTest = useBackendCond(rule, decoded)
 			case "tcp":
-				if rule.Host == "" {
-					log.Println(fmt.Sprintf("Empty SNI for backend %s, SKIP", rule.Backend))
-					continue
-				}
 				condTest = fmt.Sprintf("{ req_ssl_sni -i %s } ", rule.Host)
 			}
 			err := c.backendSwitchingRuleCreate(frontend.Name, models.BackendSwitchingRule{
@@ -111,14 +141,183 @@ func (c *HAProxyController) refreshBackendSwitching() (needsReload bool) {
 				ID:       utils.PtrInt64(0),
 			})
 			utils.PanicErr(err)
+			rulesCount++
+			// inserted after the main rule so it is evaluated first
+			if rule.CanaryBackend != "" && frontend.Mode == "http" {
+				err = c.backendSwitchingRuleCreate(frontend.Name, models.BackendSwitchingRule{
+					Cond:     "if",
+					CondTest: fmt.Sprintf("%s { rand(100) lt %d }", strings.TrimSpace(condTest), rule.CanaryWeight),
+					Name:     rule.CanaryBackend,
+					ID:       utils.PtrInt64(0),
+				})
+				utils.PanicErr(err)
+				rulesCount++
+			}
 		}
+		metricFrontendRules.Set(float64(rulesCount), frontend.Name)
 		needsReload = true
 		delete(c.cfg.BackendSwitchingStatus, frontend.Name)
 	}
+	// status of frontends without use_backend rules or deleted ones
+	for frontendName := range c.cfg.BackendSwitchingStatus {
+		delete(c.cfg.BackendSwitchingStatus, frontendName)
+	}
+	c.updateNamespaceBackendsMetric()
 	needsReload = c.clearBackends(activeBackends) || needsReload
 	return needsReload
 }
 
+// useBackendCond returns the condition of the use_backend rule of an HTTP frontend
+func useBackendCond(rule UseBackendRule, decoded bool) (condTest string) {
+	if rule.Host != "" {
+		condTest = fmt.Sprintf("{ req.hdr(host) -i %s } ", rule.Host)
+	}
+	path := rule.Path
+	if path == "" && rule.Host == "" {
+		// rule without host nor path matches any request
+		path = "/"
+	}
+	if path != "" {
+		condTest = condTest + pathCond(path, decoded)
+	}
+	if rule.EjectFallback {
+		condTest = fmt.Sprintf("%s { nbsrv(%s) gt 0 }", condTest, rule.Backend)
+	}
+	if rule.Eject5xxRate > 0 {
+		condTest = fmt.Sprintf("%s { str(%s),table_gpc0_rate(%s) le %d }", condTest, rule.Backend, eject5xxRateBackend, rule.Eject5xxRate)
+	}
+	return condTest
+}
+
+// createdUseBackendKeys returns, in creation order, the keys of the use_backend rules created
+// in a frontend and the ones skipped by max-rules-per-frontend. Rules are inserted on top, so
+// the last keys are evaluated first: rules are skipped from the start of sortedKeys, the most
+// specific ones are kept, and ACME challenges rule is never skipped. A rule with a canary
+// backend costs two rules in HTTP mode, rules skipped by a TLS conflict or without SNI in
+// TCP mode are not created and cost nothing.
+func (c *HAProxyController) createdUseBackendKeys(frontendName, mode string, rules UseBackendRules, sortedKeys []string, maxRules int) (created, skipped []string) {
+	cost := func(rule UseBackendRule) int {
+		switch {
+		case c.tlsConflictSkip(frontendName, rule), mode == "tcp" && rule.Host == "":
+			return 0
+		case mode == "http" && rule.CanaryBackend != "":
+			return 2
+		}
+		return 1
+	}
+	rulesCount := 0
+	for _, key := range sortedKeys {
+		if rules[key].Ingress == acmeSolverIngress {
+			rulesCount += cost(rules[key])
+		}
+	}
+	start := 0
+	for i := len(sortedKeys) - 1; i >= 0; i-- {
+		rule := rules[sortedKeys[i]]
+		if rule.Ingress == acmeSolverIngress {
+			continue
+		}
+		if maxRules > 0 && rulesCount+cost(rule) > maxRules {
+			start = i + 1
+			break
+		}
+		rulesCount += cost(rule)
+	}
+	for i, key := range sortedKeys {
+		rule := rules[key]
+		if i < start && rule.Ingress != acmeSolverIngress {
+			skipped = append(skipped, key)
+			continue
+		}
+		if cost(rule) > 0 {
+			created = append(created, key)
+		}
+	}
+	return created, skipped
+}
+
+// useBackendRuleLabel returns host and path matched by a use_backend rule and its backend
+func useBackendRuleLabel(rule UseBackendRule) string {
+	match := rule.Host + rule.Path
+	if match == "" {
+		match = "/"
+	}
+	return fmt.Sprintf("%s (%s)", match, rule.Backend)
+}
+
+// sortedUseBackendKeys returns keys of use_backend rules in creation order
+func sortedUseBackendKeys(rules UseBackendRules) []string {
+	keys := make([]string, 0, len(rules))
+	for key := range rules {
+		keys = append(keys, key)
+	}
+	sortRuleKeys(keys)
+	sortHostlessRules(keys, rules)
+	// rules are inserted on top, ACME challenges rule is the first evaluated
+	sort.SliceStable(keys, func(i, j int) bool {
+		return rules[keys[i]].Ingress != acmeSolverIngress && rules[keys[j]].Ingress == acmeSolverIngress
+	})
+	return keys
+}
+
+// sortHostlessRules moves use_backend rules without host before host specific ones.
+// Rules are inserted on top, so rules of Ingress rules without host end up after
+// all host specific rules and a host match always wins over a path only match.
+// Rules without host are sorted by path, the longest one matching first.
+func sortHostlessRules(keys []string, rules UseBackendRules) {
+	sort.SliceStable(keys, func(i, j int) bool {
+		ruleI, ruleJ := rules[keys[i]], rules[keys[j]]
+		if ruleI.Host == "" && ruleJ.Host == "" {
+			return ruleI.Path < ruleJ.Path
+		}
+		return ruleI.Host == "" && ruleJ.Host != ""
+	})
+}
+
+// recordSkippedRulesWarning records a warning event on each ingress having one of the skipped rules
+func (c *HAProxyController) recordSkippedRulesWarning(rules UseBackendRules, skipped []string, message string) {
+	ingresses := map[string]struct{}{}
+	for _, key := range skipped {
+		rule := rules[key]
+		// ingresses generated by the controller (default service) have no events
+		if namespace := c.cfg.Namespace[rule.Namespace]; namespace == nil || namespace.Ingresses[rule.Ingress] == nil {
+			continue
+		}
+		ingresses[rule.Namespace+"/"+rule.Ingress] = struct{}{}
+	}
+	for ingress := range ingresses {
+		c.recordIngressWarning(ingress, "MaxRulesPerFrontend", message)
+	}
+}
+
+// recordIngressWarning records a Warning event on the <namespace>/<name> ingress
+func (c *HAProxyController) recordIngressWarning(ingress, reason, message string) {
+	if c.k8s == nil {
+		return
+	}
+	parts := strings.SplitN(ingress, "/", 2)
+	go func() {
+		utils.LogErr(c.k8s.CreateIngressEvent(parts[0], parts[1], corev1.EventTypeWarning, reason, message))
+	}()
+}
+
+// maxRulesPerFrontend returns the "max-rules-per-frontend" cap, 0 if there is no limit
+func (c *HAProxyController) maxRulesPerFrontend() (maxRules int, status Status) {
+	annMaxRules, err := GetValueFromAnnotations("max-rules-per-frontend", c.cfg.ConfigMap.Annotations)
+	if err != nil {
+		return 0, EMPTY
+	}
+	if annMaxRules.Status == DELETED {
+		return 0, DELETED
+	}
+	maxRules, err = strconv.Atoi(annMaxRules.Value)
+	if err != nil || maxRules < 0 {
+		utils.LogErr(fmt.Errorf("max-rules-per-frontend annotation: incorrect value '%s'", annMaxRules.Value))
+		return 0, annMaxRules.Status
+	}
+	return maxRules, annMaxRules.Status
+}
+
 // Remove unused backends
 func (c *HAProxyController) clearBackends(activeBackends map[string]struct{}) (needsReload bool) {
 	allBackends, err := c.backendsGet()
@@ -136,11 +335,71 @@ func (c *HAProxyController) clearBackends(activeBackends map[string]struct{}) (n
 	return needsReload
 }
 
-func (c *HAProxyController) setDefaultBackend(backendName string) (err error) {
-	for _, frontendName := range []string{FrontendHTTP, FrontendHTTPS} {
+// ruleFrontends returns the HTTP mode frontends of "frontends" ingress annotation
+// receiving use_backend rules of the ingress, e.g. a public and an internal frontend of
+// the base HAProxy configuration. HTTP and HTTPS frontends are used by default or
+// when a listed frontend does not exist.
+func (c *HAProxyController) ruleFrontends(ingress *Ingress) (frontends []string, err error) {
+	defaultFrontends := []string{FrontendHTTP, FrontendHTTPS}
+	annFrontends, _ := GetValueFromAnnotations("frontends", ingress.Annotations)
+	if annFrontends == nil || annFrontends.Status == DELETED {
+		return defaultFrontends, nil
+	}
+	for _, name := range strings.FieldsFunc(annFrontends.Value, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		frontend, errGet := c.frontendGet(name)
+		if errGet != nil {
+			return defaultFrontends, fmt.Errorf("frontends annotation: frontend '%s' does not exist", name)
+		}
+		if frontend.Mode != "http" || name == FrontendSSL {
+			return defaultFrontends, fmt.Errorf("frontends annotation: frontend '%s' is not in HTTP mode", name)
+		}
+		if !isMember(frontends, name) {
+			frontends = append(frontends, name)
+		}
+	}
+	if len(frontends) == 0 {
+		return defaultFrontends, fmt.Errorf("frontends annotation: empty value")
+	}
+	return frontends, nil
+}
+
+// httpRuleFrontends returns the frontends, other than SSL passthrough one, having a use_backend rule of key
+func (c *HAProxyController) httpRuleFrontends(key string) (frontends []string) {
+	for frontend, rules := range c.cfg.BackendSwitchingRules {
+		if _, ok := rules[key]; ok && frontend != FrontendSSL {
+			frontends = append(frontends, frontend)
+		}
+	}
+	sort.Strings(frontends)
+	return frontends
+}
+
+// deleteFrontendRules removes use_backend rules of a deleted frontend, the frontend
+// status triggers refreshBackendSwitching so that backends it used are removed.
+func (c *HAProxyController) deleteFrontendRules(frontendName string) {
+	delete(c.cfg.BackendSwitchingRules, frontendName)
+	c.cfg.BackendSwitchingStatus[frontendName] = struct{}{}
+	metricFrontendRules.Delete(frontendName)
+}
+
+// setDefaultBackend sets default_backend of frontends, HTTP and HTTPS ones if none
+// is given, so that each frontend can have its own catch-all. It is removed
+// when "default-backend-disabled" annotation is enabled.
+func (c *HAProxyController) setDefaultBackend(backendName string, frontends ...string) (err error) {
+	if len(frontends) == 0 {
+		frontends = []string{FrontendHTTP, FrontendHTTPS}
+	}
+	value := backendName
+	if c.cfg.DefaultBackendDisabled {
+		value = ""
+	}
+	for _, frontendName := range frontends {
+		c.cfg.DefaultBackends[frontendName] = backendName
 		frontend, e := c.frontendGet(frontendName)
-		if e == nil {
-			frontend.DefaultBackend = backendName
+		if e == nil && frontend.DefaultBackend != value {
+			frontend.DefaultBackend = value
 			e = c.frontendEdit(frontend)
 		}
 		if e != nil {
@@ -149,3 +408,45 @@ func (c *HAProxyController) setDefaultBackend(backendName string) (err error) {
 	}
 	return err
 }
+
+// unsetDefaultBackend sets back fallbackBackend on frontends having backendName
+// as default backend, except the kept ones
+func (c *HAProxyController) unsetDefaultBackend(backendName string, keep ...string) (err error) {
+	frontends := []string{}
+	for frontend, backend := range c.cfg.DefaultBackends {
+		if backend == backendName && !isMember(keep, frontend) {
+			frontends = append(frontends, frontend)
+		}
+	}
+	if len(frontends) == 0 {
+		return nil
+	}
+	sort.Strings(frontends)
+	return c.setDefaultBackend(fallbackBackend, frontends...)
+}
+
+// handleDefaultBackendDisabled removes default_backend of HTTP frontends when
+// "default-backend-disabled" annotation is enabled, requests matching no use_backend
+// rule are then answered with a 503 by HAProxy instead of being routed.
+func (c *HAProxyController) handleDefaultBackendDisabled() (needsReload bool, err error) {
+	annDisabled, errAnn := GetValueFromAnnotations("default-backend-disabled", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annDisabled.Status == EMPTY {
+		return false, nil
+	}
+	disabled := false
+	if annDisabled.Status != DELETED {
+		if disabled, err = utils.GetBoolValue(annDisabled.Value, "default-backend-disabled"); err != nil {
+			return false, err
+		}
+	}
+	if disabled == c.cfg.DefaultBackendDisabled {
+		return false, nil
+	}
+	c.cfg.DefaultBackendDisabled = disabled
+	for frontend, backend := range c.cfg.DefaultBackends {
+		if errSet := c.setDefaultBackend(backend, frontend); errSet != nil {
+			err = errSet
+		}
+	}
+	return true, err
+}
@@ -0,0 +1,292 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+func TestMaxRulesPerFrontend(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	c.testIngress("default", "a")
+	c.testIngress("default", "b")
+	c.cfg.ConfigMap.Annotations = MapStringW{"max-rules-per-frontend": &StringW{Value: "1", Status: ADDED}}
+	c.addUseBackendRule("Rdefaultaexample.com/a", UseBackendRule{Host: "example.com", Path: "/a", Backend: "default-app-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaultbexample.com/b", UseBackendRule{Host: "example.com", Path: "/b", Backend: "default-app-80", Namespace: "default", Ingress: "b"}, FrontendHTTP)
+	config := c.testSync(t, func() {
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if count := strings.Count(config, "use_backend"); count != 1 {
+		t.Errorf("expected 1 use_backend rule, got %d:\n%s", count, config)
+	}
+	events := testEvents(t, client, "default", 1)
+	if events[0].Reason != "MaxRulesPerFrontend" || events[0].Type != "Warning" {
+		t.Errorf("unexpected event %s %s: %s", events[0].Type, events[0].Reason, events[0].Message)
+	}
+	if !strings.Contains(events[0].Message, "skipping 1 use_backend rules") {
+		t.Errorf("unexpected message '%s'", events[0].Message)
+	}
+}
+
+func TestMaxRulesPerFrontendGeneratedIngress(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	c.cfg.ConfigMap.Annotations = MapStringW{"max-rules-per-frontend": &StringW{Value: "1", Status: ADDED}}
+	c.addUseBackendRule("RdefaultDefaultServiceexample.com/a", UseBackendRule{Host: "example.com", Path: "/a", Backend: "default-app-80", Namespace: "default", Ingress: "DefaultService"}, FrontendHTTP)
+	c.addUseBackendRule("RdefaultDefaultServiceexample.com/b", UseBackendRule{Host: "example.com", Path: "/b", Backend: "default-app-80", Namespace: "default", Ingress: "DefaultService"}, FrontendHTTP)
+	c.testSync(t, func() { c.refreshBackendSwitching() })
+	testEvents(t, client, "default", 0)
+}
+
+func TestMaxRulesPerFrontendKeptRules(t *testing.T) {
+	tests := []struct {
+		maxRules string
+		expected []string
+		skipped  int
+		kept     string
+	}{
+		{
+			maxRules: "4",
+			expected: []string{
+				"use_backend default-acme-80 if { path_beg /.well-known/acme-challenge/ }",
+				"use_backend default-canary-80 if { req.hdr(host) -i example.org } { path_beg / } { rand(100) lt 10 }",
+				"use_backend default-org-80 if { req.hdr(host) -i example.org } { path_beg / }",
+				"use_backend default-example-80 if { req.hdr(host) -i example.com } { path_beg / }",
+			},
+			skipped: 1,
+			kept:    "kept: /.well-known/acme-challenge/ (default-acme-80), example.org/ (default-org-80), example.com/ (default-example-80)",
+		},
+		{
+			// the canary rule does not fit, rules evaluated after it are skipped too
+			maxRules: "2",
+			expected: []string{
+				"use_backend default-acme-80 if { path_beg /.well-known/acme-challenge/ }",
+			},
+			skipped: 3,
+			kept:    "kept: /.well-known/acme-challenge/ (default-acme-80)",
+		},
+		{
+			// ACME challenges rule is never skipped
+			maxRules: "1",
+			expected: []string{
+				"use_backend default-acme-80 if { path_beg /.well-known/acme-challenge/ }",
+			},
+			skipped: 3,
+			kept:    "kept: /.well-known/acme-challenge/ (default-acme-80)",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.maxRules, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			client := c.testK8s()
+			for _, name := range []string{"a", "b", "c"} {
+				c.testIngress("default", name)
+			}
+			c.cfg.ConfigMap.Annotations = MapStringW{"max-rules-per-frontend": &StringW{Value: test.maxRules, Status: ADDED}}
+			c.addUseBackendRule("RdefaultACMESolver/.well-known/acme-challenge/", UseBackendRule{Path: acmeChallengePath, Backend: "default-acme-80", Namespace: "default", Ingress: acmeSolverIngress}, FrontendHTTP)
+			c.addUseBackendRule("Rdefaulta/api", UseBackendRule{Path: "/api", Backend: "default-any-api-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+			c.addUseBackendRule("Rdefaultbexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-example-80", Namespace: "default", Ingress: "b"}, FrontendHTTP)
+			c.addUseBackendRule("Rdefaultcexample.org/", UseBackendRule{Host: "example.org", Path: "/", Backend: "default-org-80", Namespace: "default", Ingress: "c", CanaryBackend: "default-canary-80", CanaryWeight: 10}, FrontendHTTP)
+			config := c.testSync(t, func() { c.refreshBackendSwitching() })
+			got := []string{}
+			for _, line := range testSection(config, "frontend http") {
+				if strings.HasPrefix(line, "use_backend") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+			}
+			events := testEvents(t, client, "default", test.skipped)
+			if !strings.Contains(events[0].Message, test.kept) {
+				t.Errorf("expected '%s' in '%s'", test.kept, events[0].Message)
+			}
+		})
+	}
+}
+
+func TestHostlessRules(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.addUseBackendRule("Rdefaulta/api", UseBackendRule{Path: "/api", Backend: "default-any-api-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaulta/", UseBackendRule{Path: "/", Backend: "default-any-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaultbexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-example-80", Namespace: "default", Ingress: "b"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaultc", UseBackendRule{Backend: "default-all-80", Namespace: "default", Ingress: "c"}, FrontendHTTP)
+	config := c.testSync(t, func() { c.refreshBackendSwitching() })
+	// host specific rule wins over path only ones, then the longest path matches first
+	expected := []string{
+		"use_backend default-example-80 if { req.hdr(host) -i example.com } { path_beg / }",
+		"use_backend default-any-api-80 if { path_beg /api }",
+		"use_backend default-any-80 if { path_beg / }",
+		"use_backend default-all-80 if { path_beg / }",
+	}
+	got := []string{}
+	for _, line := range testSection(config, "frontend http") {
+		if strings.HasPrefix(line, "use_backend") {
+			got = append(got, line)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestDefaultBackendDisabled(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations["default-backend-disabled"] = &StringW{Value: "true", Status: ADDED}
+	c.addUseBackendRule("Rdefaultaexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+	config := c.testSync(t, func() {
+		if err := c.setDefaultBackend(fallbackBackend); err != nil {
+			t.Fatal(err)
+		}
+		if reload, err := c.handleDefaultBackendDisabled(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		c.refreshBackendSwitching()
+	})
+	// unmatched requests are not routed to any backend
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		for _, line := range testSection(config, frontend) {
+			if strings.HasPrefix(line, "default_backend") {
+				t.Errorf("%s: expected no default_backend, got '%s'", frontend, line)
+			}
+		}
+	}
+	if !testSectionHas(config, "frontend http", "use_backend default-app-80 if { req.hdr(host) -i example.com } { path_beg / }") {
+		t.Errorf("expected use_backend rule:\n%s", config)
+	}
+	if !strings.Contains(config, "backend default_backend") {
+		t.Errorf("expected default backend to be kept:\n%s", config)
+	}
+
+	c.cfg.ConfigMap.Annotations["default-backend-disabled"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleDefaultBackendDisabled(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		if !testSectionHas(config, frontend, "default_backend default_backend") {
+			t.Errorf("%s: expected default_backend:\n%s", frontend, config)
+		}
+	}
+}
+
+// testDefaultBackends returns the default_backend of http, https and internal frontends
+func testDefaultBackends(config string) string {
+	backends := []string{}
+	for _, frontend := range []string{"http", "https", "internal"} {
+		backend := ""
+		for _, line := range testSection(config, "frontend "+frontend) {
+			if strings.HasPrefix(line, "default_backend ") {
+				backend = strings.TrimPrefix(line, "default_backend ")
+			}
+		}
+		backends = append(backends, frontend+"="+backend)
+	}
+	return strings.Join(backends, ",")
+}
+
+func TestDefaultBackendPerFrontend(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testServiceEndpoints("default", "app")
+	c.testServiceEndpoints("default", "internal-app")
+	public := c.testIngress("default", "public")
+	public.DefaultBackend = &IngressPath{ServiceName: "app", ServicePortInt: 80, IsDefaultBackend: true, Status: ADDED}
+	internal := c.testIngress("default", "internal")
+	internal.Annotations = MapStringW{"frontends": &StringW{Value: "internal", Status: ADDED}}
+	internal.DefaultBackend = &IngressPath{ServiceName: "internal-app", ServicePortInt: 80, IsDefaultBackend: true, Status: ADDED}
+	namespace := c.cfg.GetNamespace("default")
+	update := func(ingresses ...*Ingress) string {
+		return c.testSync(t, func() {
+			for _, ingress := range ingresses {
+				if _, err := c.handlePath(namespace, ingress, &IngressRule{}, ingress.DefaultBackend); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.refreshBackendSwitching()
+		})
+	}
+	c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: "internal", Mode: "http", DefaultBackend: fallbackBackend}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	config := update(public, internal)
+	expected := "http=default-app-80,https=default-app-80,internal=default-internal-app-80"
+	if got := testDefaultBackends(config); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+	if !strings.Contains(config, "backend default-app-80") || !strings.Contains(config, "backend default-internal-app-80") {
+		t.Errorf("expected default backends to be kept:\n%s", config)
+	}
+
+	// internal frontend falls back to the default backend of the base configuration
+	internal.DefaultBackend.Status = DELETED
+	expected = "http=default-app-80,https=default-app-80,internal=default_backend"
+	if got := testDefaultBackends(update(internal)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+
+	// default backend moved to the internal frontend
+	public.Annotations = MapStringW{"frontends": &StringW{Value: "internal", Status: ADDED}}
+	public.DefaultBackend.Status = MODIFIED
+	expected = "http=default_backend,https=default_backend,internal=default-app-80"
+	if got := testDefaultBackends(update(public)); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
+
+func TestDefaultBackendDisabledPerFrontend(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations["default-backend-disabled"] = &StringW{Value: "true", Status: ADDED}
+	config := c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: "internal", Mode: "http"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.setDefaultBackend("default-app-80", "internal"); err != nil {
+			t.Fatal(err)
+		}
+		if reload, err := c.handleDefaultBackendDisabled(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if got := testDefaultBackends(config); got != "http=,https=,internal=" {
+		t.Errorf("expected no default_backend, got %s", got)
+	}
+	c.cfg.ConfigMap.Annotations["default-backend-disabled"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleDefaultBackendDisabled(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	// each frontend gets its own default backend back
+	expected := "http=default_backend,https=default_backend,internal=default-app-80"
+	if got := testDefaultBackends(config); got != expected {
+		t.Errorf("expected %s, got %s", expected, got)
+	}
+}
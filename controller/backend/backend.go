@@ -16,6 +16,7 @@ package backend
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
@@ -35,9 +36,18 @@ func (b *Backend) UpdateAbortOnClose(value string) error {
 
 func (b *Backend) UpdateBalance(value string) error {
 	//TODO Balance proper usage
-	val := &models.Balance{
-		Algorithm: &value,
+	algorithm := strings.TrimSpace(value)
+	val := &models.Balance{}
+	// random(<draws>)
+	if strings.HasPrefix(algorithm, "random(") && strings.HasSuffix(algorithm, ")") {
+		draws, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(algorithm, "random("), ")"), 10, 64)
+		if err != nil || draws < 1 {
+			return fmt.Errorf("balance algorithm: random draws should be a positive integer, got '%s'", algorithm)
+		}
+		algorithm = "random"
+		val.RandomDraws = draws
 	}
+	val.Algorithm = &algorithm
 	if err := val.Validate(nil); err != nil {
 		return fmt.Errorf("balance algorithm: %s", err)
 	}
@@ -105,3 +115,51 @@ func (b *Backend) UpdateHttpchk(value string) error {
 	b.Httpchk = val
 	return nil
 }
+
+func (b *Backend) UpdateRetries(value string) error {
+	retries, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || retries < 0 {
+		return fmt.Errorf("retries: incorrect value '%s'", value)
+	}
+	b.Retries = &retries
+	return nil
+}
+
+// UpdateHttpchkHost sets the Host header sent by HTTP checks,
+// using the "option httpchk <method> <uri> <version>" syntax.
+func (b *Backend) UpdateHttpchkHost(value string) error {
+	host := strings.TrimSpace(value)
+	if b.Httpchk == nil {
+		return fmt.Errorf("httpchk host: check-http is not configured")
+	}
+	if host == "" || strings.ContainsAny(host, " \t\\") {
+		return fmt.Errorf("httpchk host: incorrect value '%s'", value)
+	}
+	if b.Httpchk.Method == "" {
+		b.Httpchk.Method = "GET"
+	}
+	// the header is deliberately appended to the version field: this HAProxy syntax
+	// has no other place for headers, HTTP/1.1 checks require a Host header
+	b.Httpchk.Version = fmt.Sprintf(`HTTP/1.1\r\nHost:%s`, host)
+	return b.Httpchk.Validate(nil)
+}
+
+// UpdateRedispatch sets what happens when the server a session is persisted
+// to (cookie, stick table) is down: "redispatch" to another server or "error".
+func (b *Backend) UpdateRedispatch(value string) error {
+	var enabled string
+	switch strings.TrimSpace(value) {
+	case "redispatch":
+		enabled = "enabled"
+	case "error":
+		enabled = "disabled"
+	default:
+		return fmt.Errorf("sticky fallback: incorrect value '%s', expected redispatch or error", value)
+	}
+	// interval 0 would disable redispatch, -1 redispatches on last retry
+	b.Redispatch = &models.Redispatch{
+		Enabled:  &enabled,
+		Interval: -1,
+	}
+	return b.Redispatch.Validate(nil)
+}
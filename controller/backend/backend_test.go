@@ -0,0 +1,123 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backend
+
+import (
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+func TestUpdateBalance(t *testing.T) {
+	tests := []struct {
+		value     string
+		algorithm string
+		draws     int64
+		err       bool
+	}{
+		{"roundrobin", "roundrobin", 0, false},
+		{" leastconn ", "leastconn", 0, false},
+		{"random", "random", 0, false},
+		{"random(2)", "random", 2, false},
+		{"random(0)", "", 0, true},
+		{"random(two)", "", 0, true},
+		{"fastest", "", 0, true},
+	}
+	for _, test := range tests {
+		b := Backend{}
+		err := b.UpdateBalance(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t, got %v", test.value, test.err, err)
+			continue
+		}
+		if test.err {
+			if b.Balance != nil {
+				t.Errorf("%s: expected balance unchanged", test.value)
+			}
+			continue
+		}
+		if *b.Balance.Algorithm != test.algorithm || b.Balance.RandomDraws != test.draws {
+			t.Errorf("%s: expected %s(%d), got %s(%d)", test.value, test.algorithm, test.draws, *b.Balance.Algorithm, b.Balance.RandomDraws)
+		}
+	}
+}
+
+func TestUpdateRetries(t *testing.T) {
+	for value, expected := range map[string]int64{"0": 0, "3": 3, "-1": -1, "x": -1} {
+		b := Backend{}
+		err := b.UpdateRetries(value)
+		if expected < 0 {
+			if err == nil {
+				t.Errorf("%s: expected error", value)
+			}
+			continue
+		}
+		if err != nil || b.Retries == nil || *b.Retries != expected {
+			t.Errorf("%s: expected %d, got %v %v", value, expected, b.Retries, err)
+		}
+	}
+}
+
+func TestUpdateHttpchkHost(t *testing.T) {
+	tests := []struct {
+		name     string
+		httpchk  *models.Httpchk
+		host     string
+		expected models.Httpchk
+		err      bool
+	}{
+		{
+			name:     "uri",
+			httpchk:  &models.Httpchk{URI: "/health"},
+			host:     "example.com",
+			expected: models.Httpchk{Method: "GET", URI: "/health", Version: `HTTP/1.1\r\nHost:example.com`},
+		},
+		{
+			name:     "method uri",
+			httpchk:  &models.Httpchk{Method: "HEAD", URI: "/"},
+			host:     " example.com ",
+			expected: models.Httpchk{Method: "HEAD", URI: "/", Version: `HTTP/1.1\r\nHost:example.com`},
+		},
+		{
+			name: "no httpchk",
+			host: "example.com",
+			err:  true,
+		},
+		{
+			name:    "space in host",
+			httpchk: &models.Httpchk{URI: "/"},
+			host:    "example.com x",
+			err:     true,
+		},
+		{
+			name:    "escape in host",
+			httpchk: &models.Httpchk{URI: "/"},
+			host:    `example.com\r\nX-Injected:1`,
+			err:     true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			b := Backend{Httpchk: test.httpchk}
+			err := b.UpdateHttpchkHost(test.host)
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if !test.err && *b.Httpchk != test.expected {
+				t.Errorf("expected %+v, got %+v", test.expected, *b.Httpchk)
+			}
+		})
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// handleBackupServers adds the endpoints of "backup-service" (<service>:<port> of the same namespace)
+// as backup servers of a backend, e.g. a static maintenance page. Backup servers only receive
+// traffic when all servers of the service are down, the first one only unless "allbackups" is enabled.
+// Backup servers of each backend are tracked in backupServers.
+func (c *HAProxyController) handleBackupServers(namespace *Namespace, ingress *Ingress, service *Service, backendName string, newBackend bool) (needReload bool, err error) {
+	if backendName == "" {
+		return false, nil
+	}
+	desired := map[string]models.Server{}
+	annBackup, _ := GetValueFromAnnotations("backup-service", service.Annotations, ingress.Annotations)
+	if annBackup != nil && annBackup.Status != DELETED {
+		desired, err = c.backupServersDesired(namespace, ingress, service, annBackup.Value)
+		if err != nil {
+			err = fmt.Errorf("backup-service annotation: %s", err)
+		}
+	}
+	current := c.backupServers[backendName]
+	if newBackend {
+		current = nil
+	}
+	for name, server := range desired {
+		old, ok := current[name]
+		switch {
+		case !ok:
+			if errCreate := c.backendServerCreate(backendName, server); errCreate != nil {
+				if !strings.Contains(errCreate.Error(), "already exists") {
+					utils.LogErr(errCreate)
+					continue
+				}
+				utils.LogErr(c.backendServerEdit(backendName, server))
+			}
+			needReload = true
+		case !reflect.DeepEqual(old, server):
+			utils.LogErr(c.backendServerEdit(backendName, server))
+			needReload = true
+		}
+	}
+	for name := range current {
+		if _, ok := desired[name]; !ok {
+			errDelete := c.backendServerDelete(backendName, name)
+			if errDelete != nil && !strings.Contains(errDelete.Error(), "does not exist") {
+				utils.LogErr(errDelete)
+			}
+			needReload = true
+		}
+	}
+	if len(desired) == 0 {
+		delete(c.backupServers, backendName)
+	} else {
+		c.backupServers[backendName] = desired
+	}
+	return needReload, err
+}
+
+// backupServersDesired returns backup servers of backup-service value
+func (c *HAProxyController) backupServersDesired(namespace *Namespace, ingress *Ingress, service *Service, value string) (map[string]models.Server, error) {
+	parts := strings.SplitN(strings.TrimSpace(value), ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("expected <service>:<port>, got '%s'", value)
+	}
+	if parts[0] == service.Name {
+		return nil, fmt.Errorf("service '%s' can not be its own backup", service.Name)
+	}
+	backupService, ok := namespace.Services[parts[0]]
+	if !ok || backupService.Status == DELETED {
+		return nil, fmt.Errorf("service '%s' does not exist", parts[0])
+	}
+	path := &IngressPath{ServiceName: backupService.Name}
+	if port, errPort := strconv.ParseInt(parts[1], 10, 64); errPort == nil {
+		path.ServicePortInt = port
+	} else {
+		path.ServicePortString = parts[1]
+	}
+	servers := map[string]models.Server{}
+	endpoints, ok := namespace.Endpoints[backupService.Name]
+	if !ok {
+		return servers, nil
+	}
+	if err := c.setTargetPort(path, backupService, endpoints); err != nil {
+		return nil, err
+	}
+	for _, ip := range *endpoints.Addresses {
+		if ip.Status == DELETED || ip.HAProxyName == "" {
+			continue
+		}
+		port := path.TargetPort
+		server := models.Server{
+			Name:    "BCK_" + ip.HAProxyName,
+			Address: ip.IP,
+			Port:    &port,
+			Weight:  utils.PtrInt64(defaultServerWeight),
+			Backup:  "enabled",
+		}
+		if ip.Disabled {
+			server.Maintenance = "enabled"
+		}
+		c.handleServerAnnotations(ingress, service, &server)
+		servers[server.Name] = server
+	}
+	return servers, nil
+}
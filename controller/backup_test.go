@@ -0,0 +1,101 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackupServers(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	namespace := c.cfg.GetNamespace("default")
+	c.testService("default", "app", nil)
+	backup := c.testService("default", "maintenance", nil)
+	namespace.Endpoints[backup.Name] = &Endpoints{
+		Namespace: "default",
+		Ports:     &EndpointPorts{{Name: "http", Protocol: "TCP", Port: 8080}},
+		Addresses: &EndpointIPs{
+			"10.0.0.5": {IP: "10.0.0.5", HAProxyName: "SRV_1", Status: ADDED},
+			"10.0.0.6": {IP: "10.0.0.6", HAProxyName: "SRV_2", Disabled: true, Status: ADDED},
+		},
+		Status: ADDED,
+	}
+	ingress := &Ingress{Annotations: MapStringW{"backup-service": &StringW{Value: "maintenance:80", Status: ADDED}}}
+	service := namespace.Services["app"]
+	config := c.testSync(t, func() {
+		if reload, err := c.handleBackupServers(namespace, ingress, service, "default-app-80", false); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	// used by HAProxy only when all servers of the backend are down
+	for _, server := range []string{
+		"server BCK_SRV_1 10.0.0.5:8080 backup weight 128",
+		"server BCK_SRV_2 10.0.0.6:8080 backup disabled weight 128",
+	} {
+		if !testSectionHas(config, "backend default-app-80", server) {
+			t.Errorf("expected '%s':\n%s", server, config)
+		}
+	}
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackupServers(namespace, ingress, service, "default-app-80", false); reload {
+			t.Error("expected no reload")
+		}
+	})
+
+	ingress.Annotations["backup-service"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, _ := c.handleBackupServers(namespace, ingress, service, "default-app-80", false); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "BCK_") {
+		t.Errorf("expected no backup servers:\n%s", config)
+	}
+}
+
+func TestHandleBackupServersErrors(t *testing.T) {
+	for _, value := range []string{"maintenance", "app:80", "missing:80"} {
+		t.Run(value, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			namespace := c.cfg.GetNamespace("default")
+			service := c.testService("default", "app", nil)
+			ingress := &Ingress{Annotations: MapStringW{"backup-service": &StringW{Value: value, Status: ADDED}}}
+			c.testSync(t, func() {
+				if _, err := c.handleBackupServers(namespace, ingress, service, "default-app-80", false); err == nil {
+					t.Error("expected error")
+				}
+			})
+		})
+	}
+}
+
+func TestAllBackups(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: MapStringW{"allbackups": &StringW{Value: "true", Status: ADDED}}}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleBackendOption("allbackups", ingress, service, "default-app-80", false); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	// all backup servers are used instead of the first one
+	if !testSectionHas(config, "backend default-app-80", "option allbackups") {
+		t.Errorf("expected option allbackups:\n%s", config)
+	}
+}
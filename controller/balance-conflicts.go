@@ -0,0 +1,114 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// getBackendName returns the name of the backend of a service port,
+// a backend can be shared by paths of multiple ingresses
+func getBackendName(namespace *Namespace, service *Service, path *IngressPath) string {
+	if path.ServicePortInt == 0 {
+		return fmt.Sprintf("%s-%s-%s", namespace.Name, service.Name, path.ServicePortString)
+	}
+	return fmt.Sprintf("%s-%s-%d", namespace.Name, service.Name, path.ServicePortInt)
+}
+
+// detectBalanceConflicts finds backends shared by ingresses with different
+// "load-balance" annotations. Balance is backend scoped, so the value of the ingress
+// first in <namespace>/<name> order is used for such backends and a warning is logged.
+// The default service has the lowest priority: when its backend is shared with ingresses,
+// it uses their value without warning.
+func (c *HAProxyController) detectBalanceConflicts() {
+	// backend -> ingress -> load-balance value
+	balances := map[string]map[string]string{}
+	// backend -> load-balance value of the default service
+	defaultBalances := map[string]string{}
+	addPaths := func(namespace *Namespace, ingress *Ingress, paths []*IngressPath, set func(backendName, value string)) {
+		for _, path := range paths {
+			service, ok := namespace.Services[path.ServiceName]
+			if !ok || path.Status == DELETED || service.Status == DELETED {
+				continue
+			}
+			annBalance, err := GetValueFromAnnotations("load-balance", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+			if err != nil || annBalance.Status == DELETED {
+				continue
+			}
+			set(getBackendName(namespace, service, path), annBalance.Value)
+		}
+	}
+	for _, namespace := range c.cfg.Namespace {
+		if !namespace.Relevant {
+			continue
+		}
+		for _, ingress := range namespace.Ingresses {
+			annClass, _ := GetValueFromAnnotations("ingress.class", ingress.Annotations)
+			if ingress.Status == DELETED || (annClass.Value != "" && annClass.Value != c.osArgs.IngressClass) {
+				continue
+			}
+			paths := []*IngressPath{}
+			if ingress.DefaultBackend != nil {
+				paths = append(paths, ingress.DefaultBackend)
+			}
+			for _, rule := range ingress.Rules {
+				for _, path := range rule.Paths {
+					paths = append(paths, path)
+				}
+			}
+			name := namespace.Name + "/" + ingress.Name
+			addPaths(namespace, ingress, paths, func(backendName, value string) {
+				if _, ok := balances[backendName]; !ok {
+					balances[backendName] = map[string]string{}
+				}
+				balances[backendName][name] = value
+			})
+		}
+	}
+	if namespace, ingress, path, err := c.defaultServicePath(); err == nil && path != nil {
+		addPaths(namespace, ingress, []*IngressPath{path}, func(backendName, value string) {
+			defaultBalances[backendName] = value
+		})
+	}
+	overrides := map[string]string{}
+	for backendName, ingresses := range balances {
+		names := make([]string, 0, len(ingresses))
+		values := map[string]struct{}{}
+		for name, value := range ingresses {
+			names = append(names, name)
+			values[value] = struct{}{}
+		}
+		sort.Strings(names)
+		if len(values) < 2 {
+			if value, ok := defaultBalances[backendName]; ok && value != ingresses[names[0]] {
+				overrides[backendName] = ingresses[names[0]]
+			}
+			continue
+		}
+		overrides[backendName] = ingresses[names[0]]
+		if c.balanceOverrides[backendName] != overrides[backendName] {
+			conflicts := make([]string, len(names))
+			for i, name := range names {
+				conflicts[i] = fmt.Sprintf("%s: %s", name, ingresses[name])
+			}
+			log.Printf("WARNING: conflicting load-balance annotations for backend %s (%s), using '%s' of ingress %s",
+				backendName, strings.Join(conflicts, ", "), overrides[backendName], names[0])
+		}
+	}
+	c.balanceOverrides = overrides
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// handleCanary splits traffic of an ingress path between its service and the
+// "canary-service" (<namespace>/<service>:<port>) according to "canary-weight" percentage.
+// Canary service can live in another namespace only with --allow-cross-namespace-backends.
+func (c *HAProxyController) handleCanary(namespace *Namespace, ingress *Ingress, rule *IngressRule, path *IngressPath) (needReload bool, err error) {
+	key := fmt.Sprintf("R%s%s%s%s", namespace.Name, ingress.Name, rule.Host, path.Path)
+	annService, _ := GetValueFromAnnotations("canary-service", ingress.Annotations)
+	annWeight, _ := GetValueFromAnnotations("canary-weight", ingress.Annotations)
+	canaryBackend := ""
+	weight := int64(0)
+	defer func() {
+		c.setCanaryRule(key, canaryBackend, weight)
+	}()
+	if annService == nil || annService.Status == DELETED || path.Status == DELETED {
+		delete(c.canaryPaths, key)
+		return false, nil
+	}
+	if annWeight == nil || annWeight.Status == DELETED {
+		return false, fmt.Errorf("canary-service annotation: canary-weight is not set")
+	}
+	weight, err = strconv.ParseInt(annWeight.Value, 10, 64)
+	if err != nil || weight < 0 || weight > 100 {
+		weight = 0
+		return false, fmt.Errorf("canary-weight annotation: weight should be between 0 and 100, got '%s'", annWeight.Value)
+	}
+	canaryNamespace, canaryPath, err := c.canaryPath(namespace, path, annService.Value)
+	if err != nil {
+		weight = 0
+		return false, fmt.Errorf("canary-service annotation: %s", err)
+	}
+	if old, ok := c.canaryPaths[key]; ok && old.ServiceName == canaryPath.ServiceName &&
+		old.ServicePortInt == canaryPath.ServicePortInt && old.ServicePortString == canaryPath.ServicePortString {
+		canaryPath = old
+	} else {
+		c.canaryPaths[key] = canaryPath
+	}
+	needReload, err = c.handlePath(canaryNamespace, ingress, rule, canaryPath)
+	canaryPath.Status = EMPTY
+	if err != nil {
+		weight = 0
+		return needReload, err
+	}
+	service := canaryNamespace.Services[canaryPath.ServiceName]
+	endpoints, ok := canaryNamespace.Endpoints[service.Name]
+	if !ok || endpoints.BackendName == "" {
+		weight = 0
+		return needReload, nil
+	}
+	canaryBackend = endpoints.BackendName
+	return needReload, nil
+}
+
+// canaryPath parses <namespace>/<service>:<port> value of canary-service annotation,
+// namespace defaults to the one of the ingress.
+func (c *HAProxyController) canaryPath(namespace *Namespace, ingressPath *IngressPath, value string) (*Namespace, *IngressPath, error) {
+	nsName := namespace.Name
+	service := strings.TrimSpace(value)
+	if parts := strings.SplitN(service, "/", 2); len(parts) == 2 {
+		nsName, service = parts[0], parts[1]
+	}
+	parts := strings.SplitN(service, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, nil, fmt.Errorf("expected <namespace>/<service>:<port>, got '%s'", value)
+	}
+	if nsName != namespace.Name && !c.osArgs.AllowCrossNamespace {
+		return nil, nil, fmt.Errorf("service '%s' is in namespace '%s', cross namespace backends are not allowed", parts[0], nsName)
+	}
+	canaryNamespace, ok := c.cfg.Namespace[nsName]
+	if !ok || !canaryNamespace.Relevant {
+		return nil, nil, fmt.Errorf("namespace '%s' is not watched by the controller", nsName)
+	}
+	path := &IngressPath{
+		ServiceName: parts[0],
+		Path:        ingressPath.Path,
+		IsCanary:    true,
+		Status:      ADDED,
+	}
+	if port, err := strconv.ParseInt(parts[1], 10, 64); err == nil {
+		path.ServicePortInt = port
+	} else {
+		path.ServicePortString = parts[1]
+	}
+	return canaryNamespace, path, nil
+}
+
+// setCanaryRule updates canary backend of use_backend rules of HTTP frontends
+func (c *HAProxyController) setCanaryRule(key string, backend string, weight int64) {
+	if weight == 0 {
+		backend = ""
+	}
+	for _, frontend := range c.httpRuleFrontends(key) {
+		rule, ok := c.cfg.BackendSwitchingRules[frontend][key]
+		if !ok || (rule.CanaryBackend == backend && rule.CanaryWeight == weight) {
+			continue
+		}
+		rule.CanaryBackend = backend
+		rule.CanaryWeight = weight
+		c.addUseBackendRule(key, rule, frontend)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+// testServiceEndpoints adds a service with endpoints on port 80 of namespace
+func (c *HAProxyController) testServiceEndpoints(namespace, name string) {
+	c.testService(namespace, name, nil)
+	c.cfg.GetNamespace(namespace).Endpoints[name] = &Endpoints{
+		Namespace: namespace,
+		Ports:     &EndpointPorts{{Name: "http", Protocol: "TCP", Port: 8080}},
+		Addresses: &EndpointIPs{},
+		Status:    ADDED,
+	}
+}
+
+func TestHandleCanary(t *testing.T) {
+	tests := []struct {
+		name           string
+		canaryService  string
+		weight         string
+		crossNamespace bool
+		canaryRule     string
+		err            bool
+	}{
+		{
+			name:          "same namespace",
+			canaryService: "app-canary:80",
+			weight:        "20",
+			canaryRule:    "use_backend prod-app-canary-80 if { req.hdr(host) -i example.com } { path_beg / } { rand(100) lt 20 }",
+		},
+		{
+			name:           "cross namespace allowed",
+			canaryService:  "canary/app:80",
+			weight:         "10",
+			crossNamespace: true,
+			canaryRule:     "use_backend canary-app-80 if { req.hdr(host) -i example.com } { path_beg / } { rand(100) lt 10 }",
+		},
+		{
+			name:          "cross namespace denied by default",
+			canaryService: "canary/app:80",
+			weight:        "10",
+			err:           true,
+		},
+		{
+			name:          "invalid weight",
+			canaryService: "app-canary:80",
+			weight:        "101",
+			err:           true,
+		},
+		{
+			name:          "missing port",
+			canaryService: "app-canary",
+			weight:        "10",
+			err:           true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.canaryPaths = map[string]*IngressPath{}
+			c.osArgs.AllowCrossNamespace = test.crossNamespace
+			namespace := c.cfg.GetNamespace("prod")
+			c.testServiceEndpoints("prod", "app")
+			c.testServiceEndpoints("prod", "app-canary")
+			c.testServiceEndpoints("canary", "app")
+			ingress := c.testIngress("prod", "a")
+			ingress.Annotations = MapStringW{
+				"canary-service": &StringW{Value: test.canaryService, Status: ADDED},
+				"canary-weight":  &StringW{Value: test.weight, Status: ADDED},
+			}
+			rule, path := testRule(ingress, "example.com", "/", "app")
+			var err error
+			config := c.testSync(t, func() {
+				if _, errPath := c.handlePath(namespace, ingress, rule, path); errPath != nil {
+					t.Fatal(errPath)
+				}
+				_, err = c.handleCanary(namespace, ingress, rule, path)
+				c.refreshBackendSwitching()
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			mainRule := "use_backend prod-app-80 if { req.hdr(host) -i example.com } { path_beg / }"
+			if !testSectionHas(config, "frontend http", mainRule) {
+				t.Errorf("expected '%s':\n%s", mainRule, config)
+			}
+			if test.err {
+				if strings.Contains(config, "rand(100)") {
+					t.Errorf("expected no canary rule:\n%s", config)
+				}
+				return
+			}
+			lines := testSection(config, "frontend http")
+			canaryIndex, mainIndex := -1, -1
+			for i, line := range lines {
+				switch line {
+				case test.canaryRule:
+					canaryIndex = i
+				case mainRule:
+					mainIndex = i
+				}
+			}
+			if canaryIndex == -1 || canaryIndex > mainIndex {
+				t.Errorf("expected '%s' before main rule:\n%s", test.canaryRule, config)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// runtimeSocketTimeout bounds a runtime command sent by runtimeCommand
+const runtimeSocketTimeout = 10 * time.Second
+
+// storeCert writes a certificate file. When it replaces a certificate loaded by HAProxy
+// (secret rotation), the certificate is updated via runtime API so that no reload is
+// needed, unless "ssl-certificate-update" annotation is "reload" or the update fails.
+func (c *HAProxyController) storeCert(filename string, key, crt []byte) (reloadRequested bool, err error) {
+	_, errStat := os.Stat(filename)
+	rotation := errStat == nil
+	if err = c.writeCert(filename, key, crt); err != nil {
+		return false, err
+	}
+	if !rotation || !c.runtimeCertUpdate() {
+		return true, nil
+	}
+	if err = c.setRuntimeCert(filename); err != nil {
+		log.Printf("certificate %s not updated via runtime API, reloading: %s", filename, err)
+		return true, nil
+	}
+	log.Printf("certificate %s updated via runtime API", filename)
+	return false, nil
+}
+
+// runtimeCertUpdate tells if certificates are updated via runtime API, "set ssl cert"
+// requires HAProxy 2.1
+func (c *HAProxyController) runtimeCertUpdate() bool {
+	if c.osArgs.Test {
+		return false
+	}
+	annUpdate, err := GetValueFromAnnotations("ssl-certificate-update", c.cfg.ConfigMap.Annotations)
+	if err == nil {
+		switch annUpdate.Value {
+		case "runtime":
+		case "reload":
+			return false
+		default:
+			if annUpdate.Status != EMPTY {
+				log.Printf("ssl-certificate-update annotation: incorrect value '%s', expected 'runtime' or 'reload'", annUpdate.Value)
+			}
+		}
+	}
+	return c.featureSupported("set-ssl-cert")
+}
+
+// setRuntimeCert loads the content of a certificate file in HAProxy with a
+// "set ssl cert" transaction, which is committed or aborted on failure
+func (c *HAProxyController) setRuntimeCert(filename string) error {
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	// an empty line ends the payload of a runtime command
+	lines := []string{}
+	for _, line := range strings.Split(string(content), "\n") {
+		if strings.TrimSpace(line) != "" {
+			lines = append(lines, line)
+		}
+	}
+	response, err := runtimeCommand(c.runtimeSocket, "set ssl cert "+filename, strings.Join(lines, "\n"))
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(response, "Transaction created") && !strings.Contains(response, "Transaction updated") {
+		return fmt.Errorf("set ssl cert: %s", strings.TrimSpace(response))
+	}
+	response, err = runtimeCommand(c.runtimeSocket, "commit ssl cert "+filename, "")
+	if err == nil && !strings.Contains(response, "Success!") {
+		err = fmt.Errorf("commit ssl cert: %s", strings.TrimSpace(response))
+	}
+	if err != nil {
+		if _, errAbort := runtimeCommand(c.runtimeSocket, "abort ssl cert "+filename, ""); errAbort != nil {
+			log.Println(errAbort)
+		}
+		return err
+	}
+	return nil
+}
+
+// runtimeCommand sends a command to HAProxy runtime socket and returns the response,
+// payload is sent after the command with "<<" syntax, which the runtime client does not support
+func runtimeCommand(socket, command, payload string) (string, error) {
+	conn, err := net.DialTimeout("unix", socket, runtimeSocketTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(runtimeSocketTimeout)); err != nil {
+		return "", err
+	}
+	if payload != "" {
+		command = fmt.Sprintf("%s <<\n%s\n", command, payload)
+	}
+	if _, err = conn.Write([]byte(command + "\n")); err != nil {
+		return "", err
+	}
+	response, err := ioutil.ReadAll(conn)
+	return string(response), err
+}
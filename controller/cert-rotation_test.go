@@ -0,0 +1,105 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// testCertRotation stores a certificate then rotates it with a fake runtime socket answering
+// "commit ssl cert" with commitAnswer, it returns the reload request and the runtime commands
+func testCertRotation(t *testing.T, setup func(c *HAProxyController), commitAnswer string) (reload bool, commands []string) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.Test = false
+	dir, err := ioutil.TempDir("", "haproxy-ingress-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "default_web_tls.pem")
+	runtime := newTestRuntime(t, func(command string) string {
+		switch {
+		case strings.HasPrefix(command, "set ssl cert"):
+			return "Transaction created for certificate " + filename + "!\n"
+		case strings.HasPrefix(command, "commit ssl cert"):
+			return commitAnswer
+		}
+		return "\n"
+	})
+	defer runtime.close()
+	c.runtimeSocket = runtime.socket
+	if setup != nil {
+		setup(c)
+	}
+	// new certificates require a reload
+	if reload, err = c.storeCert(filename, []byte("old-key\n"), []byte("old-crt\n")); err != nil || !reload {
+		t.Fatalf("expected reload for new certificate, got %t %v", reload, err)
+	}
+	if len(runtime.received()) != 0 {
+		t.Fatalf("expected no runtime command for new certificate, got %v", runtime.received())
+	}
+	if reload, err = c.storeCert(filename, []byte("new-key\n"), []byte("new-crt\n")); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := ioutil.ReadFile(filename); string(content) != "new-key\nnew-crt\n" {
+		t.Errorf("expected rotated certificate to be written, got '%s'", content)
+	}
+	for _, command := range runtime.received() {
+		commands = append(commands, strings.Replace(command, filename, "<cert>", -1))
+	}
+	return reload, commands
+}
+
+func TestCertRotationRuntime(t *testing.T) {
+	reload, commands := testCertRotation(t, nil, "Committing <cert>\nSuccess!\n")
+	if reload {
+		t.Error("expected no reload")
+	}
+	expected := "set ssl cert <cert> <<\nnew-key\nnew-crt,commit ssl cert <cert>"
+	if strings.Join(commands, ",") != expected {
+		t.Errorf("expected %q, got %q", expected, commands)
+	}
+}
+
+func TestCertRotationRuntimeFailed(t *testing.T) {
+	reload, commands := testCertRotation(t, nil, "unable to load the certificate\n")
+	if !reload {
+		t.Error("expected reload")
+	}
+	if len(commands) != 3 || commands[2] != "abort ssl cert <cert>" {
+		t.Errorf("expected transaction to be aborted, got %q", commands)
+	}
+}
+
+func TestCertRotationReload(t *testing.T) {
+	for name, setup := range map[string]func(c *HAProxyController){
+		"reload annotation": func(c *HAProxyController) {
+			c.cfg.ConfigMap.Annotations["ssl-certificate-update"] = &StringW{Value: "reload", Status: ADDED}
+		},
+		"HAProxy 2.0": func(c *HAProxyController) {
+			c.haproxyVersion = HAProxyVersion{2, 0, 0}
+		},
+	} {
+		reload, commands := testCertRotation(t, setup, "Success!\n")
+		if !reload || len(commands) != 0 {
+			t.Errorf("%s: expected reload without runtime command, got %t %q", name, reload, commands)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// handleConfigInclude checks the raw configuration file given with --config-include.
+// On change, the file is validated together with generated configuration and,
+// if valid, installed as HAProxyIncludeCFG which is loaded by HAProxy after haproxy.cfg.
+// An invalid file is rejected and the previously installed one is kept.
+func (c *HAProxyController) handleConfigInclude() (needsReload bool) {
+	if c.osArgs.ConfigInclude == "" {
+		return false
+	}
+	content, err := ioutil.ReadFile(c.osArgs.ConfigInclude)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			utils.LogErr(err)
+			return false
+		}
+		// source removed, remove installed copy
+		c.configIncludeChecked = nil
+		if _, err = os.Stat(HAProxyIncludeCFG); err == nil {
+			utils.LogErr(os.Remove(HAProxyIncludeCFG))
+			log.Printf("config include '%s' removed", c.osArgs.ConfigInclude)
+			return true
+		}
+		return false
+	}
+	if c.configIncludeChecked != nil && bytes.Equal(content, c.configIncludeChecked) {
+		return false
+	}
+	c.configIncludeChecked = content
+	installed, err := ioutil.ReadFile(HAProxyIncludeCFG)
+	if err == nil && bytes.Equal(content, installed) {
+		return false
+	}
+
+	candidate := HAProxyIncludeCFG + ".new"
+	if err = ioutil.WriteFile(candidate, content, 0644); err != nil {
+		utils.LogErr(err)
+		return false
+	}
+	if err = validateConfig(c.haproxyBinary(), HAProxyCFG, candidate); err != nil {
+		utils.LogErr(fmt.Errorf("config include '%s' rejected: %s", c.osArgs.ConfigInclude, err))
+		utils.LogErr(os.Remove(candidate))
+		return false
+	}
+	if err = os.Rename(candidate, HAProxyIncludeCFG); err != nil {
+		utils.LogErr(err)
+		return false
+	}
+	log.Printf("config include '%s' updated", c.osArgs.ConfigInclude)
+	return true
+}
+
+// validateConfig runs HAProxy binary in check mode with given configuration files.
+func validateConfig(binary string, files ...string) error {
+	args := []string{"-c"}
+	for _, file := range files {
+		args = append(args, "-f", file)
+	}
+	cmd := exec.Command(binary, args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %s", err, stderr.String())
+	}
+	return nil
+}
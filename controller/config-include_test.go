@@ -0,0 +1,95 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHandleConfigInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-include")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg, include := HAProxyCFG, HAProxyIncludeCFG
+	HAProxyCFG, HAProxyIncludeCFG = filepath.Join(dir, "haproxy.cfg"), filepath.Join(dir, "include.cfg")
+	defer func() { HAProxyCFG, HAProxyIncludeCFG = cfg, include }()
+	// fake HAProxy binary rejecting includes which contain "invalid", args are "-c -f <cfg> -f <include>"
+	binary := filepath.Join(dir, "haproxy")
+	if err = ioutil.WriteFile(binary, []byte("#!/bin/sh\n! grep -q invalid \"$5\"\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	source := filepath.Join(dir, "source.cfg")
+	c := &HAProxyController{}
+	c.osArgs.HAProxyBinary = binary
+	c.osArgs.ConfigInclude = source
+
+	if c.handleConfigInclude() {
+		t.Error("expected no reload without source file")
+	}
+	write := func(content string) {
+		if err := ioutil.WriteFile(source, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	installed := func() string {
+		content, _ := ioutil.ReadFile(HAProxyIncludeCFG)
+		return string(content)
+	}
+
+	write("backend extra\n  mode http\n")
+	if !c.handleConfigInclude() {
+		t.Error("expected reload on new include")
+	}
+	if installed() != "backend extra\n  mode http\n" {
+		t.Errorf("unexpected installed include '%s'", installed())
+	}
+	if c.handleConfigInclude() {
+		t.Error("expected no reload when unchanged")
+	}
+
+	write("backend extra\n  invalid directive\n")
+	if c.handleConfigInclude() {
+		t.Error("expected invalid include to be rejected")
+	}
+	if installed() != "backend extra\n  mode http\n" {
+		t.Errorf("expected previous include to be kept, got '%s'", installed())
+	}
+	if _, err = os.Stat(HAProxyIncludeCFG + ".new"); !os.IsNotExist(err) {
+		t.Error("expected rejected candidate to be removed")
+	}
+
+	write("backend extra\n  mode tcp\n")
+	if !c.handleConfigInclude() {
+		t.Error("expected reload on valid change")
+	}
+	if installed() != "backend extra\n  mode tcp\n" {
+		t.Errorf("unexpected installed include '%s'", installed())
+	}
+
+	if err = os.Remove(source); err != nil {
+		t.Fatal(err)
+	}
+	if !c.handleConfigInclude() {
+		t.Error("expected reload when source is removed")
+	}
+	if _, err = os.Stat(HAProxyIncludeCFG); !os.IsNotExist(err) {
+		t.Error("expected installed include to be removed")
+	}
+}
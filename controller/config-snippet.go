@@ -0,0 +1,92 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+)
+
+// handleFrontendConfigSnippet injects "frontend-config-snippet" lines into HTTP frontends.
+func (c *HAProxyController) handleFrontendConfigSnippet() (needsReload bool, err error) {
+	annSnippet, errAnn := GetValueFromAnnotations("frontend-config-snippet", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annSnippet.Status == EMPTY {
+		return false, nil
+	}
+	lines := []string{}
+	if annSnippet.Status != DELETED {
+		if lines, err = parseConfigSnippet(parser.Frontends, annSnippet.Value); err != nil {
+			return false, fmt.Errorf("frontend-config-snippet annotation: %s", err)
+		}
+	}
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		reload, errSet := c.sectionSnippetSet(parser.Frontends, frontend, lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
+// handleBackendConfigSnippet injects "backend-config-snippet" lines into the backend of a service.
+func (c *HAProxyController) handleBackendConfigSnippet(ingress *Ingress, service *Service, backendName string, newBackend bool) (needsReload bool, err error) {
+	annSnippet, errAnn := GetValueFromAnnotations("backend-config-snippet", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || (annSnippet.Status == EMPTY && !newBackend) {
+		return false, nil
+	}
+	lines := []string{}
+	if annSnippet.Status != DELETED {
+		if lines, err = parseConfigSnippet(parser.Backends, annSnippet.Value); err != nil {
+			return false, fmt.Errorf("backend-config-snippet annotation: %s", err)
+		}
+	}
+	return c.sectionSnippetSet(parser.Backends, backendName, lines)
+}
+
+// parseConfigSnippet splits a snippet into lines and checks that none of them
+// is a directive managed by the controller (ie modeled by config-parser),
+// since only unprocessed lines can be tracked and removed later.
+// Remaining errors are caught by HAProxy validation when the transaction is committed.
+func parseConfigSnippet(section parser.Section, snippet string) ([]string, error) {
+	lines := []string{}
+	for _, line := range strings.Split(snippet, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	p := parser.Parser{}
+	if err := p.ParseData(fmt.Sprintf("%s snippet\n%s", section, strings.Join(lines, "\n"))); err != nil {
+		return nil, err
+	}
+	unprocessed := map[string]struct{}{}
+	if data, err := p.Get(section, "snippet", ""); err == nil {
+		for _, line := range data.([]types.UnProcessed) {
+			unprocessed[line.Value] = struct{}{}
+		}
+	}
+	for _, line := range lines {
+		if _, ok := unprocessed[line]; !ok {
+			return nil, fmt.Errorf("'%s' is not allowed in %s config snippet", line, section)
+		}
+	}
+	return lines, nil
+}
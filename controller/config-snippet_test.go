@@ -0,0 +1,170 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+func TestParseConfigSnippet(t *testing.T) {
+	tests := []struct {
+		section  parser.Section
+		snippet  string
+		expected []string
+		err      bool
+	}{
+		{parser.Backends, "http-reuse always\n# comment\n\n  http-after-response set-header X-Backend app ", []string{"http-reuse always", "http-after-response set-header X-Backend app"}, false},
+		{parser.Frontends, "option http-no-delay", []string{"option http-no-delay"}, false},
+		// directives managed by the controller
+		{parser.Backends, "balance leastconn", nil, true},
+		{parser.Backends, "http-reuse always\nhttp-request set-header X-Backend app", nil, true},
+		{parser.Frontends, "default_backend app", nil, true},
+	}
+	for _, test := range tests {
+		lines, err := parseConfigSnippet(test.section, test.snippet)
+		if (err != nil) != test.err {
+			t.Errorf("%q: expected error %t, got %v", test.snippet, test.err, err)
+		}
+		if !reflect.DeepEqual(lines, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.snippet, test.expected, lines)
+		}
+	}
+}
+
+func TestHandleBackendConfigSnippet(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: MapStringW{"backend-config-snippet": &StringW{Value: "http-reuse always", Status: ADDED}}}
+	config := c.testSync(t, func() {
+		reload, err := c.handleBackendConfigSnippet(ingress, service, "default-app-80", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reload {
+			t.Error("expected reload")
+		}
+	})
+	if !testSectionHas(config, "backend default-app-80", "http-reuse always") {
+		t.Errorf("expected snippet in backend:\n%s", config)
+	}
+	if testSectionHas(config, "backend default_backend", "http-reuse always") {
+		t.Errorf("expected snippet only in backend of the service:\n%s", config)
+	}
+
+	service.Annotations["backend-config-snippet"] = &StringW{Value: "balance leastconn", Status: MODIFIED}
+	var err error
+	config = c.testSync(t, func() {
+		_, err = c.handleBackendConfigSnippet(ingress, service, "default-app-80", false)
+	})
+	if err == nil {
+		t.Error("expected error for a directive managed by the controller")
+	}
+	if strings.Contains(config, "leastconn") {
+		t.Errorf("expected rejected snippet not to be applied:\n%s", config)
+	}
+
+	service.Annotations["backend-config-snippet"].Status = DELETED
+	config = c.testSync(t, func() {
+		if _, err := c.handleBackendConfigSnippet(ingress, service, "default-app-80", false); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "http-reuse") || strings.Contains(config, configSnippetBegin) {
+		t.Errorf("expected snippet removed:\n%s", config)
+	}
+}
+
+func TestHandleFrontendConfigSnippet(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{"frontend-config-snippet": &StringW{Value: "option http-no-delay", Status: ADDED}}
+	config := c.testSync(t, func() {
+		if _, err := c.handleFrontendConfigSnippet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		if !testSectionHas(config, frontend, "option http-no-delay") {
+			t.Errorf("%s: expected snippet:\n%s", frontend, config)
+		}
+	}
+	if testSectionHas(config, "frontend stats", "option http-no-delay") {
+		t.Errorf("expected snippet only in HTTP frontends:\n%s", config)
+	}
+}
+
+func TestConfigSnippetManagedDirectives(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{
+		"backend-config-snippet": "http-reuse safe\noption splice-auto",
+		"backend-protocol":       "h2",
+		"grpc-balance":           "stream",
+	})}
+	c.cfg.ConfigMap.Annotations = MapStringW{
+		"frontend-config-snippet": &StringW{Value: "option http-no-delay", Status: ADDED},
+		"http-no-delay":           &StringW{Value: "false", Status: ADDED},
+	}
+	handle := func() string {
+		return c.testSync(t, func() {
+			if _, err := c.handleBackendConfigSnippet(ingress, service, "default-app-80", true); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handleBackendProtocol(ingress, service, "default-app-80"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handleBackendGRPCBalance(ingress, service, "default-app-80"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handleBackendOption("splice-auto", ingress, service, "default-app-80", true); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handleFrontendConfigSnippet(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handleFrontendOption("http-no-delay", FrontendHTTP); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+	// lines set by annotations of the controller do not replace the ones of the snippet
+	config := handle()
+	expected := []string{configSnippetBegin, "http-reuse safe", "option splice-auto", configSnippetEnd, "http-reuse always"}
+	got := []string{}
+	for _, line := range testSection(config, "backend default-app-80") {
+		if strings.HasPrefix(line, "http-reuse") || strings.HasPrefix(line, "option splice-auto") || strings.HasPrefix(line, "# config-snippet") {
+			got = append(got, line)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(got, "\n"))
+	}
+	if !testSectionHas(config, "frontend http", "option http-no-delay") {
+		t.Errorf("expected snippet line in frontend:\n%s", config)
+	}
+
+	// removing the annotation of the controller keeps the snippet
+	service.Annotations["grpc-balance"].Status = DELETED
+	config = handle()
+	if testSectionHas(config, "backend default-app-80", "http-reuse always") || !testSectionHas(config, "backend default-app-80", "http-reuse safe") {
+		t.Errorf("expected only snippet http-reuse:\n%s", config)
+	}
+}
@@ -28,6 +28,18 @@ const (
 	HTTP_REDIRECT = "http-redirect"
 	//nolint
 	REQUEST_CAPTURE = "request-capture"
+	//nolint
+	X_FORWARDED_PROTO = "x-forwarded-proto"
+	//nolint
+	CONN_RATE_LIMIT = "conn-rate-limit"
+	//nolint
+	EXPECT_PROXY = "expect-proxy"
+	//nolint
+	TLS_RATE_LIMIT = "tls-handshake-rate-limit"
+	//nolint
+	JWT_CAPTURE = "jwt-capture"
+	//nolint
+	INGRESS_MATCH = "ingress-match"
 )
 
 //Configuration represents k8s state
@@ -49,7 +61,10 @@ type Configuration struct {
 	TCPRequestsStatus      Status
 	BackendSwitchingRules  map[string]UseBackendRules
 	BackendSwitchingStatus map[string]struct{}
+	IngressMatchRules      map[string][]models.HTTPRequestRule
 	RateLimitingEnabled    bool
+	DefaultBackends        map[string]string
+	DefaultBackendDisabled bool
 	HTTPS                  bool
 	SSLRedirect            bool
 	SSLPassthrough         bool
@@ -94,20 +109,26 @@ func (c *Configuration) Init(osArgs utils.OSArgs, api *clientnative.HAProxyClien
 
 	c.Namespace = make(map[string]*Namespace)
 	c.SSLRedirect = false
+	c.DefaultBackends = map[string]string{FrontendHTTP: fallbackBackend, FrontendHTTPS: fallbackBackend}
 
 	c.HTTPRequests = map[string][]models.HTTPRequestRule{}
 	c.HTTPRequests[RATE_LIMIT] = []models.HTTPRequestRule{}
 	c.HTTPRequests[HTTP_REDIRECT] = []models.HTTPRequestRule{}
 	c.HTTPRequests[REQUEST_CAPTURE] = []models.HTTPRequestRule{}
+	c.HTTPRequests[JWT_CAPTURE] = []models.HTTPRequestRule{}
 	c.HTTPRequestsStatus = EMPTY
 
 	c.TCPRequests = map[string][]models.TCPRequestRule{}
 	c.TCPRequests[RATE_LIMIT] = []models.TCPRequestRule{}
+	c.TCPRequests[CONN_RATE_LIMIT] = []models.TCPRequestRule{}
+	c.TCPRequests[EXPECT_PROXY] = []models.TCPRequestRule{}
+	c.TCPRequests[TLS_RATE_LIMIT] = []models.TCPRequestRule{}
 	c.TCPRequests[REQUEST_CAPTURE] = []models.TCPRequestRule{}
 	c.TCPRequestsStatus = EMPTY
 
 	c.BackendSwitchingRules = make(map[string]UseBackendRules)
 	c.BackendSwitchingStatus = make(map[string]struct{})
+	c.IngressMatchRules = make(map[string][]models.HTTPRequestRule)
 	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS, FrontendSSL} {
 		c.BackendSwitchingRules[frontend] = UseBackendRules{}
 	}
@@ -126,13 +147,14 @@ func (c *Configuration) GetNamespace(name string) *Namespace {
 //NewNamespace returns new initialized Namespace
 func (c *Configuration) NewNamespace(name string) *Namespace {
 	newNamespace := &Namespace{
-		Name:      name,
-		Relevant:  c.IsRelevantNamespace(name),
-		Endpoints: make(map[string]*Endpoints),
-		Services:  make(map[string]*Service),
-		Ingresses: make(map[string]*Ingress),
-		Secret:    make(map[string]*Secret),
-		Status:    ADDED,
+		Name:       name,
+		Relevant:   c.IsRelevantNamespace(name),
+		Endpoints:  make(map[string]*Endpoints),
+		Services:   make(map[string]*Service),
+		Ingresses:  make(map[string]*Ingress),
+		Secret:     make(map[string]*Secret),
+		ConfigMaps: make(map[string]*ConfigMap),
+		Status:     ADDED,
 	}
 	c.Namespace[name] = newNamespace
 	return newNamespace
@@ -0,0 +1,69 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// connRateLimitBackend holds the stick-table with the single entry tracking
+// connections of all HTTP frontends
+const connRateLimitBackend = "GlobalConnRate"
+
+// handleConnRateLimit rejects new connections of HTTP frontends once the rate of connections
+// accepted by all sources exceeds --frontend-conn-rate-limit per second.
+// Unlike rate-limit annotation, connections are not tracked by source address.
+func (c *HAProxyController) handleConnRateLimit() (needsReload bool, err error) {
+	limit := c.osArgs.FrontendConnRateLimit
+	if limit <= 0 {
+		return false, nil
+	}
+	if _, errGet := c.backendGet(connRateLimitBackend); errGet != nil {
+		err = c.backendCreate(models.Backend{
+			Name: connRateLimitBackend,
+			StickTable: &models.BackendStickTable{
+				Type:  "integer",
+				Size:  utils.PtrInt64(1),
+				Store: "conn_rate(1s)",
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		needsReload = true
+	}
+	if len(c.cfg.TCPRequests[CONN_RATE_LIMIT]) == 0 {
+		c.cfg.TCPRequests[CONN_RATE_LIMIT] = []models.TCPRequestRule{
+			{
+				ID:     utils.PtrInt64(0),
+				Type:   "connection",
+				Action: "track-sc1 int(1) table " + connRateLimitBackend,
+			},
+			{
+				ID:       utils.PtrInt64(0),
+				Type:     "connection",
+				Action:   "reject",
+				Cond:     "if",
+				CondTest: fmt.Sprintf("{ sc1_conn_rate(%s) gt %d }", connRateLimitBackend, limit),
+			},
+		}
+		c.cfg.TCPRequestsStatus = MODIFIED
+		needsReload = true
+	}
+	return needsReload, nil
+}
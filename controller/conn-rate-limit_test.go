@@ -0,0 +1,71 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleConnRateLimit(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.FrontendConnRateLimit = 500
+	config := c.testSync(t, func() {
+		if reload, err := c.handleConnRateLimit(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.requestsTCPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "backend GlobalConnRate", "stick-table type integer size 1 store conn_rate(1s)") {
+		t.Errorf("expected stick-table in backend GlobalConnRate:\n%s", config)
+	}
+	expected := []string{
+		"tcp-request connection track-sc1 int(1) table GlobalConnRate",
+		"tcp-request connection reject if { sc1_conn_rate(GlobalConnRate) gt 500 }",
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		got := []string{}
+		for _, line := range testSection(config, frontend) {
+			if strings.HasPrefix(line, "tcp-request") {
+				got = append(got, line)
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", frontend, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+	// rules are generated once
+	c.testSync(t, func() {
+		if reload, _ := c.handleConnRateLimit(); reload {
+			t.Error("expected no reload")
+		}
+	})
+}
+
+func TestHandleConnRateLimitDisabled(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleConnRateLimit(); reload {
+			t.Error("expected no reload")
+		}
+	})
+	if strings.Contains(config, "GlobalConnRate") {
+		t.Errorf("expected no connection rate limit:\n%s", config)
+	}
+}
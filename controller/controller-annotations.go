@@ -20,6 +20,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/go-openapi/strfmt"
 	parser "github.com/haproxytech/config-parser/v2"
 	"github.com/haproxytech/config-parser/v2/types"
 	"github.com/haproxytech/kubernetes-ingress/controller/backend"
@@ -82,7 +83,11 @@ func (c *HAProxyController) handleSSLPassthrough(ingress *Ingress, service *Serv
 	}
 	updateBackendSwitching = false
 	annSSLPassthrough, _ := GetValueFromAnnotations("ssl-passthrough", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	annFrontendMode, _ := GetValueFromAnnotations("frontend-mode", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	status := annSSLPassthrough.Status
+	if annFrontendMode != nil && annFrontendMode.Status != EMPTY {
+		status = annFrontendMode.Status
+	}
 	if status == EMPTY {
 		status = path.Status
 	}
@@ -92,6 +97,15 @@ func (c *HAProxyController) handleSSLPassthrough(ingress *Ingress, service *Serv
 			utils.LogErr(fmt.Errorf("ssl-passthrough annotation: %s", err))
 			return updateBackendSwitching
 		}
+		// frontend-mode explicitly selects the frontend, ssl-passthrough is then ignored
+		if annFrontendMode != nil && annFrontendMode.Status != DELETED {
+			var mode Mode
+			if err = mode.UnmarshalFlag(annFrontendMode.Value); err != nil {
+				utils.LogErr(fmt.Errorf("frontend-mode annotation: %s", err))
+				return updateBackendSwitching
+			}
+			enabled = mode == ModeTCP
+		}
 		if enabled {
 			if !path.IsSSLPassthrough {
 				path.IsSSLPassthrough = true
@@ -117,10 +131,29 @@ func (c *HAProxyController) handleBackendAnnotations(ingress *Ingress, service *
 	backendAnnotations["abortonclose"], _ = GetValueFromAnnotations("abortonclose", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	backendAnnotations["cookie-persistence"], _ = GetValueFromAnnotations("cookie-persistence", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	backendAnnotations["load-balance"], _ = GetValueFromAnnotations("load-balance", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	backendAnnotations["retries"], _ = GetValueFromAnnotations("retries", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	backendAnnotations["sticky-fallback"], _ = GetValueFromAnnotations("sticky-fallback", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	backendAnnotations["timeout-check"], _ = GetValueFromAnnotations("timeout-check", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	// check-timeout is an alias of timeout-check named as other check annotations, it takes precedence
+	if annCheckTimeout, _ := GetValueFromAnnotations("check-timeout", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations); annCheckTimeout != nil {
+		annTimeoutCheck := backendAnnotations["timeout-check"]
+		switch {
+		case annCheckTimeout.Status != DELETED:
+			backendAnnotations["timeout-check"] = annCheckTimeout
+		case annTimeoutCheck != nil && annTimeoutCheck.Status != DELETED:
+			// alias removed, timeout-check value is applied again
+			backendAnnotations["timeout-check"] = &StringW{Value: annTimeoutCheck.Value, Status: MODIFIED}
+		default:
+			backendAnnotations["timeout-check"] = annCheckTimeout
+		}
+	}
 	if backend.Mode == "http" {
-		backendAnnotations["check-http"], _ = GetValueFromAnnotations("check-http", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 		backendAnnotations["forwarded-for"], _ = GetValueFromAnnotations("forwarded-for", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		annCheckHTTP, _ := GetValueFromAnnotations("check-http", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		annCheckHost, _ := GetValueFromAnnotations("check-host", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		if c.handleBackendHttpchk(ingress, &backend, annCheckHTTP, annCheckHost, newBackend) {
+			activeAnnotations = true
+		}
 	}
 
 	// The DELETED status of an annotation is handled explicitly
@@ -130,53 +163,58 @@ func (c *HAProxyController) handleBackendAnnotations(ingress *Ingress, service *
 			continue
 		}
 		if v.Status != EMPTY || newBackend {
+			// a rejected value is reverted, other annotations are still applied
+			saved := backend
+			var err error
 			switch k {
 			case "abortonclose":
-				if err := backend.UpdateAbortOnClose(v.Value); err != nil {
-					utils.LogErr(err)
-					continue
-				}
-				activeAnnotations = true
-			case "check-http":
-				if v.Status == DELETED && !newBackend {
-					backend.Httpchk = nil
-				} else if err := backend.UpdateHttpchk(v.Value); err != nil {
-					utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
-					continue
-				}
-				activeAnnotations = true
+				err = backend.UpdateAbortOnClose(v.Value)
 			case "cookie-persistence":
 				if v.Status == DELETED && !newBackend {
 					backend.Cookie = nil
 				} else {
 					cookie := c.handleCookieAnnotations(ingress, service)
-					if err := backend.UpdateCookie(&cookie); err != nil {
-						utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
-						continue
-					}
+					err = backend.UpdateCookie(&cookie)
 				}
-				activeAnnotations = true
 			case "forwarded-for":
-				if err := backend.UpdateForwardfor(v.Value); err != nil {
-					utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
-					continue
-				}
-				activeAnnotations = true
+				err = backend.UpdateForwardfor(v.Value)
 			case "load-balance":
-				if err := backend.UpdateBalance(v.Value); err != nil {
-					utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
-					continue
+				value := v.Value
+				if override, ok := c.balanceOverrides[backend.Name]; ok {
+					// backend shared by ingresses with different values
+					value = override
+				}
+				err = backend.UpdateBalance(value)
+			case "retries":
+				if v.Status == DELETED && !newBackend {
+					backend.Retries = nil
+				} else {
+					err = backend.UpdateRetries(v.Value)
+				}
+			case "sticky-fallback":
+				if v.Status == DELETED && !newBackend {
+					// defaults section redispatches
+					backend.Redispatch = nil
+				} else {
+					err = backend.UpdateRedispatch(v.Value)
 				}
-				activeAnnotations = true
 			case "timeout-check":
 				if v.Status == DELETED && !newBackend {
 					backend.CheckTimeout = nil
-				} else if err := backend.UpdateCheckTimeout(v.Value); err != nil {
-					utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
-					continue
+				} else {
+					err = backend.UpdateCheckTimeout(v.Value)
 				}
-				activeAnnotations = true
 			}
+			if err == nil {
+				model := models.Backend(backend)
+				err = model.Validate(strfmt.Default)
+			}
+			if err != nil {
+				c.annotationErr(ingress, fmt.Errorf("%s annotation: %s", k, err))
+				backend = saved
+				continue
+			}
+			activeAnnotations = true
 		}
 	}
 	*backendModel = models.Backend(backend)
@@ -184,12 +222,76 @@ func (c *HAProxyController) handleBackendAnnotations(ingress *Ingress, service *
 
 }
 
+// handleBackendHttpchk sets "option httpchk" of a backend from check-http and check-host
+// annotations, handled together since the Host header is part of the httpchk line.
+// check-host alone checks "GET /". A rejected value keeps the previous httpchk.
+func (c *HAProxyController) handleBackendHttpchk(ingress *Ingress, b *backend.Backend, checkHTTP, checkHost *StringW, newBackend bool) (active bool) {
+	updated := newBackend
+	for _, ann := range []*StringW{checkHTTP, checkHost} {
+		updated = updated || (ann != nil && ann.Status != EMPTY)
+	}
+	if !updated {
+		return false
+	}
+	saved := b.Httpchk
+	httpSet := checkHTTP != nil && checkHTTP.Status != DELETED
+	hostSet := checkHost != nil && checkHost.Status != DELETED
+	var err error
+	switch {
+	case httpSet:
+		if err = b.UpdateHttpchk(checkHTTP.Value); err != nil {
+			err = fmt.Errorf("check-http annotation: %s", err)
+		}
+	case hostSet:
+		err = b.UpdateHttpchk("GET /")
+	default:
+		b.Httpchk = nil
+	}
+	if err == nil && hostSet {
+		if err = b.UpdateHttpchkHost(checkHost.Value); err != nil {
+			err = fmt.Errorf("check-host annotation: %s", err)
+		}
+	}
+	if err != nil {
+		c.annotationErr(ingress, err)
+		b.Httpchk = saved
+		return false
+	}
+	return true
+}
+
+// backendOptions are boolean "option <name>" directives configurable by annotations of the same name
+var backendOptions = []string{"log-health-checks", "allbackups", "nolinger", "h1-case-adjust-bogus-server", "prefer-last-server",
+	"splice-auto", "splice-request", "splice-response"}
+
+// handleBackendOption enables or disables "option <name>" in the backend of a service
+// according to the annotation of the same name.
+func (c *HAProxyController) handleBackendOption(option string, ingress *Ingress, service *Service, backendName string, newBackend bool) (needsReload bool, err error) {
+	annOption, errAnn := GetValueFromAnnotations(option, service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || (annOption.Status == EMPTY && !newBackend) {
+		return false, nil
+	}
+	lines := []string{}
+	if annOption.Status != DELETED {
+		enabled, errBool := utils.GetBoolValue(annOption.Value, option)
+		if errBool != nil {
+			return false, fmt.Errorf("%s annotation: %s", option, errBool)
+		}
+		if enabled {
+			lines = append(lines, "option "+option)
+		}
+	}
+	return c.sectionDirectivesSet(parser.Backends, backendName, "option "+option, lines)
+}
+
 // Update server with annotations values.
 func (c *HAProxyController) handleServerAnnotations(ingress *Ingress, service *Service, serverModel *models.Server) (activeAnnotations bool) {
 	activeAnnotations = false
 	server := server.Server(*serverModel)
 
-	serverAnnotations := make(map[string]*StringW, 5)
+	serverAnnotations := make(map[string]*StringW, 7)
+	serverAnnotations["agent-check-port"], _ = GetValueFromAnnotations("agent-check-port", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	serverAnnotations["agent-check-interval"], _ = GetValueFromAnnotations("agent-check-interval", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	serverAnnotations["cookie-persistence"], _ = GetValueFromAnnotations("cookie-persistence", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	serverAnnotations["check"], _ = GetValueFromAnnotations("check", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	serverAnnotations["check-interval"], _ = GetValueFromAnnotations("check-interval", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
@@ -204,6 +306,23 @@ func (c *HAProxyController) handleServerAnnotations(ingress *Ingress, service *S
 		}
 		if v.Status != EMPTY {
 			switch k {
+			case "agent-check-port":
+				if v.Status == DELETED {
+					server.AgentCheck = ""
+					server.AgentPort = nil
+				} else if err := server.UpdateAgentCheck(v.Value); err != nil {
+					utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
+					continue
+				}
+				activeAnnotations = true
+			case "agent-check-interval":
+				if v.Status == DELETED {
+					server.AgentInter = nil
+				} else if err := server.UpdateAgentInter(v.Value); err != nil {
+					utils.LogErr(fmt.Errorf("%s annotation: %s", k, err))
+					continue
+				}
+				activeAnnotations = true
 			case "cookie-persistence":
 				if v.Status == DELETED {
 					server.Cookie = ""
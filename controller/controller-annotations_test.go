@@ -0,0 +1,557 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/backend"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+func TestBalanceRandomDraws(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: MapStringW{"load-balance": &StringW{Value: "random(2)", Status: ADDED}}}
+	config := c.testSync(t, func() {
+		b, err := c.backendGet("default-app-80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !c.handleBackendAnnotations(ingress, service, &b, false) {
+			t.Fatal("expected active annotations")
+		}
+		if err = c.backendEdit(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "backend default-app-80", "balance random(2)") {
+		t.Errorf("expected balance random(2):\n%s", config)
+	}
+}
+
+func TestFrontendMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		host     string
+		frontend string
+		rule     string
+		err      bool
+	}{
+		{"tcp", "tcp", "example.com", "frontend ssl", "use_backend default-app-80 if { req_ssl_sni -i example.com }", false},
+		{"http", "http", "example.com", "frontend https", "use_backend default-app-80 if { req.hdr(host) -i example.com } { path_beg / }", false},
+		{"tcp without host", "tcp", "", "frontend ssl", "", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			namespace := c.cfg.GetNamespace("default")
+			ingress := c.testIngress("default", "a")
+			ingress.Annotations = MapStringW{"frontend-mode": &StringW{Value: test.mode, Status: ADDED}}
+			c.testService("default", "app", nil)
+			rule, path := testRule(ingress, test.host, "/", "app")
+			var err error
+			config := c.testSync(t, func() {
+				if errCreate := c.frontendCreate(models.Frontend{Name: FrontendSSL, Mode: "tcp"}); errCreate != nil {
+					t.Fatal(errCreate)
+				}
+				_, err = c.handlePath(namespace, ingress, rule, path)
+				c.refreshBackendSwitching()
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.err {
+				if strings.Contains(config, "use_backend default-app-80") {
+					t.Errorf("expected no use_backend rule:\n%s", config)
+				}
+				return
+			}
+			if !testSectionHas(config, test.frontend, test.rule) {
+				t.Errorf("expected '%s' in %s:\n%s", test.rule, test.frontend, config)
+			}
+			for _, frontend := range []string{"frontend ssl", "frontend http", "frontend https"} {
+				if frontend == test.frontend || (test.mode == "http" && frontend == "frontend http") {
+					continue
+				}
+				for _, line := range testSection(config, frontend) {
+					if strings.HasPrefix(line, "use_backend default-app-80") {
+						t.Errorf("unexpected rule in %s: %s", frontend, line)
+					}
+				}
+			}
+			mode := "mode " + test.mode
+			if !testSectionHas(config, "backend default-app-80", mode) {
+				t.Errorf("expected backend %s:\n%s", mode, config)
+			}
+		})
+	}
+}
+
+func TestHandleBackendHttpchk(t *testing.T) {
+	previous := &models.Httpchk{Method: "HEAD", URI: "/ready"}
+	tests := []struct {
+		name      string
+		checkHTTP *StringW
+		checkHost *StringW
+		expected  *models.Httpchk
+		active    bool
+	}{
+		{
+			name:      "check-http",
+			checkHTTP: &StringW{Value: "/health", Status: ADDED},
+			expected:  &models.Httpchk{URI: "/health"},
+			active:    true,
+		},
+		{
+			name:      "check-http and check-host",
+			checkHTTP: &StringW{Value: "/health", Status: ADDED},
+			checkHost: &StringW{Value: "example.com", Status: ADDED},
+			expected:  &models.Httpchk{Method: "GET", URI: "/health", Version: `HTTP/1.1\r\nHost:example.com`},
+			active:    true,
+		},
+		{
+			name:      "check-host only",
+			checkHost: &StringW{Value: "example.com", Status: ADDED},
+			expected:  &models.Httpchk{Method: "GET", URI: "/", Version: `HTTP/1.1\r\nHost:example.com`},
+			active:    true,
+		},
+		{
+			name:      "check-host removed",
+			checkHTTP: &StringW{Value: "/health", Status: EMPTY},
+			checkHost: &StringW{Value: "example.com", Status: DELETED},
+			expected:  &models.Httpchk{URI: "/health"},
+			active:    true,
+		},
+		{
+			name:      "both removed",
+			checkHTTP: &StringW{Value: "/health", Status: DELETED},
+			checkHost: &StringW{Value: "example.com", Status: DELETED},
+			active:    true,
+		},
+		{
+			name:      "unchanged",
+			checkHTTP: &StringW{Value: "/health", Status: EMPTY},
+			expected:  previous,
+		},
+		{
+			name:      "invalid check-host keeps previous httpchk",
+			checkHTTP: &StringW{Value: "/health", Status: EMPTY},
+			checkHost: &StringW{Value: "example.com x", Status: ADDED},
+			expected:  previous,
+		},
+		{
+			name:      "invalid check-http keeps previous httpchk",
+			checkHTTP: &StringW{Value: " ", Status: MODIFIED},
+			expected:  previous,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &HAProxyController{}
+			httpchk := *previous
+			b := backend.Backend{Httpchk: &httpchk}
+			active := c.handleBackendHttpchk(nil, &b, test.checkHTTP, test.checkHost, false)
+			if active != test.active {
+				t.Errorf("expected active %t, got %t", test.active, active)
+			}
+			switch {
+			case test.expected == nil && b.Httpchk != nil:
+				t.Errorf("expected no httpchk, got %+v", *b.Httpchk)
+			case test.expected != nil && (b.Httpchk == nil || *b.Httpchk != *test.expected):
+				t.Errorf("expected %+v, got %+v", *test.expected, b.Httpchk)
+			}
+		})
+	}
+}
+
+func TestStickyFallback(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		// a down sticky server fails over to another one
+		{"redispatch", "option redispatch -1"},
+		// the request fails: no other server is tried
+		{"error", "no option redispatch"},
+		{"invalid", ""},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: MapStringW{"sticky-fallback": &StringW{Value: test.value, Status: ADDED}}}
+			config := c.testSync(t, func() {
+				b, err := c.backendGet("default-app-80")
+				if err != nil {
+					t.Fatal(err)
+				}
+				c.handleBackendAnnotations(ingress, service, &b, false)
+				if err = c.backendEdit(b); err != nil {
+					t.Fatal(err)
+				}
+			})
+			redispatch := ""
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.Contains(line, "option redispatch") {
+					redispatch = line
+				}
+			}
+			if redispatch != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, redispatch)
+			}
+		})
+	}
+}
+
+func TestCheckTimeout(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: MapStringW{}}
+	checkTimeout := func() string {
+		config := c.testSync(t, func() {
+			b, err := c.backendGet("default-app-80")
+			if err != nil {
+				t.Fatal(err)
+			}
+			c.handleBackendAnnotations(ingress, service, &b, false)
+			if err = c.backendEdit(b); err != nil {
+				t.Fatal(err)
+			}
+		})
+		for _, line := range testSection(config, "backend default-app-80") {
+			if strings.HasPrefix(line, "timeout check") {
+				return line
+			}
+		}
+		return ""
+	}
+	steps := []struct {
+		name        string
+		annotations map[string]*StringW
+		expected    string
+	}{
+		{
+			name:        "check-timeout",
+			annotations: map[string]*StringW{"check-timeout": {Value: "10s", Status: ADDED}},
+			expected:    "timeout check 10000",
+		},
+		{
+			name: "check-timeout takes precedence",
+			annotations: map[string]*StringW{
+				"check-timeout": {Value: "10s", Status: EMPTY},
+				"timeout-check": {Value: "3s", Status: ADDED},
+			},
+			expected: "timeout check 10000",
+		},
+		{
+			name: "timeout-check applied when check-timeout is removed",
+			annotations: map[string]*StringW{
+				"check-timeout": {Value: "10s", Status: DELETED},
+				"timeout-check": {Value: "3s", Status: EMPTY},
+			},
+			expected: "timeout check 3000",
+		},
+		{
+			name:        "removed",
+			annotations: map[string]*StringW{"timeout-check": {Value: "3s", Status: DELETED}},
+		},
+	}
+	for _, step := range steps {
+		service.Annotations = step.annotations
+		if got := checkTimeout(); got != step.expected {
+			t.Errorf("%s: expected '%s', got '%s'", step.name, step.expected, got)
+		}
+	}
+}
+
+func TestBalanceConflicts(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	SetDefaultAnnotation("default-backend-service", "/")
+	defer delete(defaultAnnotationValues, "default-backend-service")
+	c.testService("default", "app", nil)
+	for name, balance := range map[string]string{"b": "roundrobin", "a": "leastconn", "c": "roundrobin"} {
+		ingress := c.testIngress("default", name)
+		ingress.Annotations["load-balance"] = &StringW{Value: balance, Status: ADDED}
+		testRule(ingress, name+".example.com", "/", "app")
+	}
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	c.detectBalanceConflicts()
+	c.detectBalanceConflicts()
+	log.SetOutput(os.Stderr)
+	// value of the first ingress in <namespace>/<name> order is used
+	if override := c.balanceOverrides["default-app-80"]; override != "leastconn" {
+		t.Errorf("expected leastconn, got '%s'", override)
+	}
+	warning := "WARNING: conflicting load-balance annotations for backend default-app-80 (default/a: leastconn, default/b: roundrobin, default/c: roundrobin), using 'leastconn' of ingress default/a"
+	if count := strings.Count(output.String(), warning); count != 1 {
+		t.Errorf("expected warning once, got:\n%s", output.String())
+	}
+
+	ingress := &Ingress{Annotations: MapStringW{"load-balance": &StringW{Value: "roundrobin", Status: ADDED}}}
+	config := c.testSync(t, func() {
+		b, err := c.backendGet("default-app-80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.handleBackendAnnotations(ingress, c.cfg.Namespace["default"].Services["app"], &b, false)
+		if err = c.backendEdit(b); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "backend default-app-80", "balance leastconn") {
+		t.Errorf("expected balance leastconn:\n%s", config)
+	}
+
+	// no more conflict
+	c.cfg.Namespace["default"].Ingresses["a"].Annotations["load-balance"].Value = "roundrobin"
+	c.detectBalanceConflicts()
+	if _, ok := c.balanceOverrides["default-app-80"]; ok {
+		t.Error("expected no override")
+	}
+}
+
+func TestDefaultServiceAnnotations(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	SetDefaultAnnotation("default-backend-service", "default/app")
+	defer delete(defaultAnnotationValues, "default-backend-service")
+	c.testServiceEndpoints("default", "app")
+	service := c.cfg.GetNamespace("default").Services["app"]
+	service.Status = ADDED
+	for annotation, value := range map[string]string{"load-balance": "leastconn", "timeout-check": "5s"} {
+		service.Annotations[annotation] = &StringW{Value: value, Status: ADDED}
+	}
+	config := c.testSync(t, func() {
+		if _, err := c.handleDefaultService(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	for _, line := range []string{"balance leastconn", "timeout check 5000"} {
+		if !testSectionHas(config, "backend default-app-80", line) {
+			t.Errorf("expected '%s' in default backend:\n%s", line, config)
+		}
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		if !testSectionHas(config, frontend, "default_backend default-app-80") {
+			t.Errorf("%s: expected default_backend default-app-80:\n%s", frontend, config)
+		}
+	}
+}
+
+func TestDefaultServiceBalanceConflicts(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	SetDefaultAnnotation("default-backend-service", "default/app")
+	defer delete(defaultAnnotationValues, "default-backend-service")
+	c.testServiceEndpoints("default", "app")
+	service := c.cfg.GetNamespace("default").Services["app"]
+	service.Status = ADDED
+	ingress := c.testIngress("default", "web")
+	ingress.Annotations["load-balance"] = &StringW{Value: "leastconn", Status: ADDED}
+	testRule(ingress, "example.com", "/", "app")
+	var output bytes.Buffer
+	log.SetOutput(&output)
+	c.detectBalanceConflicts()
+	log.SetOutput(os.Stderr)
+	// the default service has the lowest priority, it follows the ingress
+	if override := c.balanceOverrides["default-app-80"]; override != "leastconn" {
+		t.Errorf("expected leastconn of ingress, got '%s'", override)
+	}
+	if strings.Contains(output.String(), "conflicting load-balance") {
+		t.Errorf("expected no warning, got:\n%s", output.String())
+	}
+	// backend created for the default service before the ingress is processed
+	config := c.testSync(t, func() {
+		if err := c.backendDelete("default-app-80"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.handleDefaultService(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "backend default-app-80", "balance leastconn") {
+		t.Errorf("expected balance leastconn in default backend:\n%s", config)
+	}
+
+	// conflicts between ingresses do not list the default service
+	other := c.testIngress("default", "api")
+	other.Annotations["load-balance"] = &StringW{Value: "source", Status: ADDED}
+	testRule(other, "api.example.com", "/", "app")
+	log.SetOutput(&output)
+	c.detectBalanceConflicts()
+	log.SetOutput(os.Stderr)
+	warning := "WARNING: conflicting load-balance annotations for backend default-app-80 (default/api: source, default/web: leastconn), using 'source' of ingress default/api"
+	if !strings.Contains(output.String(), warning) {
+		t.Errorf("expected warning '%s', got:\n%s", warning, output.String())
+	}
+
+	// annotation of the service applies to all its backends, there is no conflict
+	service.Annotations["load-balance"] = &StringW{Value: "leastconn", Status: ADDED}
+	c.detectBalanceConflicts()
+	if override, ok := c.balanceOverrides["default-app-80"]; ok {
+		t.Errorf("expected no override, got '%s'", override)
+	}
+}
+
+func TestAgentCheck(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+	}{
+		{"port", map[string]string{"agent-check-port": "9999"}, "server SRV_1 10.0.0.1:8080 agent-check agent-port 9999 weight 128"},
+		{"port and interval", map[string]string{"agent-check-port": "9999", "agent-check-interval": "5s"}, "server SRV_1 10.0.0.1:8080 agent-check agent-port 9999 agent-inter 5000 weight 128"},
+		{"invalid port", map[string]string{"agent-check-port": "99999"}, "server SRV_1 10.0.0.1:8080 weight 128"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: MapStringW{}}
+			for name, value := range test.annotations {
+				service.Annotations[name] = &StringW{Value: value, Status: ADDED}
+			}
+			port := int64(8080)
+			server := models.Server{Name: "SRV_1", Address: "10.0.0.1", Port: &port, Weight: utils.PtrInt64(128)}
+			c.handleServerAnnotations(ingress, service, &server)
+			// weights reported by the agent are applied by HAProxy at runtime
+			config := c.testSync(t, func() {
+				if err := c.backendServerCreate("default-app-80", server); err != nil {
+					t.Fatal(err)
+				}
+			})
+			if !testSectionHas(config, "backend default-app-80", test.expected) {
+				t.Errorf("expected '%s':\n%s", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestHandleBackendOptionToggle(t *testing.T) {
+	for _, option := range []string{"nolinger", "prefer-last-server", "splice-auto", "splice-request", "splice-response"} {
+		t.Run(option, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: MapStringW{option: &StringW{Value: "true", Status: ADDED}}}
+			config := c.testSync(t, func() {
+				if reload, err := c.handleBackendOption(option, ingress, service, "default-app-80", false); err != nil || !reload {
+					t.Errorf("expected reload, got %t %v", reload, err)
+				}
+			})
+			if !testSectionHas(config, "backend default-app-80", "option "+option) {
+				t.Errorf("expected option %s:\n%s", option, config)
+			}
+
+			service.Annotations[option] = &StringW{Value: "false", Status: MODIFIED}
+			config = c.testSync(t, func() {
+				if reload, err := c.handleBackendOption(option, ingress, service, "default-app-80", false); err != nil || !reload {
+					t.Errorf("expected reload, got %t %v", reload, err)
+				}
+			})
+			if strings.Contains(config, option) {
+				t.Errorf("expected no option %s:\n%s", option, config)
+			}
+
+			service.Annotations[option] = &StringW{Value: "maybe", Status: MODIFIED}
+			c.testSync(t, func() {
+				if _, err := c.handleBackendOption(option, ingress, service, "default-app-80", false); err == nil {
+					t.Error("expected error")
+				}
+			})
+		})
+	}
+}
+
+func TestHandleBackendAnnotationsRevert(t *testing.T) {
+	retries := int64(3)
+	cookieType := "insert"
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		active      bool
+		check       func(b models.Backend) bool
+	}{
+		{
+			name:        "invalid cookie",
+			annotations: map[string]string{"cookie-persistence": "new", "cookie-type": "bogus"},
+			check:       func(b models.Backend) bool { return b.Cookie != nil && *b.Cookie.Name == "old" },
+		},
+		{
+			name:        "valid cookie",
+			annotations: map[string]string{"cookie-persistence": "new", "cookie-type": "insert"},
+			active:      true,
+			check:       func(b models.Backend) bool { return b.Cookie != nil && *b.Cookie.Name == "new" },
+		},
+		{
+			name:        "invalid retries",
+			annotations: map[string]string{"retries": "-1"},
+			check:       func(b models.Backend) bool { return b.Retries != nil && *b.Retries == 3 },
+		},
+		{
+			name:        "invalid balance",
+			annotations: map[string]string{"load-balance": "random(0)"},
+			check:       func(b models.Backend) bool { return *b.Balance.Algorithm == "roundrobin" },
+		},
+		{
+			name:        "invalid value does not revert other annotations",
+			annotations: map[string]string{"load-balance": "bogus", "retries": "5"},
+			active:      true,
+			check: func(b models.Backend) bool {
+				return *b.Balance.Algorithm == "roundrobin" && b.Retries != nil && *b.Retries == 5
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &HAProxyController{}
+			c.cfg.ConfigMap = &ConfigMap{Annotations: MapStringW{}}
+			name := "old"
+			algorithm := "roundrobin"
+			b := models.Backend{
+				Name:    "default-app-80",
+				Mode:    "http",
+				Balance: &models.Balance{Algorithm: &algorithm},
+				Cookie:  &models.Cookie{Name: &name, Type: cookieType},
+				Retries: &retries,
+			}
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			for _, ann := range service.Annotations {
+				ann.Status = MODIFIED
+			}
+			if active := c.handleBackendAnnotations(ingress, service, &b, false); active != test.active {
+				t.Errorf("expected active %t, got %t", test.active, active)
+			}
+			if !test.check(b) {
+				t.Errorf("unexpected backend %+v", b)
+			}
+		})
+	}
+}
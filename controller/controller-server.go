@@ -0,0 +1,89 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/metrics"
+)
+
+// runControllerServer serves controller endpoints (metrics, ready, force-reload, route) on --controller-port
+func (c *HAProxyController) runControllerServer() {
+	address := fmt.Sprintf(":%d", c.osArgs.ControllerPort)
+	log.Printf("Controller server listening on %s", address)
+	log.Println(http.ListenAndServe(address, c.controllerServerMux()))
+}
+
+// controllerServerMux registers controller endpoints, force-reload and route ones
+// only when their token is set
+func (c *HAProxyController) controllerServerMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+	mux.HandleFunc("/ready", c.handleReady)
+	if c.osArgs.ForceReloadToken != "" {
+		mux.HandleFunc("/force-reload", c.handleForceReload)
+	}
+	if c.osArgs.RouteQueryToken != "" {
+		mux.HandleFunc("/route", c.handleRouteQuery)
+	}
+	return mux
+}
+
+// handleForceReload requests a full resync and reload of HAProxy regardless of
+// change detection, request must be a POST with "Authorization: Bearer <token>"
+func (c *HAProxyController) handleForceReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, c.osArgs.ForceReloadToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	c.eventChan <- SyncDataEvent{SyncType: FORCE_RELOAD}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handleReady answers 503 while HAProxy process is not running, to be used as readiness probe
+func (c *HAProxyController) handleReady(w http.ResponseWriter, r *http.Request) {
+	if !c.haproxyUp() {
+		http.Error(w, "HAProxy is not running", http.StatusServiceUnavailable)
+		return
+	}
+	fmt.Fprintln(w, "ok")
+}
+
+// authorized checks "Authorization: Bearer <token>" header against the token of the endpoint,
+// --force-reload-token or --route-query-token
+func authorized(r *http.Request, expected string) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return expected != "" && subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// forceResync marks generated rules as modified so they are recreated
+// from the desired state and HAProxy is reloaded on next update
+func (c *HAProxyController) forceResync() {
+	for frontend := range c.cfg.BackendSwitchingRules {
+		c.cfg.BackendSwitchingStatus[frontend] = struct{}{}
+	}
+	c.cfg.HTTPRequestsStatus = MODIFIED
+	c.cfg.TCPRequestsStatus = MODIFIED
+	c.forceReload = true
+}
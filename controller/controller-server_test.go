@@ -0,0 +1,123 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandleForceReload(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		header string
+		status int
+	}{
+		{name: "authorized", method: "POST", header: "Bearer secret", status: http.StatusAccepted},
+		{name: "wrong token", method: "POST", header: "Bearer other", status: http.StatusUnauthorized},
+		{name: "no token", method: "POST", status: http.StatusUnauthorized},
+		{name: "get", method: "GET", header: "Bearer secret", status: http.StatusMethodNotAllowed},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := &HAProxyController{eventChan: make(chan SyncDataEvent, 1)}
+			c.osArgs.ForceReloadToken = "secret"
+			request := httptest.NewRequest(test.method, "/force-reload", nil)
+			if test.header != "" {
+				request.Header.Set("Authorization", test.header)
+			}
+			recorder := httptest.NewRecorder()
+			c.handleForceReload(recorder, request)
+			if recorder.Code != test.status {
+				t.Errorf("expected status %d, got %d", test.status, recorder.Code)
+			}
+			select {
+			case event := <-c.eventChan:
+				if test.status != http.StatusAccepted || event.SyncType != FORCE_RELOAD {
+					t.Errorf("unexpected event %v", event.SyncType)
+				}
+			default:
+				if test.status == http.StatusAccepted {
+					t.Error("expected FORCE_RELOAD event")
+				}
+			}
+		})
+	}
+}
+
+func TestControllerServerMux(t *testing.T) {
+	tests := []struct {
+		reloadToken string
+		routeToken  string
+		reload      bool
+		route       bool
+	}{
+		{"", "", false, false},
+		{"secret", "", true, false},
+		{"", "secret", false, true},
+		{"secret", "other", true, true},
+	}
+	for _, test := range tests {
+		c := &HAProxyController{}
+		c.osArgs.ForceReloadToken = test.reloadToken
+		c.osArgs.RouteQueryToken = test.routeToken
+		mux := c.controllerServerMux()
+		for path, expected := range map[string]bool{"/force-reload": test.reload, "/route": test.route} {
+			_, pattern := mux.Handler(httptest.NewRequest("GET", path, nil))
+			if (pattern == path) != expected {
+				t.Errorf("force-reload token '%s', route token '%s': expected %s registered %t", test.reloadToken, test.routeToken, path, expected)
+			}
+		}
+	}
+}
+
+func TestForceResyncReloads(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	SetDefaultAnnotation("default-backend-service", "/")
+	defer delete(defaultAnnotationValues, "default-backend-service")
+	runtime := newTestRuntime(t, func(command string) string { return "1\n" })
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	c.reloadEvents = newReloadEvents()
+	reloads := func() (count int) {
+		for _, command := range runtime.received() {
+			if command == "show servers state" {
+				count++
+			}
+		}
+		return count
+	}
+
+	if err := c.updateHAProxy(); err != nil {
+		t.Fatal(err)
+	}
+	initial := reloads()
+	if err := c.updateHAProxy(); err != nil {
+		t.Fatal(err)
+	}
+	if reloads() != initial {
+		t.Fatal("expected no reload without changes")
+	}
+	c.forceResync()
+	if err := c.updateHAProxy(); err != nil {
+		t.Fatal(err)
+	}
+	if reloads() != initial+1 {
+		t.Error("expected forced reload")
+	}
+}
@@ -22,11 +22,14 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	clientnative "github.com/haproxytech/client-native"
 	"github.com/haproxytech/client-native/configuration"
 	"github.com/haproxytech/client-native/runtime"
 	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
 	"github.com/haproxytech/models"
 	"k8s.io/apimachinery/pkg/watch"
@@ -42,6 +45,23 @@ type HAProxyController struct {
 	ActiveTransactionHasChanges bool
 	eventChan                   chan SyncDataEvent
 	serverlessPods              map[string]int
+	configIncludeChecked        []byte
+	forceReload                 bool
+	lastDriftCheck              time.Time
+	canaryPaths                 map[string]*IngressPath
+	reloadEvents                *reloadEvents
+	backendDefaultServers       map[string][]params.ServerOption
+	reloadsInFlight             int32
+	balanceOverrides            map[string]string
+	backupServers               map[string]map[string]models.Server
+	haproxyVersion              HAProxyVersion
+	disabledFeatures            map[string]struct{}
+	tlsConflicts                map[string]string
+	tlsSecretConflicts          map[string]string
+	annotationWarnings          annotationWarnings
+	haproxyProcess              haproxyProcess
+	haproxyDown                 int32
+	runtimeSocket               string
 }
 
 // Start initialize and run HAProxyController
@@ -76,7 +96,20 @@ func (c *HAProxyController) Start(ctx context.Context, osArgs utils.OSArgs) {
 	}
 
 	c.serverlessPods = map[string]int{}
+	c.canaryPaths = map[string]*IngressPath{}
+	c.reloadEvents = newReloadEvents()
+	c.backendDefaultServers = map[string][]params.ServerOption{}
+	c.backupServers = map[string]map[string]models.Server{}
+	c.tlsConflicts = map[string]string{}
+	c.tlsSecretConflicts = map[string]string{}
 	c.eventChan = make(chan SyncDataEvent, watch.DefaultChanSize*6)
+
+	if osArgs.ControllerPort != 0 {
+		go c.runControllerServer()
+	}
+	if !osArgs.Test {
+		go c.superviseHAProxy()
+	}
 	go c.monitorChanges()
 	<-ctx.Done()
 }
@@ -88,6 +121,10 @@ func (c *HAProxyController) HAProxyInitialize() {
 	if err != nil {
 		utils.PanicErr(err)
 	}
+	err = os.MkdirAll(crtListCertDir(), 0755)
+	if err != nil {
+		utils.PanicErr(err)
+	}
 	err = os.MkdirAll(HAProxyStateDir, 0755)
 	if err != nil {
 		utils.PanicErr(err)
@@ -96,21 +133,37 @@ func (c *HAProxyController) HAProxyInitialize() {
 	if err != nil {
 		utils.PanicErr(err)
 	}
+	err = os.MkdirAll(HAProxyErrorsDir, 0755)
+	if err != nil {
+		utils.PanicErr(err)
+	}
+	err = os.MkdirAll(HAProxyJWTDir, 0755)
+	if err != nil {
+		utils.PanicErr(err)
+	}
+
+	if !c.osArgs.Test {
+		if err = c.checkHAProxyBinary(); err != nil {
+			utils.PanicErr(err)
+		}
+	}
 
-	cmd := exec.Command("sh", "-c", "haproxy -v")
+	cmd := exec.Command(c.haproxyBinary(), "-v")
 	haproxyInfo, err := cmd.Output()
 	if err == nil {
 		log.Println("Running with ", strings.ReplaceAll(string(haproxyInfo), "\n", ""))
+		c.haproxyVersion, err = parseHAProxyVersion(string(haproxyInfo))
+		utils.LogErr(err)
 	} else {
 		log.Println(err)
 	}
 
 	log.Println("Starting HAProxy with", HAProxyCFG)
+	c.haproxyProcess = serviceProcess{pidFile: haproxyPidFile, command: func() *exec.Cmd {
+		return c.haproxyCommand(c.startCommand())
+	}}
 	if !c.osArgs.Test {
-		cmd := exec.Command("service", "haproxy", "start")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		err = cmd.Start()
+		err = c.haproxyProcess.Start()
 		if err != nil {
 			log.Println(err)
 		}
@@ -120,9 +173,10 @@ func (c *HAProxyController) HAProxyInitialize() {
 	utils.LogErr(err)
 	log.Println("Running on", hostname)
 
+	c.runtimeSocket = haproxyRuntimeSocket
 	runtimeClient := runtime.Client{}
 	err = runtimeClient.InitWithSockets(map[int]string{
-		0: "/var/run/haproxy-runtime-api.sock",
+		0: c.runtimeSocket,
 	})
 	if err != nil {
 		utils.PanicErr(err)
@@ -132,7 +186,7 @@ func (c *HAProxyController) HAProxyInitialize() {
 	err = confClient.Init(configuration.ClientParams{
 		ConfigurationFile:      HAProxyCFG,
 		PersistentTransactions: false,
-		Haproxy:                "haproxy",
+		Haproxy:                c.haproxyBinary(),
 	})
 	if err != nil {
 		utils.PanicErr(err)
@@ -142,6 +196,9 @@ func (c *HAProxyController) HAProxyInitialize() {
 		Configuration: &confClient,
 		Runtime:       &runtimeClient,
 	}
+	if err = c.waitForHAProxy(); err != nil {
+		utils.PanicErr(err)
+	}
 
 	c.cfg.Init(c.osArgs, c.NativeAPI)
 
@@ -184,10 +241,16 @@ func (c *HAProxyController) HAProxyReload() error {
 	err := c.saveServerState()
 	utils.LogErr(err)
 	if !c.osArgs.Test {
-		cmd := exec.Command("service", "haproxy", "reload")
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
+		cmd := c.haproxyCommand(c.reloadCommand())
 		err = cmd.Start()
+		if err == nil {
+			// drift checks are skipped until the reload command exits, see handleDrift
+			atomic.AddInt32(&c.reloadsInFlight, 1)
+			go func() {
+				utils.LogErr(cmd.Wait())
+				atomic.AddInt32(&c.reloadsInFlight, -1)
+			}()
+		}
 	} else {
 		err = nil
 		log.Println("HAProxy would be reloaded now")
@@ -209,6 +272,10 @@ func (c *HAProxyController) handlePath(namespace *Namespace, ingress *Ingress, r
 		return needReload, err
 	}
 
+	reload, err = c.handleBackupServers(namespace, ingress, service, backendName, newBackend)
+	utils.LogErr(err)
+	needReload = needReload || reload
+
 	endpoints, ok := namespace.Endpoints[service.Name]
 	if !ok {
 		log.Printf("No Endpoints found for service '%s'", service.Name)
@@ -233,7 +300,7 @@ func (c *HAProxyController) handleEndpointIP(namespace *Namespace, ingress *Ingr
 		Name:    ip.HAProxyName,
 		Address: ip.IP,
 		Port:    &path.TargetPort,
-		Weight:  utils.PtrInt64(128),
+		Weight:  utils.PtrInt64(defaultServerWeight),
 	}
 	if ip.Disabled {
 		server.Maintenance = "enabled"
@@ -305,20 +372,16 @@ func (c *HAProxyController) handleService(namespace *Namespace, ingress *Ingress
 			c.deleteUseBackendRule(key, FrontendSSL)
 		case path.IsDefaultBackend:
 			log.Printf("Removing default_backend %s from ingress \n", service.Name)
-			utils.LogErr(c.setDefaultBackend(""))
+			utils.LogErr(c.unsetDefaultBackend(getBackendName(namespace, service, path)))
 			needReload = true
 		default:
-			c.deleteUseBackendRule(key, FrontendHTTP, FrontendHTTPS)
+			c.deleteUseBackendRule(key, c.httpRuleFrontends(key)...)
 		}
 		return "", false, needReload, nil
 	}
 
 	// Set backendName
-	if path.ServicePortInt == 0 {
-		backendName = fmt.Sprintf("%s-%s-%s", namespace.Name, service.Name, path.ServicePortString)
-	} else {
-		backendName = fmt.Sprintf("%s-%s-%d", namespace.Name, service.Name, path.ServicePortInt)
-	}
+	backendName = getBackendName(namespace, service, path)
 
 	// Get/Create Backend
 	newBackend = false
@@ -350,32 +413,117 @@ func (c *HAProxyController) handleService(namespace *Namespace, ingress *Ingress
 		}
 		needReload = true
 	}
+	reload, errAnn := c.handleBackendConfigSnippet(ingress, service, backendName, newBackend)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	for _, option := range backendOptions {
+		reload, errAnn = c.handleBackendOption(option, ingress, service, backendName, newBackend)
+		c.annotationErr(ingress, errAnn)
+		needReload = needReload || reload
+	}
+	reload, errAnn = c.handleBackendRetryOn(ingress, service, backendName, newBackend)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendTunnelTimeout(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendSSE(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendHashKey(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendResolvers(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendErrorfile503(namespace, ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendErrorloc(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendRequestBuffering(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendStickOn(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendPriority(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendProtocol(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendGRPCBalance(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendServerHeader(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendReplaceValue(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendH2Downgrade(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendEject(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+	reload, errAnn = c.handleBackendEject5xxRate(ingress, service, backendName)
+	c.annotationErr(ingress, errAnn)
+	needReload = needReload || reload
+
+	annFrontends, _ := GetValueFromAnnotations("frontends", ingress.Annotations)
+	frontendsChanged := annFrontends != nil && annFrontends.Status != EMPTY
 
 	// No need to update BackendSwitching
-	if (status == EMPTY && !activeSSLPassthrough) || path.IsTCPService {
+	// canary rules are handled by handleCanary
+	if (status == EMPTY && !activeSSLPassthrough && !frontendsChanged) || path.IsTCPService || path.IsCanary {
 		return backendName, newBackend, needReload, nil
 	}
 
 	// Update backendSwitching
 	key := fmt.Sprintf("R%s%s%s%s", namespace.Name, ingress.Name, rule.Host, path.Path)
 	useBackendRule := UseBackendRule{
-		Host:      rule.Host,
-		Path:      path.Path,
-		Backend:   backendName,
-		Namespace: namespace.Name,
+		Host:          rule.Host,
+		Path:          path.Path,
+		Backend:       backendName,
+		Namespace:     namespace.Name,
+		Ingress:       ingress.Name,
+		EjectFallback: c.ejectFallback(ingress, service),
+	}
+	if limit, errRate := c.eject5xxRate(ingress, service); errRate == nil {
+		useBackendRule.Eject5xxRate = limit
 	}
 	switch {
 	case path.IsDefaultBackend:
 		log.Printf("Confiugring default_backend %s from ingress %s\n", service.Name, ingress.Name)
-		utils.LogErr(c.setDefaultBackend(backendName))
+		frontends, errFrontends := c.ruleFrontends(ingress)
+		c.annotationErr(ingress, errFrontends)
+		utils.LogErr(c.setDefaultBackend(backendName, frontends...))
+		utils.LogErr(c.unsetDefaultBackend(backendName, frontends...))
 		needReload = true
 	case path.IsSSLPassthrough:
+		if rule.Host == "" {
+			// TCP frontend can only route by SNI
+			c.deleteUseBackendRule(key, FrontendSSL, FrontendHTTP, FrontendHTTPS)
+			return backendName, newBackend, needReload, fmt.Errorf("ingress %s/%s: TCP mode rule for backend %s requires a host (SNI)", namespace.Name, ingress.Name, backendName)
+		}
 		c.addUseBackendRule(key, useBackendRule, FrontendSSL)
 		if activeSSLPassthrough {
 			c.deleteUseBackendRule(key, FrontendHTTP, FrontendHTTPS)
 		}
 	default:
-		c.addUseBackendRule(key, useBackendRule, FrontendHTTP, FrontendHTTPS)
+		frontends, errFrontends := c.ruleFrontends(ingress)
+		c.annotationErr(ingress, errFrontends)
+		c.addUseBackendRule(key, useBackendRule, frontends...)
+		unlisted := []string{}
+		for _, frontend := range c.httpRuleFrontends(key) {
+			if !isMember(frontends, frontend) {
+				unlisted = append(unlisted, frontend)
+			}
+		}
+		c.deleteUseBackendRule(key, unlisted...)
 		if activeSSLPassthrough {
 			c.deleteUseBackendRule(key, FrontendSSL)
 		}
@@ -0,0 +1,315 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	clientnative "github.com/haproxytech/client-native"
+	"github.com/haproxytech/client-native/configuration"
+	"github.com/haproxytech/client-native/runtime"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+const testHAProxyCFG = `global
+  daemon
+  stats socket /var/run/haproxy-runtime-api.sock level admin expose-fd listeners
+
+defaults
+  mode http
+
+frontend http
+  mode http
+  bind 0.0.0.0:80 name bind_1
+  default_backend default_backend
+
+frontend https
+  mode http
+  bind 0.0.0.0:443 name bind_1
+  default_backend default_backend
+
+frontend stats
+  mode http
+  bind *:1024
+  stats enable
+
+backend default_backend
+  mode http
+
+backend default-app-80
+  mode http
+  balance roundrobin
+`
+
+// newTestController returns a controller using a configuration client on a copy of
+// testHAProxyCFG, configuration is not checked by HAProxy which is never started.
+// cleanup removes the configuration files.
+func newTestController(t *testing.T) (c *HAProxyController, cleanup func()) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// server state is saved on reload
+	stateDir := HAProxyStateDir
+	HAProxyStateDir = dir + "/"
+	cleanup = func() {
+		HAProxyStateDir = stateDir
+		os.RemoveAll(dir)
+	}
+	cfgFile := filepath.Join(dir, "haproxy.cfg")
+	if err = ioutil.WriteFile(cfgFile, []byte(testHAProxyCFG), 0644); err != nil {
+		t.Fatal(err)
+	}
+	confClient := configuration.Client{}
+	if err = confClient.Init(configuration.ClientParams{
+		ConfigurationFile: cfgFile,
+		TransactionDir:    filepath.Join(dir, "transactions"),
+		// "true -f <file> -c" accepts any configuration
+		Haproxy: "true",
+	}); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	c = &HAProxyController{
+		osArgs:                utils.OSArgs{Test: true},
+		NativeAPI:             &clientnative.HAProxyClient{Configuration: &confClient},
+		backendDefaultServers: map[string][]params.ServerOption{},
+		backupServers:         map[string]map[string]models.Server{},
+	}
+	c.cfg.Init(c.osArgs, c.NativeAPI)
+	c.cfg.ConfigMap = &ConfigMap{Annotations: MapStringW{}}
+	return c, cleanup
+}
+
+// testSync runs f in a transaction which is committed to the configuration file,
+// as SyncData does, and returns the resulting configuration.
+func (c *HAProxyController) testSync(t *testing.T, f func()) string {
+	if err := c.apiStartTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	f()
+	if err := c.apiCommitTransaction(); err != nil {
+		t.Fatal(err)
+	}
+	c.apiDisposeTransaction()
+	data, err := ioutil.ReadFile(c.NativeAPI.Configuration.ConfigurationFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// testAnnotations returns annotations with the given values and EMPTY status
+func testAnnotations(values map[string]string) MapStringW {
+	annotations := MapStringW{}
+	for name, value := range values {
+		annotations[name] = &StringW{Value: value, Status: EMPTY}
+	}
+	return annotations
+}
+
+// testK8s makes the controller record events with a fake Kubernetes client
+func (c *HAProxyController) testK8s() *fake.Clientset {
+	client := fake.NewSimpleClientset()
+	// the fake clientset does not generate names, events would collide
+	var generated int32
+	client.PrependReactor("create", "events", func(action k8stesting.Action) (bool, k8sruntime.Object, error) {
+		event := action.(k8stesting.CreateAction).GetObject().(*corev1.Event)
+		if event.Name == "" && event.GenerateName != "" {
+			event.Name = fmt.Sprintf("%s%d", event.GenerateName, atomic.AddInt32(&generated, 1))
+		}
+		return false, nil, nil
+	})
+	c.k8s = &K8s{API: client}
+	return client
+}
+
+// testEvents waits for count events to be recorded in namespace, events are created asynchronously
+func testEvents(t *testing.T, client *fake.Clientset, namespace string, count int) []corev1.Event {
+	deadline := time.Now().Add(2 * time.Second)
+	if count == 0 {
+		time.Sleep(100 * time.Millisecond)
+	}
+	for {
+		events, err := client.CoreV1().Events(namespace).List(metav1.ListOptions{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events.Items) >= count || time.Now().After(deadline) {
+			if len(events.Items) != count {
+				t.Fatalf("expected %d events, got %d: %v", count, len(events.Items), events.Items)
+			}
+			return events.Items
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// testIngress adds an ingress to the configuration so that events can be recorded on it
+func (c *HAProxyController) testIngress(namespace, name string) *Ingress {
+	ingress := &Ingress{Namespace: namespace, Name: name, Annotations: MapStringW{}, Rules: map[string]*IngressRule{}, TLS: map[string]*IngressTLS{}}
+	c.cfg.GetNamespace(namespace).Ingresses[name] = ingress
+	return ingress
+}
+
+// testService adds a service with port 80 to namespace, its backend is "<namespace>-<name>-80"
+func (c *HAProxyController) testService(namespace, name string, annotations map[string]string) *Service {
+	service := &Service{Namespace: namespace, Name: name, Ports: []ServicePort{{Name: "http", Protocol: "TCP", Port: 80}}, Annotations: MapStringW{}}
+	for annotation, value := range annotations {
+		service.Annotations[annotation] = &StringW{Value: value, Status: ADDED}
+	}
+	c.cfg.GetNamespace(namespace).Services[name] = service
+	return service
+}
+
+// testRule adds an ingress rule routing host and path to port 80 of service
+func testRule(ingress *Ingress, host, path, service string) (*IngressRule, *IngressPath) {
+	rule, ok := ingress.Rules[host]
+	if !ok {
+		rule = &IngressRule{Host: host, Paths: map[string]*IngressPath{}, Status: ADDED}
+		ingress.Rules[host] = rule
+	}
+	ingressPath := &IngressPath{ServiceName: service, ServicePortInt: 80, Path: path, Status: ADDED}
+	rule.Paths[path] = ingressPath
+	return rule, ingressPath
+}
+
+// testSection returns the trimmed lines of the section with the given header, e.g. "backend app"
+func testSection(config, header string) []string {
+	lines := []string{}
+	inSection := false
+	for _, line := range strings.Split(config, "\n") {
+		if line != "" && line[0] != ' ' {
+			inSection = strings.TrimSpace(line) == header
+			continue
+		}
+		if inSection && strings.TrimSpace(line) != "" {
+			lines = append(lines, strings.TrimSpace(line))
+		}
+	}
+	return lines
+}
+
+// testSectionHas returns true if the section has the given line
+func testSectionHas(config, header, expected string) bool {
+	for _, line := range testSection(config, header) {
+		if line == expected {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultServerLine returns the "default-server" line of a backend.
+func defaultServerLine(t *testing.T, config, backendName string) string {
+	for _, line := range testSection(config, "backend "+backendName) {
+		if strings.HasPrefix(line, "default-server") {
+			return line
+		}
+	}
+	t.Fatalf("no default-server line in backend %s:\n%s", backendName, config)
+	return ""
+}
+
+// testRuntime is a fake HAProxy runtime API socket answering commands with answer
+type testRuntime struct {
+	socket   string
+	listener net.Listener
+	mu       sync.Mutex
+	commands []string
+}
+
+// newTestRuntime listens on a unix socket in a temporary directory, a command is a line,
+// or a line ending with "<<" followed by a payload terminated by an empty line.
+// The "set severity-output number;" prefix of client-native is removed from commands.
+func newTestRuntime(t *testing.T, answer func(command string) string) *testRuntime {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-runtime")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &testRuntime{socket: filepath.Join(dir, "runtime.sock")}
+	if r.listener, err = net.Listen("unix", r.socket); err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	go func() {
+		defer os.RemoveAll(dir)
+		for {
+			conn, errAccept := r.listener.Accept()
+			if errAccept != nil {
+				return
+			}
+			command := readTestCommand(bufio.NewReader(conn))
+			r.mu.Lock()
+			r.commands = append(r.commands, command)
+			r.mu.Unlock()
+			conn.Write([]byte(answer(command)))
+			conn.Close()
+		}
+	}()
+	return r
+}
+
+func readTestCommand(reader *bufio.Reader) string {
+	line, _ := reader.ReadString('\n')
+	command := strings.TrimPrefix(strings.TrimSuffix(line, "\n"), "set severity-output number;")
+	if !strings.HasSuffix(command, "<<") {
+		return command
+	}
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil || line == "\n" {
+			return command
+		}
+		command += "\n" + strings.TrimSuffix(line, "\n")
+	}
+}
+
+// client returns a runtime client of the fake socket
+func (r *testRuntime) client(t *testing.T) *runtime.Client {
+	client := &runtime.Client{}
+	if err := client.InitWithSockets(map[int]string{0: r.socket}); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// received returns the commands received so far
+func (r *testRuntime) received() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string{}, r.commands...)
+}
+
+func (r *testRuntime) close() {
+	r.listener.Close()
+}
@@ -0,0 +1,39 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+)
+
+// handleContstats sets "option contstats" in defaults section with --contstats flag, so that
+// traffic counters of long lived sessions (e.g. WebSocket, downloads) are updated continuously
+// instead of at session end, giving accurate rates in stats page and metrics.
+func (c *HAProxyController) handleContstats() (needsReload bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	_, errGet := config.Get(parser.Defaults, parser.DefaultSectionName, "option contstats")
+	if (errGet == nil) == c.osArgs.Contstats {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	if c.osArgs.Contstats {
+		return true, config.Set(parser.Defaults, parser.DefaultSectionName, "option contstats", types.SimpleOption{})
+	}
+	return true, config.Set(parser.Defaults, parser.DefaultSectionName, "option contstats", nil)
+}
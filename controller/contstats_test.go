@@ -0,0 +1,58 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleContstats(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	config := c.testSync(t, func() {
+		if reload, err := c.handleContstats(); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "option contstats") {
+		t.Errorf("expected no option contstats by default:\n%s", config)
+	}
+
+	c.osArgs.Contstats = true
+	config = c.testSync(t, func() {
+		if reload, err := c.handleContstats(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if !testSectionHas(config, "defaults", "option contstats") {
+		t.Errorf("expected option contstats in defaults:\n%s", config)
+	}
+	c.testSync(t, func() {
+		if reload, err := c.handleContstats(); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+
+	c.osArgs.Contstats = false
+	config = c.testSync(t, func() {
+		if reload, err := c.handleContstats(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "option contstats") {
+		t.Errorf("expected option contstats to be removed:\n%s", config)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// crtListCertDir holds certificates with options, they are not in HAProxyCertDir
+// to be loaded only once, via the crt-list
+func crtListCertDir() string {
+	return path.Join(HAProxyCertDir, "crt-list")
+}
+
+// crtListFile is the crt-list of HTTPS binds, outside of HAProxyCertDir
+// since all files of that directory are loaded as certificates
+func crtListFile() string {
+	return path.Join(path.Dir(path.Clean(HAProxyCertDir)), "crt-list")
+}
+
+// handleCrtList writes certificates with options (ie "alpn" ingress annotation) in the
+// crt-list, so hosts of a same HTTPS frontend can advertise different ALPN protocols,
+// and adds the crt-list to HTTPS binds when it is not empty.
+func (c *HAProxyController) handleCrtList(usedCerts map[string]certOptions) (needsReload bool, err error) {
+	certDir := crtListCertDir()
+	if err = os.MkdirAll(certDir, 0755); err != nil {
+		return false, err
+	}
+	utils.LogErr(c.cleanCertDir(certDir, usedCerts))
+
+	files := []string{}
+	for filename, options := range usedCerts {
+		if options != (certOptions{}) {
+			files = append(files, filename)
+		}
+	}
+	sortCrtListFiles(files)
+	var content bytes.Buffer
+	for _, filename := range files {
+		options := usedCerts[filename]
+		if strings.ContainsAny(options.Alpn, " \t[]") {
+			utils.LogErr(fmt.Errorf("alpn annotation: incorrect value '%s' for %s", options.Alpn, filename))
+			continue
+		}
+		fmt.Fprintf(&content, "%s [alpn %s]\n", filename, options.Alpn)
+	}
+	if current, errRead := ioutil.ReadFile(crtListFile()); errRead != nil || !bytes.Equal(current, content.Bytes()) {
+		if err = ioutil.WriteFile(crtListFile(), content.Bytes(), 0644); err != nil {
+			return false, err
+		}
+		needsReload = content.Len() > 0 || errRead == nil
+	}
+	crtList := ""
+	if content.Len() > 0 {
+		crtList = crtListFile()
+	}
+	reload, err := c.setBindsCrtList(FrontendHTTPS, crtList)
+	return needsReload || reload, err
+}
+
+// sortCrtListFiles lists certificates with wildcard names after the other ones.
+// HAProxy prefers an exact SNI match over a wildcard one, but when several certificates
+// hold the same name the first one wins, so a certificate for "a.example.com" is
+// preferred over one for "*.example.com" that also lists "a.example.com".
+func sortCrtListFiles(files []string) {
+	wildcard := make(map[string]bool, len(files))
+	for _, filename := range files {
+		wildcard[filename] = certHasWildcard(filename)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if wildcard[files[i]] != wildcard[files[j]] {
+			return !wildcard[files[i]]
+		}
+		return files[i] < files[j]
+	})
+}
+
+// certHasWildcard returns true if the first certificate of a PEM file has a wildcard name
+func certHasWildcard(filename string) bool {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return false
+	}
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, errParse := x509.ParseCertificate(block.Bytes)
+		if errParse != nil {
+			return false
+		}
+		for _, name := range append(cert.DNSNames, cert.Subject.CommonName) {
+			if strings.HasPrefix(name, "*.") {
+				return true
+			}
+		}
+		return false
+	}
+	return false
+}
+
+// setBindsCrtList sets or removes "crt-list" on ssl binds of a frontend.
+// crt-list is not part of bind model, so it is set via the parser and
+// again after binds are edited (ssl offload/passthrough switch).
+func (c *HAProxyController) setBindsCrtList(frontend string, crtList string) (changed bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	data, err := config.Get(parser.Frontends, frontend, "bind")
+	if err != nil {
+		return false, nil
+	}
+	binds := data.([]types.Bind)
+	for i, bind := range binds {
+		ssl := false
+		current := ""
+		bindParams := make([]params.BindOption, 0, len(bind.Params)+1)
+		for _, param := range bind.Params {
+			switch p := param.(type) {
+			case *params.BindOptionWord:
+				ssl = ssl || p.Name == "ssl"
+			case *params.BindOptionValue:
+				if p.Name == "crt-list" {
+					current = p.Value
+					continue
+				}
+			}
+			bindParams = append(bindParams, param)
+		}
+		wanted := crtList
+		if !ssl {
+			wanted = ""
+		}
+		if current == wanted {
+			continue
+		}
+		if wanted != "" {
+			bindParams = append(bindParams, &params.BindOptionValue{Name: "crt-list", Value: wanted})
+		}
+		binds[i].Params = bindParams
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	return true, config.Set(parser.Frontends, frontend, "bind", binds)
+}
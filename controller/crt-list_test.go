@@ -0,0 +1,177 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/config-parser/v2/types"
+)
+
+func TestHandleCrtList(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certDir := HAProxyCertDir
+	HAProxyCertDir = path.Join(dir, "certs") + "/"
+	defer func() { HAProxyCertDir = certDir }()
+	if err = os.MkdirAll(crtListCertDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	secret := Secret{
+		Namespace: "default",
+		Name:      "tls",
+		Data:      map[string][]byte{"tls.key": []byte("key"), "tls.crt": []byte("crt")},
+	}
+	ingressH2 := Ingress{Namespace: "default", Name: "grpc", Annotations: MapStringW{"alpn": &StringW{Value: "h2,http/1.1", Status: ADDED}}}
+	ingressH1 := Ingress{Namespace: "default", Name: "legacy", Annotations: MapStringW{"alpn": &StringW{Value: "http/1.1", Status: ADDED}}}
+	ingress := Ingress{Namespace: "default", Name: "web", Annotations: MapStringW{}}
+	config := c.testSync(t, func() {
+		configuration, errCfg := c.ActiveConfiguration()
+		if errCfg != nil {
+			t.Fatal(errCfg)
+		}
+		if errCfg = configuration.Set(parser.Frontends, FrontendHTTPS, "bind", []types.Bind{{
+			Path:   "0.0.0.0:443",
+			Params: []params.BindOption{&params.BindOptionValue{Name: "name", Value: "bind_1"}, &params.BindOptionWord{Name: "ssl"}, &params.BindOptionValue{Name: "crt", Value: HAProxyCertDir}},
+		}}); errCfg != nil {
+			t.Fatal(errCfg)
+		}
+		usedCerts := map[string]certOptions{}
+		for _, i := range []Ingress{ingressH2, ingressH1, ingress} {
+			c.handleSecret(i, secret, true, usedCerts)
+		}
+		if reload, errList := c.handleCrtList(usedCerts); errList != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, errList)
+		}
+	})
+	content, err := ioutil.ReadFile(crtListFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := path.Join(crtListCertDir(), "default_grpc_tls.pem") + " [alpn h2,http/1.1]\n" +
+		path.Join(crtListCertDir(), "default_legacy_tls.pem") + " [alpn http/1.1]\n"
+	if string(content) != expected {
+		t.Errorf("expected crt-list:\n%s\ngot:\n%s", expected, content)
+	}
+	// certificates without options are loaded from the certificate directory
+	if _, err = os.Stat(path.Join(HAProxyCertDir, "default_web_tls.pem")); err != nil {
+		t.Error(err)
+	}
+	bind := "bind 0.0.0.0:443 name bind_1 ssl crt " + HAProxyCertDir + " crt-list " + crtListFile()
+	if !testSectionHas(config, "frontend https", bind) {
+		t.Errorf("expected '%s':\n%s", bind, config)
+	}
+
+	// no more certificates with options
+	config = c.testSync(t, func() {
+		usedCerts := map[string]certOptions{}
+		c.handleSecret(ingress, secret, false, usedCerts)
+		if reload, errList := c.handleCrtList(usedCerts); errList != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, errList)
+		}
+	})
+	if testSectionHas(config, "frontend https", bind) {
+		t.Errorf("expected no crt-list:\n%s", config)
+	}
+	if _, err = os.Stat(path.Join(crtListCertDir(), "default_grpc_tls.pem")); !os.IsNotExist(err) {
+		t.Errorf("expected certificate to be removed, got %v", err)
+	}
+}
+
+// testCertPEM returns a self signed certificate with the given names and its key
+func testCertPEM(t *testing.T, names ...string) (crt, key []byte) {
+	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: names[0]},
+		DNSNames:     names,
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &privateKey.PublicKey, privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer})
+}
+
+func TestHandleCrtListWildcardLast(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certDir := HAProxyCertDir
+	HAProxyCertDir = path.Join(dir, "certs") + "/"
+	defer func() { HAProxyCertDir = certDir }()
+	if err = os.MkdirAll(crtListCertDir(), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	wildcardCrt, wildcardKey := testCertPEM(t, "*.example.com", "a.example.com")
+	exactCrt, exactKey := testCertPEM(t, "a.example.com")
+	// the ingress of the wildcard certificate sorts first by name
+	wildcard := Secret{Namespace: "default", Name: "wildcard", Data: map[string][]byte{"tls.key": wildcardKey, "tls.crt": wildcardCrt}}
+	exact := Secret{Namespace: "default", Name: "exact", Data: map[string][]byte{"tls.key": exactKey, "tls.crt": exactCrt}}
+	ingressWildcard := Ingress{Namespace: "default", Name: "api", Annotations: MapStringW{"alpn": &StringW{Value: "h2,http/1.1", Status: ADDED}}}
+	ingressExact := Ingress{Namespace: "default", Name: "web", Annotations: MapStringW{"alpn": &StringW{Value: "http/1.1", Status: ADDED}}}
+	c.testSync(t, func() {
+		usedCerts := map[string]certOptions{}
+		c.handleSecret(ingressWildcard, wildcard, true, usedCerts)
+		c.handleSecret(ingressExact, exact, true, usedCerts)
+		if _, errList := c.handleCrtList(usedCerts); errList != nil {
+			t.Error(errList)
+		}
+	})
+	content, err := ioutil.ReadFile(crtListFile())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a.example.com is served with its own certificate, listed first
+	expected := path.Join(crtListCertDir(), "default_web_exact.pem") + " [alpn http/1.1]\n" +
+		path.Join(crtListCertDir(), "default_api_wildcard.pem") + " [alpn h2,http/1.1]\n"
+	if string(content) != expected {
+		t.Errorf("expected crt-list:\n%s\ngot:\n%s", expected, content)
+	}
+}
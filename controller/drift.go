@@ -0,0 +1,163 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/metrics"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+var metricConfigDrifts = metrics.NewCounterVec("haproxy_ingress_config_drifts_total",
+	"Number of differences found between desired state and HAProxy runtime state.", "kind")
+
+// runtimeServer is the state of a server as reported by "show servers state"
+type runtimeServer struct {
+	address     string
+	maintenance bool
+	weight      int64
+}
+
+// handleDrift periodically compares servers of the desired state with HAProxy runtime state.
+// Servers with a different address or maintenance state are fixed via runtime API,
+// missing backends or servers (e.g. a lost reload) trigger a full resync and reload.
+// The check is postponed while a reload is in flight, the runtime API may still be
+// served by the previous HAProxy process which does not know the new servers yet.
+func (c *HAProxyController) handleDrift() {
+	if c.osArgs.DriftCheckInterval <= 0 || time.Since(c.lastDriftCheck) < c.osArgs.DriftCheckInterval {
+		return
+	}
+	if atomic.LoadInt32(&c.reloadsInFlight) > 0 {
+		return
+	}
+	c.lastDriftCheck = time.Now()
+	c.checkDrift()
+}
+
+// checkDrift fixes differences between servers of the desired state and HAProxy runtime state
+func (c *HAProxyController) checkDrift() {
+	runtimeState, err := c.runtimeServersState()
+	if err != nil {
+		utils.LogErr(err)
+		return
+	}
+	_, backends, err := c.NativeAPI.Configuration.GetBackends("")
+	if err != nil {
+		utils.LogErr(err)
+		return
+	}
+	configured := map[string]struct{}{}
+	for _, backend := range backends {
+		configured[backend.Name] = struct{}{}
+	}
+	resync := false
+	for _, namespace := range c.cfg.Namespace {
+		for _, endpoints := range namespace.Endpoints {
+			// only settled endpoints are compared, pending ones are handled on next update
+			if endpoints.BackendName == "" || endpoints.Status != EMPTY || len(*endpoints.Addresses) == 0 {
+				continue
+			}
+			if _, ok := configured[endpoints.BackendName]; !ok {
+				continue
+			}
+			servers, ok := runtimeState[endpoints.BackendName]
+			if !ok {
+				log.Printf("drift: backend %s missing in HAProxy", endpoints.BackendName)
+				metricConfigDrifts.Add(1, "backend")
+				resync = true
+				continue
+			}
+			for _, ip := range *endpoints.Addresses {
+				if ip.Status != EMPTY {
+					continue
+				}
+				server, ok := servers[ip.HAProxyName]
+				if !ok {
+					log.Printf("drift: server %s/%s missing in HAProxy", endpoints.BackendName, ip.HAProxyName)
+					metricConfigDrifts.Add(1, "server")
+					resync = true
+					continue
+				}
+				if server.address != ip.IP {
+					log.Printf("drift: server %s/%s has address %s instead of %s, fixing", endpoints.BackendName, ip.HAProxyName, server.address, ip.IP)
+					metricConfigDrifts.Add(1, "address")
+					utils.LogErr(c.NativeAPI.Runtime.SetServerAddr(endpoints.BackendName, ip.HAProxyName, ip.IP, 0))
+				}
+				if server.maintenance != ip.Disabled {
+					status := "ready"
+					if ip.Disabled {
+						status = "maint"
+					}
+					log.Printf("drift: server %s/%s state should be %s, fixing", endpoints.BackendName, ip.HAProxyName, status)
+					metricConfigDrifts.Add(1, "state")
+					utils.LogErr(c.NativeAPI.Runtime.SetServerState(endpoints.BackendName, ip.HAProxyName, status))
+				}
+			}
+		}
+	}
+	if resync {
+		// reload is done by the next update, not while reading the state it changes
+		log.Println("drift: resync and reload on next update")
+		c.forceResync()
+	}
+}
+
+// runtimeServersState parses "show servers state" into servers by backend
+func (c *HAProxyController) runtimeServersState() (map[string]map[string]runtimeServer, error) {
+	result, err := c.NativeAPI.Runtime.ExecuteRaw("show servers state")
+	if err != nil {
+		return nil, err
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("show servers state: empty response")
+	}
+	return parseServersState(result[0]), nil
+}
+
+// parseServersState parses the output of "show servers state"
+func parseServersState(output string) map[string]map[string]runtimeServer {
+	state := map[string]map[string]runtimeServer{}
+	// be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight ...
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 7 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		adminState, errState := strconv.ParseInt(fields[6], 10, 64)
+		if errState != nil {
+			continue
+		}
+		if _, ok := state[fields[1]]; !ok {
+			state[fields[1]] = map[string]runtimeServer{}
+		}
+		server := runtimeServer{
+			address: fields[4],
+			// SRV_ADMF_FMAINT | SRV_ADMF_IMAINT | SRV_ADMF_CMAINT
+			maintenance: adminState&0x07 != 0,
+		}
+		if len(fields) > 7 {
+			// srv_uweight
+			server.weight, _ = strconv.ParseInt(fields[7], 10, 64)
+		}
+		state[fields[1]][fields[3]] = server
+	}
+	return state
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+)
+
+const testServersState = `1
+# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight srv_iweight
+3 default-app-80 1 SRV_1 10.0.0.9 2 0 128 1
+3 default-app-80 2 SRV_2 10.0.0.2 0 1 1 1
+4 default-backend 1 SRV_1 10.0.1.1 2 0 1 1
+`
+
+func TestParseServersState(t *testing.T) {
+	expected := map[string]map[string]runtimeServer{
+		"default-app-80": {
+			"SRV_1": {address: "10.0.0.9", weight: 128},
+			"SRV_2": {address: "10.0.0.2", maintenance: true, weight: 1},
+		},
+		"default-backend": {
+			"SRV_1": {address: "10.0.1.1", weight: 1},
+		},
+	}
+	if state := parseServersState(testServersState); !reflect.DeepEqual(state, expected) {
+		t.Errorf("expected %v, got %v", expected, state)
+	}
+	if state := parseServersState(""); len(state) != 0 {
+		t.Errorf("expected empty state, got %v", state)
+	}
+}
+
+func testDriftController(t *testing.T, addresses EndpointIPs) (c *HAProxyController, r *testRuntime, cleanup func()) {
+	c, cleanupConfig := newTestController(t)
+	r = newTestRuntime(t, func(command string) string {
+		if command == "show servers state" {
+			return testServersState
+		}
+		return "\n"
+	})
+	c.NativeAPI.Runtime = r.client(t)
+	c.cfg.GetNamespace("default").Endpoints["app"] = &Endpoints{
+		BackendName: "default-app-80",
+		Addresses:   &addresses,
+		Status:      EMPTY,
+	}
+	return c, r, func() {
+		r.close()
+		cleanupConfig()
+	}
+}
+
+func TestCheckDrift(t *testing.T) {
+	tests := []struct {
+		name      string
+		addresses EndpointIPs
+		commands  []string
+		resync    bool
+	}{
+		{
+			name: "in sync",
+			addresses: EndpointIPs{
+				"10.0.0.9": {IP: "10.0.0.9", HAProxyName: "SRV_1", Status: EMPTY},
+				"10.0.0.2": {IP: "10.0.0.2", HAProxyName: "SRV_2", Disabled: true, Status: EMPTY},
+			},
+			commands: []string{"show servers state"},
+		},
+		{
+			name: "address and state fixed at runtime",
+			addresses: EndpointIPs{
+				"10.0.0.1": {IP: "10.0.0.1", HAProxyName: "SRV_1", Status: EMPTY},
+				"10.0.0.2": {IP: "10.0.0.2", HAProxyName: "SRV_2", Status: EMPTY},
+			},
+			commands: []string{"show servers state", "set server default-app-80/SRV_1 addr 10.0.0.1", "set server default-app-80/SRV_2 state ready"},
+		},
+		{
+			name: "missing server",
+			addresses: EndpointIPs{
+				"10.0.0.9": {IP: "10.0.0.9", HAProxyName: "SRV_1", Status: EMPTY},
+				"10.0.0.3": {IP: "10.0.0.3", HAProxyName: "SRV_3", Status: EMPTY},
+			},
+			commands: []string{"show servers state"},
+			resync:   true,
+		},
+		{
+			name: "pending endpoints are not compared",
+			addresses: EndpointIPs{
+				"10.0.0.3": {IP: "10.0.0.3", HAProxyName: "SRV_3", Status: ADDED},
+			},
+			commands: []string{"show servers state"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, r, cleanup := testDriftController(t, test.addresses)
+			defer cleanup()
+			c.checkDrift()
+			// endpoints addresses are a map, order of commands is not relevant
+			commands := r.received()
+			sort.Strings(commands)
+			sort.Strings(test.commands)
+			if !reflect.DeepEqual(commands, test.commands) {
+				t.Errorf("expected commands %q, got %q", test.commands, commands)
+			}
+			// HAProxy is reloaded by the next update, not by the drift check
+			if c.forceReload != test.resync {
+				t.Errorf("expected forceReload %t, got %t", test.resync, c.forceReload)
+			}
+		})
+	}
+}
+
+func TestHandleDriftReloadInFlight(t *testing.T) {
+	c, r, cleanup := testDriftController(t, EndpointIPs{})
+	defer cleanup()
+	c.osArgs.DriftCheckInterval = time.Second
+	c.reloadsInFlight = 1
+	c.handleDrift()
+	if len(r.received()) != 0 || !c.lastDriftCheck.IsZero() {
+		t.Errorf("expected drift check to be postponed, got commands %q", r.received())
+	}
+	c.reloadsInFlight = 0
+	c.handleDrift()
+	if len(r.received()) != 1 {
+		t.Errorf("expected drift check once reload is done, got commands %q", r.received())
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// earlyHintRegexp matches a Link header value, "<uri>" followed by ";" separated parameters
+var earlyHintRegexp = regexp.MustCompile(`^<[^<>\s"%]+>(\s*;\s*[A-Za-z*-]+(=[^;"%\s]+)?)*$`)
+
+// ingressEarlyHints returns "http-request early-hint" lines sending the Link values of
+// "early-hints" annotation in a 103 response, for requests to the paths of the ingress.
+func (c *HAProxyController) ingressEarlyHints(ingress *Ingress) (lines []string, err error) {
+	annHints, _ := GetValueFromAnnotations("early-hints", ingress.Annotations)
+	if ingress.Status == DELETED || annHints == nil || annHints.Status == DELETED || !c.featureSupported("early-hint") {
+		return nil, nil
+	}
+	links, err := parseEarlyHints(annHints.Value)
+	if err != nil {
+		return nil, fmt.Errorf("early-hints annotation: %s", err)
+	}
+	for _, cond := range c.ingressMatchConds(ingress) {
+		for _, link := range links {
+			lines = append(lines, fmt.Sprintf("http-request early-hint Link \"%s\" if %s", link, cond))
+		}
+	}
+	return lines, nil
+}
+
+// handleEarlyHints sets early-hint lines of all ingresses in HTTP frontends
+func (c *HAProxyController) handleEarlyHints(lines []string) (needsReload bool, err error) {
+	sort.Strings(lines)
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		reload, errSet := c.sectionDirectivesSet(parser.Frontends, frontend, "http-request early-hint", lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
+// parseEarlyHints validates a new line separated list of Link values,
+// e.g. "</style.css>; rel=preload; as=style"
+func parseEarlyHints(value string) (links []string, err error) {
+	for _, link := range strings.Split(value, "\n") {
+		link = strings.TrimSpace(link)
+		if link == "" {
+			continue
+		}
+		if !earlyHintRegexp.MatchString(link) {
+			return nil, fmt.Errorf("incorrect Link value '%s'", link)
+		}
+		links = append(links, link)
+	}
+	if len(links) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return links, nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEarlyHints(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := c.testIngress("default", "web")
+	ingress.Annotations = testAnnotations(map[string]string{
+		"early-hints": "</style.css>; rel=preload; as=style\n</app.js>; rel=preload; as=script",
+	})
+	c.testIngress("default", "other")
+	c.addUseBackendRule("Rdefaultwebexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "web"}, FrontendHTTP, FrontendHTTPS)
+	c.addUseBackendRule("Rdefaultotherexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-app-80", Namespace: "default", Ingress: "other"}, FrontendHTTP, FrontendHTTPS)
+	hints, err := c.ingressEarlyHints(ingress)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := c.testSync(t, func() {
+		if reload, errHints := c.handleEarlyHints(hints); errHints != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, errHints)
+		}
+	})
+	expected := []string{
+		`http-request early-hint Link "</app.js>; rel=preload; as=script" if { var(txn.ingress) -m str default/web }`,
+		`http-request early-hint Link "</style.css>; rel=preload; as=style" if { var(txn.ingress) -m str default/web }`,
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		got := []string{}
+		for _, line := range testSection(config, frontend) {
+			if strings.HasPrefix(line, "http-request early-hint") {
+				got = append(got, line)
+			}
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", frontend, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	// hints are removed with the annotation
+	ingress.Annotations["early-hints"].Status = DELETED
+	if hints, err = c.ingressEarlyHints(ingress); err != nil || len(hints) != 0 {
+		t.Fatalf("expected no hints, got %v %v", hints, err)
+	}
+	config = c.testSync(t, func() {
+		if reload, errHints := c.handleEarlyHints(hints); errHints != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, errHints)
+		}
+	})
+	if strings.Contains(config, "early-hint") {
+		t.Errorf("expected no early-hint:\n%s", config)
+	}
+}
+
+func TestEarlyHintsUnsupported(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{1, 8, 20}
+	ingress := c.testIngress("default", "web")
+	ingress.Annotations = testAnnotations(map[string]string{"early-hints": "</style.css>; rel=preload"})
+	c.addUseBackendRule("Rdefaultwebexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+	if hints, err := c.ingressEarlyHints(ingress); err != nil || len(hints) != 0 {
+		t.Errorf("expected no hints before HAProxy 1.9, got %v %v", hints, err)
+	}
+}
+
+func TestParseEarlyHints(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		err      bool
+	}{
+		{"</style.css>; rel=preload; as=style", []string{"</style.css>; rel=preload; as=style"}, false},
+		{" </a.js>;rel=preload\n\n<https://cdn.example.com/b.js>; rel=preload; crossorigin ", []string{"</a.js>;rel=preload", "<https://cdn.example.com/b.js>; rel=preload; crossorigin"}, false},
+		{"/style.css; rel=preload", nil, true},
+		{`</style.css>; rel="preload"`, nil, true},
+		{"</style.css>; rel=preload, </app.js>; rel=preload", nil, true},
+		{"\n", nil, true},
+	}
+	for _, test := range tests {
+		links, err := parseEarlyHints(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("%q: expected error %t, got %v", test.value, test.err, err)
+		}
+		if !reflect.DeepEqual(links, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.value, test.expected, links)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func testEndpoints(count int) *Endpoints {
+	endpoints := &Endpoints{
+		Namespace: "default",
+		Service:   StringW{Value: "app"},
+		Ports:     &EndpointPorts{{Name: "http", Protocol: "TCP", Port: 8080}},
+		Addresses: &EndpointIPs{},
+		Status:    ADDED,
+	}
+	for i := 1; i <= count; i++ {
+		ip := fmt.Sprintf("10.0.0.%d", i)
+		(*endpoints.Addresses)[ip] = &EndpointIP{IP: ip, Status: ADDED}
+	}
+	return endpoints
+}
+
+// testServerNames returns the HAProxy server name of each enabled endpoint IP
+func testServerNames(endpoints *Endpoints) map[string]string {
+	names := map[string]string{}
+	for _, adr := range *endpoints.Addresses {
+		if !adr.Disabled {
+			names[adr.IP] = adr.HAProxyName
+		}
+	}
+	return names
+}
+
+func TestEndpointsDiff(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	runtime := newTestRuntime(t, func(command string) string { return "\n" })
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	ns := c.cfg.GetNamespace("default")
+
+	c.eventEndpoints(ns, testEndpoints(10))
+	ns.Endpoints["app"].BackendName = "default-app-80"
+	c.cfg.Clean()
+	names := testServerNames(ns.Endpoints["app"])
+	if len(names) != 10 || len(*ns.Endpoints["app"].Addresses) != 42 {
+		t.Fatalf("expected 10 servers out of 42, got %d out of %d", len(names), len(*ns.Endpoints["app"].Addresses))
+	}
+
+	// one more endpoint takes over a disabled server
+	endpoints := testEndpoints(11)
+	endpoints.Status = MODIFIED
+	if reload := c.eventEndpoints(ns, endpoints); reload {
+		t.Error("expected update via runtime API without reload")
+	}
+	changed := []string{}
+	for _, adr := range *ns.Endpoints["app"].Addresses {
+		if adr.Status != EMPTY {
+			changed = append(changed, adr.HAProxyName)
+		}
+	}
+	if len(changed) != 1 {
+		t.Fatalf("expected exactly one changed server, got %v", changed)
+	}
+	newNames := testServerNames(ns.Endpoints["app"])
+	for ip, name := range names {
+		if newNames[ip] != name {
+			t.Errorf("%s: server renamed from %s to %s", ip, name, newNames[ip])
+		}
+	}
+	if newNames["10.0.0.11"] != changed[0] {
+		t.Errorf("expected 10.0.0.11 on server %s, got %s", changed[0], newNames["10.0.0.11"])
+	}
+	if len(*ns.Endpoints["app"].Addresses) != 42 {
+		t.Errorf("expected 42 servers, got %d", len(*ns.Endpoints["app"].Addresses))
+	}
+	for _, command := range runtime.received() {
+		if strings.HasPrefix(command, "set server") && !strings.Contains(command, "default-app-80/"+changed[0]+" ") {
+			t.Errorf("unexpected runtime command: %s", command)
+		}
+	}
+	if len(runtime.received()) == 0 {
+		t.Error("expected runtime commands for the new server")
+	}
+}
+
+func TestEndpointsDiffReplace(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	runtime := newTestRuntime(t, func(command string) string { return "\n" })
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	ns := c.cfg.GetNamespace("default")
+
+	c.eventEndpoints(ns, testEndpoints(10))
+	ns.Endpoints["app"].BackendName = "default-app-80"
+	c.cfg.Clean()
+	names := testServerNames(ns.Endpoints["app"])
+
+	// 10.0.0.10 is replaced with 10.0.0.20 on the same server
+	endpoints := testEndpoints(9)
+	(*endpoints.Addresses)["10.0.0.20"] = &EndpointIP{IP: "10.0.0.20", Status: ADDED}
+	endpoints.Status = MODIFIED
+	c.eventEndpoints(ns, endpoints)
+	newNames := testServerNames(ns.Endpoints["app"])
+	if len(newNames) != 10 {
+		t.Fatalf("expected 10 servers, got %v", newNames)
+	}
+	if newNames["10.0.0.20"] != names["10.0.0.10"] {
+		t.Errorf("expected 10.0.0.20 on server %s, got %s", names["10.0.0.10"], newNames["10.0.0.20"])
+	}
+	for _, adr := range *ns.Endpoints["app"].Addresses {
+		if adr.Status != EMPTY && adr.IP != "10.0.0.20" {
+			t.Errorf("%s: unexpected status %s", adr.HAProxyName, adr.Status)
+		}
+	}
+}
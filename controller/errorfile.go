@@ -0,0 +1,97 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// handleBackendErrorfile503 replaces the 503 response of a backend without
+// available servers by the one of the ConfigMap (<namespace>/<name>) referenced
+// by "errorfile-503" annotation. The ConfigMap provides "body" and optional
+// "content-type" keys, the response file is written in HAProxyErrorsDir.
+func (c *HAProxyController) handleBackendErrorfile503(namespace *Namespace, ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	file := path.Join(HAProxyErrorsDir, backendName+".503.http")
+	lines := []string{}
+	annErrorfile, _ := GetValueFromAnnotations("errorfile-503", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	// a 503 redirect of errorloc annotation takes precedence
+	if annErrorloc, _ := GetValueFromAnnotations("errorloc", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations); annErrorloc != nil && annErrorloc.Status != DELETED {
+		if locations, errParse := parseErrorloc(annErrorloc.Value); errParse == nil && locations["503"] != "" {
+			annErrorfile = nil
+		}
+	}
+	if annErrorfile == nil || annErrorfile.Status == DELETED {
+		if errRemove := os.Remove(file); errRemove != nil && !os.IsNotExist(errRemove) {
+			err = errRemove
+		}
+	} else {
+		content, errContent := c.errorfile503Content(namespace, annErrorfile.Value)
+		if errContent != nil {
+			return false, fmt.Errorf("errorfile-503 annotation: %s", errContent)
+		}
+		if current, errRead := ioutil.ReadFile(file); errRead != nil || !bytes.Equal(current, content) {
+			if err = ioutil.WriteFile(file, content, 0644); err != nil {
+				return false, fmt.Errorf("errorfile-503 annotation: %s", err)
+			}
+			needsReload = errRead == nil
+		}
+		lines = append(lines, "errorfile 503 "+file)
+	}
+	reload, errSet := c.sectionDirectivesSet(parser.Backends, backendName, "errorfile 503", lines)
+	if errSet != nil {
+		return needsReload, errSet
+	}
+	return needsReload || reload, err
+}
+
+// errorfile503Content builds a raw HTTP response from the ConfigMap referenced
+// by errorfile-503 annotation, namespace defaults to the one of the ingress.
+func (c *HAProxyController) errorfile503Content(namespace *Namespace, value string) ([]byte, error) {
+	nsName := namespace.Name
+	name := strings.TrimSpace(value)
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+		nsName, name = parts[0], parts[1]
+	}
+	if name == "" || nsName == "" {
+		return nil, fmt.Errorf("expected <namespace>/<configmap>, got '%s'", value)
+	}
+	if nsName != namespace.Name && !c.osArgs.AllowCrossNamespace {
+		return nil, fmt.Errorf("configmap '%s' is in namespace '%s', cross namespace references are not allowed", name, nsName)
+	}
+	ns, ok := c.cfg.Namespace[nsName]
+	if !ok {
+		return nil, fmt.Errorf("namespace '%s' is not watched by the controller", nsName)
+	}
+	configMap, ok := ns.ConfigMaps[name]
+	if !ok {
+		return nil, fmt.Errorf("configmap '%s/%s' does not exist", nsName, name)
+	}
+	body, err := configMap.Annotations.Get("body")
+	if err != nil {
+		return nil, fmt.Errorf("configmap '%s/%s' has no 'body' key", nsName, name)
+	}
+	contentType := "text/html"
+	if ann, errGet := configMap.Annotations.Get("content-type"); errGet == nil {
+		contentType = strings.TrimSpace(ann.Value)
+	}
+	return []byte(fmt.Sprintf("HTTP/1.0 503 Service Unavailable\r\nCache-Control: no-cache\r\nConnection: close\r\nContent-Type: %s\r\n\r\n%s", contentType, body.Value)), nil
+}
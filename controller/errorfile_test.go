@@ -0,0 +1,149 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendErrorfile503(t *testing.T) {
+	tests := []struct {
+		name           string
+		annotations    map[string]string
+		crossNamespace bool
+		content        string
+		err            bool
+	}{
+		{
+			name:        "configmap of the namespace",
+			annotations: map[string]string{"errorfile-503": "maintenance"},
+			content:     "HTTP/1.0 503 Service Unavailable\r\nCache-Control: no-cache\r\nConnection: close\r\nContent-Type: text/html\r\n\r\n<h1>maintenance</h1>",
+		},
+		{
+			name:           "configmap of another namespace",
+			annotations:    map[string]string{"errorfile-503": "shared/maintenance"},
+			crossNamespace: true,
+			content:        "HTTP/1.0 503 Service Unavailable\r\nCache-Control: no-cache\r\nConnection: close\r\nContent-Type: application/json\r\n\r\n{\"error\": \"maintenance\"}",
+		},
+		{
+			name:        "cross namespace denied by default",
+			annotations: map[string]string{"errorfile-503": "shared/maintenance"},
+			err:         true,
+		},
+		{
+			name:        "missing configmap",
+			annotations: map[string]string{"errorfile-503": "missing"},
+			err:         true,
+		},
+		{
+			name:        "errorloc takes precedence",
+			annotations: map[string]string{"errorfile-503": "maintenance", "errorloc": "503 https://status.example.com"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "haproxy-ingress-errors")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.RemoveAll(dir)
+			errorsDir := HAProxyErrorsDir
+			HAProxyErrorsDir = dir
+			defer func() { HAProxyErrorsDir = errorsDir }()
+
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.osArgs.AllowCrossNamespace = test.crossNamespace
+			namespace := c.cfg.GetNamespace("default")
+			namespace.ConfigMaps["maintenance"] = &ConfigMap{Annotations: MapStringW{"body": &StringW{Value: "<h1>maintenance</h1>"}}}
+			c.cfg.GetNamespace("shared").ConfigMaps["maintenance"] = &ConfigMap{Annotations: MapStringW{
+				"body":         &StringW{Value: `{"error": "maintenance"}`},
+				"content-type": &StringW{Value: "application/json"},
+			}}
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendErrorfile503(namespace, ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			file := filepath.Join(dir, "default-app-80.503.http")
+			if test.content == "" {
+				if strings.Contains(config, "errorfile 503") {
+					t.Errorf("expected no errorfile:\n%s", config)
+				}
+				return
+			}
+			// served by HAProxy when all servers of the backend are down
+			if !testSectionHas(config, "backend default-app-80", "errorfile 503 "+file) {
+				t.Errorf("expected errorfile 503 %s:\n%s", file, config)
+			}
+			content, errRead := ioutil.ReadFile(file)
+			if errRead != nil {
+				t.Fatal(errRead)
+			}
+			if string(content) != test.content {
+				t.Errorf("expected:\n%q\ngot:\n%q", test.content, content)
+			}
+		})
+	}
+}
+
+func TestHandleBackendErrorfile503Removed(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	errorsDir := HAProxyErrorsDir
+	HAProxyErrorsDir = dir
+	defer func() { HAProxyErrorsDir = errorsDir }()
+
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	namespace := c.cfg.GetNamespace("default")
+	namespace.ConfigMaps["maintenance"] = &ConfigMap{Annotations: MapStringW{"body": &StringW{Value: "maintenance"}}}
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"errorfile-503": "maintenance"})}
+	c.testSync(t, func() {
+		if reload, errFile := c.handleBackendErrorfile503(namespace, ingress, service, "default-app-80"); errFile != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, errFile)
+		}
+	})
+	// body changed
+	namespace.ConfigMaps["maintenance"].Annotations["body"].Value = "back soon"
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendErrorfile503(namespace, ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	service.Annotations["errorfile-503"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleBackendErrorfile503(namespace, ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "errorfile 503") {
+		t.Errorf("expected no errorfile:\n%s", config)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "default-app-80.503.http")); !os.IsNotExist(err) {
+		t.Errorf("expected response file to be removed, got %v", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// errorlocCodes are the status codes HAProxy can replace by a redirect
+var errorlocCodes = map[string]struct{}{
+	"200": {}, "400": {}, "403": {}, "405": {}, "408": {}, "425": {},
+	"429": {}, "500": {}, "502": {}, "503": {}, "504": {},
+}
+
+// handleBackendErrorloc redirects error responses of a backend to an external
+// error page service with "errorloc302" (or "errorloc303" according to
+// "errorloc-redirect-code" annotation) for each "<code> <url>" line of "errorloc" annotation.
+func (c *HAProxyController) handleBackendErrorloc(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	lines := []string{}
+	annErrorloc, _ := GetValueFromAnnotations("errorloc", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annErrorloc != nil && annErrorloc.Status != DELETED {
+		keyword := "errorloc302"
+		annCode, _ := GetValueFromAnnotations("errorloc-redirect-code", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		if annCode != nil && annCode.Status != DELETED {
+			switch annCode.Value {
+			case "302":
+			case "303":
+				keyword = "errorloc303"
+			default:
+				return false, fmt.Errorf("errorloc-redirect-code annotation: expected 302 or 303, got '%s'", annCode.Value)
+			}
+		}
+		locations, errParse := parseErrorloc(annErrorloc.Value)
+		if errParse != nil {
+			return false, fmt.Errorf("errorloc annotation: %s", errParse)
+		}
+		codes := make([]string, 0, len(locations))
+		for code := range locations {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			lines = append(lines, fmt.Sprintf("%s %s %s", keyword, code, locations[code]))
+		}
+	}
+	for _, directive := range []string{"errorloc302", "errorloc303"} {
+		directiveLines := []string{}
+		for _, line := range lines {
+			if strings.HasPrefix(line, directive+" ") {
+				directiveLines = append(directiveLines, line)
+			}
+		}
+		reload, errSet := c.sectionDirectivesSet(parser.Backends, backendName, directive, directiveLines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
+// parseErrorloc returns redirect URLs by status code of "<code> <url>" lines
+func parseErrorloc(value string) (map[string]string, error) {
+	locations := map[string]string{}
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("expected '<code> <url>', got '%s'", strings.TrimSpace(line))
+		}
+		if _, ok := errorlocCodes[fields[0]]; !ok {
+			return nil, fmt.Errorf("status code '%s' can not be redirected", fields[0])
+		}
+		location, errURL := url.Parse(fields[1])
+		if errURL != nil || (location.Scheme != "http" && location.Scheme != "https" && !strings.HasPrefix(fields[1], "/")) {
+			return nil, fmt.Errorf("incorrect URL '%s'", fields[1])
+		}
+		locations[fields[0]] = fields[1]
+	}
+	if len(locations) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return locations, nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendErrorloc(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{
+			name:        "302 redirects",
+			annotations: map[string]string{"errorloc": "503 https://errors.example.com/503.html\n403 /errors/403.html"},
+			expected: []string{
+				"errorloc302 403 /errors/403.html",
+				"errorloc302 503 https://errors.example.com/503.html",
+			},
+		},
+		{
+			name:        "303 redirect",
+			annotations: map[string]string{"errorloc": "500 https://errors.example.com/", "errorloc-redirect-code": "303"},
+			expected:    []string{"errorloc303 500 https://errors.example.com/"},
+		},
+		{
+			name:        "invalid redirect code",
+			annotations: map[string]string{"errorloc": "500 https://errors.example.com/", "errorloc-redirect-code": "301"},
+			err:         true,
+		},
+		{
+			name:        "code that can not be redirected",
+			annotations: map[string]string{"errorloc": "418 https://errors.example.com/"},
+			err:         true,
+		},
+		{
+			name:        "invalid URL",
+			annotations: map[string]string{"errorloc": "503 ftp://errors.example.com/"},
+			err:         true,
+		},
+		{
+			name:        "missing URL",
+			annotations: map[string]string{"errorloc": "503"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendErrorloc(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "errorloc") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestHandleBackendErrorlocRedirectCodeChanged(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"errorloc": "503 https://errors.example.com/"})}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendErrorloc(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	service.Annotations["errorloc-redirect-code"] = &StringW{Value: "303", Status: ADDED}
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleBackendErrorloc(ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "errorloc302") || !testSectionHas(config, "backend default-app-80", "errorloc303 503 https://errors.example.com/") {
+		t.Errorf("expected errorloc303 only:\n%s", config)
+	}
+}
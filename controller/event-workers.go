@@ -0,0 +1,96 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+)
+
+// namespacedJob is an event with the namespace it belongs to,
+// namespace is resolved by SyncData since it may create it.
+type namespacedJob struct {
+	ns  *Namespace
+	job SyncDataEvent
+}
+
+// eventWorkers processes ingress, service and endpoints events concurrently.
+// Events of a namespace always go to the same worker so they are processed in order,
+// other events and HAProxy updates wait for all workers to be idle, hence frontends
+// (backend switching rules, TCP requests...) are only updated from SyncData goroutine.
+type eventWorkers struct {
+	queues  []chan namespacedJob
+	pending sync.WaitGroup
+	mu      sync.Mutex
+	reasons map[string]struct{}
+	handle  func(ns *Namespace, job SyncDataEvent) (updateRequired bool)
+}
+
+func newEventWorkers(size int, handle func(ns *Namespace, job SyncDataEvent) bool) *eventWorkers {
+	w := &eventWorkers{
+		queues:  make([]chan namespacedJob, size),
+		reasons: map[string]struct{}{},
+		handle:  handle,
+	}
+	for i := range w.queues {
+		w.queues[i] = make(chan namespacedJob, 100)
+		go func(queue <-chan namespacedJob) {
+			for item := range queue {
+				if w.handle(item.ns, item.job) {
+					w.mu.Lock()
+					w.reasons[strings.ToLower(string(item.job.SyncType))] = struct{}{}
+					w.mu.Unlock()
+				}
+				w.pending.Done()
+			}
+		}(w.queues[i])
+	}
+	return w
+}
+
+// dispatch queues the event to the worker of its namespace
+func (w *eventWorkers) dispatch(ns *Namespace, job SyncDataEvent) {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(ns.Name))
+	w.pending.Add(1)
+	w.queues[h.Sum32()%uint32(len(w.queues))] <- namespacedJob{ns: ns, job: job}
+}
+
+// wait blocks until all queued events are processed and returns
+// the event types that changed configuration since last call
+func (w *eventWorkers) wait() (reasons []string) {
+	w.pending.Wait()
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for reason := range w.reasons {
+		reasons = append(reasons, reason)
+	}
+	w.reasons = map[string]struct{}{}
+	return reasons
+}
+
+// eventNamespaced handles events that only modify their own namespace
+func (c *HAProxyController) eventNamespaced(ns *Namespace, job SyncDataEvent) (updateRequired bool) {
+	switch job.SyncType {
+	case INGRESS:
+		return c.eventIngress(ns, job.Data.(*Ingress))
+	case ENDPOINTS:
+		return c.eventEndpoints(ns, job.Data.(*Endpoints))
+	case SERVICE:
+		return c.eventService(ns, job.Data.(*Service))
+	}
+	return false
+}
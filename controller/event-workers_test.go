@@ -0,0 +1,91 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEventWorkersConcurrentNamespaces(t *testing.T) {
+	// "ns1" and "ns2" are assigned to different workers of a pool of 2
+	unblock := make(chan struct{})
+	w := newEventWorkers(2, func(ns *Namespace, job SyncDataEvent) bool {
+		switch ns.Name {
+		case "ns2":
+			select {
+			case <-unblock:
+			case <-time.After(5 * time.Second):
+				t.Error("ns2 event was not processed while ns1 event was")
+			}
+		case "ns1":
+			close(unblock)
+		}
+		return true
+	})
+	w.dispatch(&Namespace{Name: "ns2"}, SyncDataEvent{SyncType: INGRESS})
+	w.dispatch(&Namespace{Name: "ns1"}, SyncDataEvent{SyncType: SERVICE})
+	reasons := w.wait()
+	sort.Strings(reasons)
+	if strings.Join(reasons, ",") != "ingress,service" {
+		t.Errorf("expected reasons ingress,service, got %v", reasons)
+	}
+	if reasons = w.wait(); len(reasons) != 0 {
+		t.Errorf("expected reasons to be reset, got %v", reasons)
+	}
+}
+
+func TestEventWorkersNamespaceOrder(t *testing.T) {
+	var mu sync.Mutex
+	running := map[string]bool{}
+	order := map[string][]int{}
+	w := newEventWorkers(4, func(ns *Namespace, job SyncDataEvent) bool {
+		mu.Lock()
+		if running[ns.Name] {
+			t.Errorf("%s: concurrent events in the same namespace", ns.Name)
+		}
+		running[ns.Name] = true
+		mu.Unlock()
+		time.Sleep(time.Millisecond)
+		mu.Lock()
+		running[ns.Name] = false
+		order[ns.Name] = append(order[ns.Name], job.Data.(int))
+		mu.Unlock()
+		return false
+	})
+	namespaces := []string{"a", "b", "c", "d"}
+	for i := 0; i < 20; i++ {
+		for _, name := range namespaces {
+			w.dispatch(&Namespace{Name: name}, SyncDataEvent{SyncType: ENDPOINTS, Data: i})
+		}
+	}
+	if reasons := w.wait(); len(reasons) != 0 {
+		t.Errorf("expected no reasons, got %v", reasons)
+	}
+	for _, name := range namespaces {
+		if len(order[name]) != 20 {
+			t.Fatalf("%s: expected 20 events, got %d", name, len(order[name]))
+		}
+		for i, n := range order[name] {
+			if n != i {
+				t.Errorf("%s: events processed out of order: %v", name, order[name])
+				break
+			}
+		}
+	}
+}
@@ -18,6 +18,7 @@ import (
 	"fmt"
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
 	"log"
+	"sort"
 	"strconv"
 )
 
@@ -251,43 +252,54 @@ func (c *HAProxyController) setModifiedStatusEndpoints(oldObj, newObj *Endpoints
 		newObj.Service.Status = MODIFIED
 		newObj.Status = MODIFIED
 	}
+	// servers keep their name when the IP is still there, new IPs take over
+	// the slot of removed IPs first and of disabled servers next,
+	// so only changed servers are updated (via runtime API) and no server is renamed
+	added := []*EndpointIP{}
 	for _, adrNew := range *newObj.Addresses {
 		adrNew.Status = ADDED
-	}
-	for oldKey, adrOld := range *oldObj.Addresses {
-		for _, adrNew := range *newObj.Addresses {
+		found := false
+		for oldKey, adrOld := range *oldObj.Addresses {
 			if adrOld.IP == adrNew.IP {
 				adrNew.HAProxyName = adrOld.HAProxyName
 				adrNew.Status = adrOld.Status
 				delete(*oldObj.Addresses, oldKey)
+				found = true
 				break
 			}
 		}
-
+		if !found {
+			added = append(added, adrNew)
+		}
 	}
+	sort.Slice(added, func(i, j int) bool { return added[i].IP < added[j].IP })
+	removed := []string{}
+	disabled := []string{}
 	for oldKey, adrOld := range *oldObj.Addresses {
+		if adrOld.Disabled {
+			disabled = append(disabled, oldKey)
+		} else {
+			removed = append(removed, oldKey)
+		}
+	}
+	sort.Strings(removed)
+	sort.Strings(disabled)
+	for _, oldKey := range append(removed, disabled...) {
+		adrOld := (*oldObj.Addresses)[oldKey]
+		if len(added) > 0 {
+			added[0].HAProxyName = adrOld.HAProxyName
+			added[0].Status = MODIFIED
+			added = added[1:]
+			continue
+		}
 		if !adrOld.Disabled {
 			// it not disabled so it must be now, no longer exists
 			adrOld.IP = "127.0.0.1"
 			adrOld.Disabled = true
 			adrOld.Status = MODIFIED
-			(*newObj.Addresses)[fmt.Sprintf("SRV_%s", utils.RandomString(5))] = adrOld
-		} else {
-			//try to find one that is added so we can switch them
-			replaced := false
-			for _, adrNew := range *newObj.Addresses {
-				if adrNew.Status == ADDED {
-					replaced = true
-					adrNew.HAProxyName = adrOld.HAProxyName
-					adrNew.Status = MODIFIED
-					break
-				}
-			}
-			if !replaced {
-				(*newObj.Addresses)[oldKey] = adrOld
-			}
+			oldKey = fmt.Sprintf("SRV_%s", utils.RandomString(5))
 		}
-
+		(*newObj.Addresses)[oldKey] = adrOld
 	}
 
 	annIncrement, _ := GetValueFromAnnotations("servers-increment", c.cfg.ConfigMap.Annotations)
@@ -343,11 +355,7 @@ func (c *HAProxyController) processEndpointIPs(data *Endpoints) (updateRequired
 		case ADDED:
 			//added on haproxy update
 			ip.Status = ADDED
-			ip.HAProxyName = fmt.Sprintf("SRV_%s", utils.RandomString(5))
-			for _, ok := usedNames[ip.HAProxyName]; ok; {
-				ip.HAProxyName = fmt.Sprintf("SRV_%s", utils.RandomString(5))
-			}
-			usedNames[ip.HAProxyName] = struct{}{}
+			ip.HAProxyName = uniqueServerName(usedNames)
 			updateRequired = true
 		case MODIFIED:
 			if data.BackendName != "" {
@@ -366,6 +374,7 @@ func (c *HAProxyController) processEndpointIPs(data *Endpoints) (updateRequired
 					log.Println(err)
 					updateRequired = true
 				}
+				data.Rebalance = true
 			} else {
 				//this is ok since if exists, we edit current data
 				ip.Status = ADDED
@@ -387,11 +396,7 @@ func (c *HAProxyController) processEndpointIPs(data *Endpoints) (updateRequired
 		return updateRequired
 	}
 	for index := 0; index < toCreate; index++ {
-		hAProxyName := fmt.Sprintf("SRV_%s", utils.RandomString(5))
-		for _, ok := usedNames[hAProxyName]; ok; {
-			hAProxyName = fmt.Sprintf("SRV_%s", utils.RandomString(5))
-			usedNames[hAProxyName] = struct{}{}
-		}
+		hAProxyName := uniqueServerName(usedNames)
 
 		(*data.Addresses)[hAProxyName] = &EndpointIP{
 			IP:          "127.0.0.1",
@@ -404,6 +409,17 @@ func (c *HAProxyController) processEndpointIPs(data *Endpoints) (updateRequired
 	return updateRequired
 }
 
+// uniqueServerName returns a random server name not in usedNames and records it
+func uniqueServerName(usedNames map[string]struct{}) string {
+	for {
+		name := fmt.Sprintf("SRV_%s", utils.RandomString(5))
+		if _, ok := usedNames[name]; !ok {
+			usedNames[name] = struct{}{}
+			return name
+		}
+	}
+}
+
 func (c *HAProxyController) eventService(ns *Namespace, data *Service) (updateRequired bool) {
 	updateRequired = false
 	switch data.Status {
@@ -415,7 +431,8 @@ func (c *HAProxyController) eventService(ns *Namespace, data *Service) (updateRe
 			log.Println("Service not registered with controller !", data.Name)
 		}
 		if oldService.Equal(newService) {
-			return updateRequired
+			// ingress statuses are patched with new addresses of publish service
+			return c.isPublishService(data) && c.cfg.PublishService.Status != EMPTY
 		}
 		newService.Annotations.SetStatus(oldService.Annotations)
 		ns.Services[data.Name] = newService
@@ -443,6 +460,11 @@ func (c *HAProxyController) eventService(ns *Namespace, data *Service) (updateRe
 	return updateRequired
 }
 
+func (c *HAProxyController) isPublishService(service *Service) bool {
+	publishSvc := c.cfg.PublishService
+	return publishSvc != nil && publishSvc.Namespace == service.Namespace && publishSvc.Name == service.Name
+}
+
 func (c *HAProxyController) eventConfigMap(ns *Namespace, data *ConfigMap, chConfigMapReceivedAndProcessed chan bool) (updateRequired bool) {
 	updateRequired = false
 	//TODO refractor this so we remember all configmaps, since we now use more that one
@@ -482,6 +504,18 @@ func (c *HAProxyController) eventConfigMap(ns *Namespace, data *ConfigMap, chCon
 		}
 	}
 
+	if !configmap && !configmapTCP {
+		old, ok := ns.ConfigMaps[data.Name]
+		switch data.Status {
+		case DELETED:
+			delete(ns.ConfigMaps, data.Name)
+			updateRequired = ok
+		default:
+			ns.ConfigMaps[data.Name] = data
+			updateRequired = !ok || !old.Equal(data)
+		}
+	}
+
 	if configmapTCP {
 		switch data.Status {
 		case MODIFIED:
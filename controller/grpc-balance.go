@@ -0,0 +1,45 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// handleBackendGRPCBalance sets "http-reuse" in h2 backends ("backend-protocol" annotation)
+// according to "grpc-balance" annotation. HAProxy balances each HTTP/2 stream as a request,
+// http-reuse decides if streams of a long lived client connection can use connections
+// to servers opened for other clients. A client connection can not be pinned to a server,
+// HAProxy balances streams and not connections in HTTP mode.
+func (c *HAProxyController) handleBackendGRPCBalance(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	lines := []string{}
+	annBalance, _ := GetValueFromAnnotations("grpc-balance", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	annProtocol, _ := GetValueFromAnnotations("backend-protocol", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annBalance != nil && annBalance.Status != DELETED && annProtocol != nil && annProtocol.Status != DELETED && annProtocol.Value == "h2" {
+		if annBalance.Value != "stream" {
+			err = fmt.Errorf("grpc-balance annotation: incorrect value '%s', expected stream", annBalance.Value)
+		} else {
+			// streams of all clients share server connections, each RPC is balanced
+			lines = append(lines, "http-reuse always")
+		}
+	}
+	reload, errSet := c.sectionDirectivesSet(parser.Backends, backendName, "http-reuse", lines)
+	if errSet != nil {
+		return false, errSet
+	}
+	return reload, err
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendGRPCBalance(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		version     HAProxyVersion
+		reuse       string
+		proto       bool
+		err         bool
+	}{
+		{
+			name:        "stream balancing of h2 backend",
+			annotations: map[string]string{"backend-protocol": "h2", "grpc-balance": "stream"},
+			reuse:       "http-reuse always",
+			proto:       true,
+		},
+		{
+			name:        "h2 backend without balancing",
+			annotations: map[string]string{"backend-protocol": "h2"},
+			proto:       true,
+		},
+		{
+			name:        "connection balancing is rejected",
+			annotations: map[string]string{"backend-protocol": "h2", "grpc-balance": "connection"},
+			proto:       true,
+			err:         true,
+		},
+		{
+			name:        "ignored for http backend",
+			annotations: map[string]string{"backend-protocol": "http", "grpc-balance": "stream"},
+		},
+		{
+			name:        "h2 servers on old version",
+			annotations: map[string]string{"backend-protocol": "h2", "grpc-balance": "stream"},
+			version:     HAProxyVersion{1, 8, 0},
+			reuse:       "http-reuse always",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.haproxyVersion = test.version
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				if _, errProto := c.handleBackendProtocol(ingress, service, "default-app-80"); errProto != nil {
+					t.Fatal(errProto)
+				}
+				_, err = c.handleBackendGRPCBalance(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			reuse := ""
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "http-reuse") {
+					reuse = line
+				}
+			}
+			if reuse != test.reuse {
+				t.Errorf("expected '%s', got '%s'", test.reuse, reuse)
+			}
+			if proto := testSectionHas(config, "backend default-app-80", "default-server proto h2"); proto != test.proto {
+				t.Errorf("expected default-server proto h2 %t:\n%s", test.proto, config)
+			}
+		})
+	}
+}
+
+func TestHandleBackendProtocolRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"backend-protocol": "h2"})}
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendProtocol(ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendProtocol(ingress, service, "default-app-80"); reload {
+			t.Error("expected no reload")
+		}
+	})
+	service.Annotations["backend-protocol"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleBackendProtocol(ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "default-server") {
+		t.Errorf("expected no default-server line:\n%s", config)
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+var headerNameRegexp = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+.^_|~-]+$`)
+
+// handleH1CaseAdjust sets the global case conversion of HTTP/1 header names, HAProxy sends them in lower case.
+// "h1-case-adjust" lists header names with the expected case, "h1-case-adjust-file" is the path of a file with
+// "<lower case> <expected case>" lines. Conversion is only applied to backends with "h1-case-adjust-bogus-server".
+func (c *HAProxyController) handleH1CaseAdjust() (needsReload bool, err error) {
+	annCaseAdjust, errAnn := GetValueFromAnnotations("h1-case-adjust", c.cfg.ConfigMap.Annotations)
+	if errAnn == nil && annCaseAdjust.Status != EMPTY {
+		lines := []string{}
+		if annCaseAdjust.Status != DELETED {
+			lines, err = h1CaseAdjustLines(annCaseAdjust.Value)
+		}
+		if err != nil {
+			err = fmt.Errorf("h1-case-adjust annotation: %s", err)
+		} else {
+			needsReload, err = c.sectionDirectivesSet(parser.Global, parser.GlobalSectionName, "h1-case-adjust", lines)
+		}
+	}
+	annCaseAdjustFile, errAnn := GetValueFromAnnotations("h1-case-adjust-file", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annCaseAdjustFile.Status == EMPTY {
+		return needsReload, err
+	}
+	lines := []string{}
+	if annCaseAdjustFile.Status != DELETED {
+		if !filepath.IsAbs(annCaseAdjustFile.Value) {
+			return needsReload, fmt.Errorf("h1-case-adjust-file annotation: expected an absolute path, got '%s'", annCaseAdjustFile.Value)
+		}
+		lines = append(lines, "h1-case-adjust-file "+annCaseAdjustFile.Value)
+	}
+	reload, errSet := c.sectionDirectivesSet(parser.Global, parser.GlobalSectionName, "h1-case-adjust-file", lines)
+	if errSet != nil {
+		err = errSet
+	}
+	return needsReload || reload, err
+}
+
+// h1CaseAdjustLines returns "h1-case-adjust <lower case> <name>" lines from a comma or space separated list of header names
+func h1CaseAdjustLines(value string) (lines []string, err error) {
+	for _, name := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	}) {
+		if !headerNameRegexp.MatchString(name) {
+			return nil, fmt.Errorf("invalid header name '%s'", name)
+		}
+		if strings.ToLower(name) == name {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("h1-case-adjust %s %s", strings.ToLower(name), name))
+	}
+	if len(lines) == 0 {
+		return nil, fmt.Errorf("no header name with upper case letters")
+	}
+	return lines, nil
+}
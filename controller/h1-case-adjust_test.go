@@ -0,0 +1,99 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleH1CaseAdjust(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{
+		"h1-case-adjust":      &StringW{Value: "X-Custom-Header, ETag content-type", Status: ADDED},
+		"h1-case-adjust-file": &StringW{Value: "/etc/haproxy/case-adjust.map", Status: ADDED},
+	}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleH1CaseAdjust(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	for _, expected := range []string{
+		"h1-case-adjust x-custom-header X-Custom-Header",
+		"h1-case-adjust etag ETag",
+		"h1-case-adjust-file /etc/haproxy/case-adjust.map",
+	} {
+		if !testSectionHas(config, "global", expected) {
+			t.Errorf("expected '%s' in global:\n%s", expected, config)
+		}
+	}
+	if strings.Contains(config, "content-type") {
+		t.Errorf("expected no mapping of lower case header:\n%s", config)
+	}
+
+	c.cfg.ConfigMap.Annotations["h1-case-adjust"] = &StringW{Value: "ETag", Status: MODIFIED}
+	c.cfg.ConfigMap.Annotations["h1-case-adjust-file"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleH1CaseAdjust(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if !testSectionHas(config, "global", "h1-case-adjust etag ETag") ||
+		strings.Contains(config, "X-Custom-Header") || strings.Contains(config, "h1-case-adjust-file") {
+		t.Errorf("expected only ETag mapping:\n%s", config)
+	}
+}
+
+func TestHandleH1CaseAdjustInvalid(t *testing.T) {
+	tests := map[string]map[string]string{
+		"invalid header name": {"h1-case-adjust": "X-Custom:Header"},
+		"lower case only":     {"h1-case-adjust": "etag"},
+		"relative file path":  {"h1-case-adjust-file": "case-adjust.map"},
+	}
+	for name, annotations := range tests {
+		t.Run(name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.cfg.ConfigMap.Annotations = MapStringW{}
+			for k, v := range annotations {
+				c.cfg.ConfigMap.Annotations[k] = &StringW{Value: v, Status: ADDED}
+			}
+			config := c.testSync(t, func() {
+				if _, err := c.handleH1CaseAdjust(); err == nil {
+					t.Error("expected error")
+				}
+			})
+			if strings.Contains(config, "h1-case-adjust") {
+				t.Errorf("expected no h1-case-adjust:\n%s", config)
+			}
+		})
+	}
+}
+
+func TestH1CaseAdjustBogusServer(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: MapStringW{"h1-case-adjust-bogus-server": &StringW{Value: "true", Status: ADDED}}}
+	config := c.testSync(t, func() {
+		if _, err := c.handleBackendOption("h1-case-adjust-bogus-server", ingress, service, "default-app-80", false); err != nil {
+			t.Error(err)
+		}
+	})
+	if !testSectionHas(config, "backend default-app-80", "option h1-case-adjust-bogus-server") {
+		t.Errorf("expected option h1-case-adjust-bogus-server:\n%s", config)
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// h2Cond matches requests received over HTTP/2
+const h2Cond = "{ fc_http_major 2 }"
+
+// handleBackendH2Downgrade configures how HTTP/2 requests of clients are sent to HTTP/1.1 servers
+// with "h2-downgrade" annotation. "translate" lets HAProxy convert them, with "option http-use-htx"
+// on versions where HTX is not the default, "reject-connect" denies HTTP/2 CONNECT requests
+// (e.g. WebSocket over HTTP/2) that servers can not handle and "reject" denies all HTTP/2 requests.
+func (c *HAProxyController) handleBackendH2Downgrade(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	mode := "translate"
+	annDowngrade, _ := GetValueFromAnnotations("h2-downgrade", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annDowngrade != nil && annDowngrade.Status != DELETED {
+		mode = annDowngrade.Value
+	}
+	var wanted *models.HTTPRequestRule
+	switch mode {
+	case "translate":
+	case "reject":
+		wanted = &models.HTTPRequestRule{Type: "deny", DenyStatus: 400, Cond: "if", CondTest: h2Cond}
+		if c.featureSupported("deny-status-any") {
+			// HTTP Version Not Supported
+			wanted.DenyStatus = 505
+		}
+	case "reject-connect":
+		wanted = &models.HTTPRequestRule{Type: "deny", DenyStatus: 405, Cond: "if", CondTest: h2Cond + " { method CONNECT }"}
+	default:
+		return false, fmt.Errorf("h2-downgrade annotation: incorrect value '%s', expected translate, reject or reject-connect", mode)
+	}
+	backend, err := c.backendGet(backendName)
+	if err != nil || backend.Mode != "http" {
+		return false, err
+	}
+
+	// HTX, needed to convert HTTP/2 to HTTP/1.1, is the default since HAProxy 2.0
+	htx := !c.haproxyVersion.Supports("htx-default")
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	if _, errGet := config.Get(parser.Backends, backendName, "option http-use-htx"); (errGet == nil) != htx {
+		c.ActiveTransactionHasChanges = true
+		needsReload = true
+		if htx {
+			err = config.Set(parser.Backends, backendName, "option http-use-htx", types.SimpleOption{})
+		} else {
+			err = config.Set(parser.Backends, backendName, "option http-use-htx", nil)
+		}
+		if err != nil {
+			return needsReload, err
+		}
+	}
+
+	_, rules, err := c.NativeAPI.Configuration.GetHTTPRequestRules("backend", backendName, c.ActiveTransaction)
+	if err != nil {
+		return needsReload, err
+	}
+	current := []*models.HTTPRequestRule{}
+	for _, rule := range rules {
+		if rule.Type == "deny" && strings.HasPrefix(rule.CondTest, h2Cond) {
+			current = append(current, rule)
+		}
+	}
+	if wanted == nil && len(current) == 0 {
+		return needsReload, nil
+	}
+	if wanted != nil && len(current) == 1 && current[0].DenyStatus == wanted.DenyStatus && current[0].CondTest == wanted.CondTest {
+		return needsReload, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	// IDs of following rules shift after each deletion
+	for i := len(current) - 1; i >= 0; i-- {
+		if errDel := c.NativeAPI.Configuration.DeleteHTTPRequestRule(*current[i].ID, "backend", backendName, c.ActiveTransaction, 0); errDel != nil {
+			return true, errDel
+		}
+	}
+	if wanted != nil {
+		wanted.ID = utils.PtrInt64(0)
+		err = c.NativeAPI.Configuration.CreateHTTPRequestRule("backend", backendName, wanted, c.ActiveTransaction, 0)
+	}
+	return true, err
+}
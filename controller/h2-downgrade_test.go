@@ -0,0 +1,128 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendH2Downgrade(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		version  HAProxyVersion
+		expected []string
+		htx      bool
+		err      bool
+	}{
+		{name: "default", expected: []string{}},
+		{name: "translate", value: "translate", expected: []string{}},
+		{name: "translate htx", value: "translate", version: HAProxyVersion{1, 9, 0}, expected: []string{}, htx: true},
+		{name: "reject", value: "reject", expected: []string{"http-request deny deny_status 505 if { fc_http_major 2 }"}},
+		{name: "reject before 2.2", value: "reject", version: HAProxyVersion{2, 1, 0}, expected: []string{"http-request deny deny_status 400 if { fc_http_major 2 }"}},
+		{name: "reject connect", value: "reject-connect", expected: []string{"http-request deny deny_status 405 if { fc_http_major 2 } { method CONNECT }"}},
+		{name: "invalid", value: "drop", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.haproxyVersion = test.version
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: MapStringW{}}
+			if test.value != "" {
+				service.Annotations = testAnnotations(map[string]string{"h2-downgrade": test.value})
+			}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendH2Downgrade(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.err {
+				return
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.Contains(line, "fc_http_major") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+			if testSectionHas(config, "backend default-app-80", "option http-use-htx") != test.htx {
+				t.Errorf("expected option http-use-htx %t:\n%s", test.htx, config)
+			}
+		})
+	}
+}
+
+func TestHandleBackendH2DowngradeTCP(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"h2-downgrade": "reject"})}
+	config := c.testSync(t, func() {
+		backend, _ := c.backendGet("default-app-80")
+		backend.Mode = "tcp"
+		if err := c.backendEdit(backend); err != nil {
+			t.Fatal(err)
+		}
+		if reload, err := c.handleBackendH2Downgrade(ingress, service, "default-app-80"); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "fc_http_major") {
+		t.Errorf("expected no HTTP/2 rule in TCP backend:\n%s", config)
+	}
+}
+
+func TestHandleBackendH2DowngradeUpdate(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"h2-downgrade": "reject"})}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendH2Downgrade(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendH2Downgrade(ingress, service, "default-app-80"); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+	service.Annotations["h2-downgrade"] = &StringW{Value: "reject-connect", Status: MODIFIED}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleBackendH2Downgrade(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Count(config, "fc_http_major") != 1 || !strings.Contains(config, "{ method CONNECT }") {
+		t.Errorf("expected HTTP/2 CONNECT requests only to be denied:\n%s", config)
+	}
+	service.Annotations["h2-downgrade"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleBackendH2Downgrade(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "fc_http_major") {
+		t.Errorf("expected HTTP/2 requests to be translated:\n%s", config)
+	}
+}
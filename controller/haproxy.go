@@ -24,10 +24,16 @@ import (
 	parser "github.com/haproxytech/config-parser/v2"
 	"github.com/haproxytech/config-parser/v2/types"
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
 )
 
 func (c *HAProxyController) updateHAProxy() error {
-	needsReload := false
+	needsReload := c.forceReload
+	if c.forceReload {
+		c.reloadEvents.addReason("forced reload")
+	}
+	c.forceReload = false
+	c.rotateAnnotationWarnings()
 
 	err := c.apiStartTransaction()
 	if err != nil {
@@ -62,12 +68,58 @@ func (c *HAProxyController) updateHAProxy() error {
 	utils.LogErr(err)
 	needsReload = needsReload || reload
 
+	reload, err = c.handleLogFormatSD()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleContstats()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleLogHealthChecks()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleSeamlessReload()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handlePrometheusExporter()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleH1CaseAdjust()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleResolvers()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	// before default service, its backend may be shared with ingresses
+	c.detectBalanceConflicts()
+
 	reload, err = c.handleDefaultService()
 	utils.LogErr(err)
 	needsReload = needsReload || reload
 
+	reload, err = c.handleACMESolver()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleNoHostMatch()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleDefaultBackendDisabled()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
 	captureHosts := map[uint64][]string{}
-	usedCerts := map[string]struct{}{}
+	usedCerts := map[string]certOptions{}
+	earlyHints := []string{}
+	statusRewrites := []models.HTTPResponseRule{}
+	tlsSkipped := c.handleTLSSecretConflicts()
 
 	for _, namespace := range c.cfg.Namespace {
 		if !namespace.Relevant {
@@ -93,11 +145,17 @@ func (c *HAProxyController) updateHAProxy() error {
 					reload, err = c.handlePath(namespace, ingress, rule, path)
 					needsReload = needsReload || reload
 					utils.LogErr(err)
+					reload, err = c.handleCanary(namespace, ingress, rule, path)
+					needsReload = needsReload || reload
+					utils.LogErr(err)
 				}
 			}
 			//handle certs
 			ingressSecrets := map[string]struct{}{}
 			for _, tls := range ingress.TLS {
+				if _, skip := tlsSkipped[ingress.Namespace+"/"+ingress.Name][strings.ToLower(tls.Host)]; skip {
+					continue
+				}
 				if _, ok := ingressSecrets[tls.SecretName.Value]; !ok {
 					ingressSecrets[tls.SecretName.Value] = struct{}{}
 					reload = c.handleTLSSecret(*ingress, *tls, usedCerts)
@@ -106,8 +164,24 @@ func (c *HAProxyController) updateHAProxy() error {
 			}
 
 			reload, err = c.handleCaptureRequest(ingress, captureHosts)
-			utils.LogErr(err)
+			c.annotationErr(ingress, err)
+			needsReload = needsReload || reload
+
+			reload, err = c.handleJWTAuth(namespace, ingress)
+			c.annotationErr(ingress, err)
 			needsReload = needsReload || reload
+
+			reload, err = c.handleRateLimitWhitelist(namespace, ingress)
+			c.annotationErr(ingress, err)
+			needsReload = needsReload || reload
+
+			hints, errHints := c.ingressEarlyHints(ingress)
+			c.annotationErr(ingress, errHints)
+			earlyHints = append(earlyHints, hints...)
+
+			rewrites, errRewrite := c.ingressStatusRewrites(ingress)
+			c.annotationErr(ingress, errRewrite)
+			statusRewrites = append(statusRewrites, rewrites...)
 		}
 	}
 
@@ -117,22 +191,73 @@ func (c *HAProxyController) updateHAProxy() error {
 	reload = c.handleHTTPS(usedCerts)
 	needsReload = needsReload || reload
 
+	reload, err = c.handleCrtList(usedCerts)
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
 	reload, err = c.handleRateLimiting(c.cfg.HTTPS)
 	if err != nil {
 		return err
 	}
 	needsReload = needsReload || reload
 
+	reload, err = c.handleConnRateLimit()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleProxyProtocol()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleTLSHandshakeRateLimit()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
 	reload, err = c.handleHTTPRedirect(c.cfg.HTTPS)
 	if err != nil {
 		return err
 	}
 	needsReload = needsReload || reload
 
+	reload, err = c.handleFrontendOption("http-no-delay", FrontendHTTP, FrontendHTTPS)
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleResponseSetHeader()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleFrontendConfigSnippet()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleNormalizeURI()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleRestrictReqHdrNames()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleEarlyHints(earlyHints)
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleStatusRewrites(statusRewrites)
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleJWTCaptureClaims()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
 	reload, err = c.requestsTCPRefresh()
 	utils.LogErr(err)
 	needsReload = needsReload || reload
 
+	reload = c.handleIngressMatch()
+	needsReload = needsReload || reload
+
 	reload, err = c.RequestsHTTPRefresh()
 	utils.LogErr(err)
 	needsReload = needsReload || reload
@@ -141,6 +266,14 @@ func (c *HAProxyController) updateHAProxy() error {
 	utils.LogErr(err)
 	needsReload = needsReload || reload
 
+	reload, err = c.handleAcceptTuning()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
+	reload, err = c.handleTCPSmartOptions()
+	utils.LogErr(err)
+	needsReload = needsReload || reload
+
 	reload = c.refreshBackendSwitching()
 	needsReload = needsReload || reload
 
@@ -150,13 +283,21 @@ func (c *HAProxyController) updateHAProxy() error {
 		return err
 	}
 	c.cfg.Clean()
+	reload = c.handleConfigInclude()
+	if reload {
+		c.reloadEvents.addReason("config include")
+	}
+	needsReload = needsReload || reload
+	reloaded := false
 	if needsReload {
 		if err := c.HAProxyReload(); err != nil {
 			utils.LogErr(err)
 		} else {
 			log.Println("HAProxy reloaded")
+			reloaded = true
 		}
 	}
+	c.reportReload(reloaded)
 	return nil
 }
 
@@ -173,6 +314,34 @@ func (c *HAProxyController) handleMaxconn(maxconn *int64, frontends ...string) e
 	return nil
 }
 
+// handleFrontendOption enables or disables "option <name>" in frontends
+// according to the ConfigMap annotation of the same name.
+func (c *HAProxyController) handleFrontendOption(option string, frontends ...string) (needsReload bool, err error) {
+	annOption, errAnn := GetValueFromAnnotations(option, c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annOption.Status == EMPTY {
+		return false, nil
+	}
+	lines := []string{}
+	if annOption.Status != DELETED {
+		enabled, errBool := utils.GetBoolValue(annOption.Value, option)
+		if errBool != nil {
+			return false, fmt.Errorf("%s annotation: %s", option, errBool)
+		}
+		if enabled {
+			lines = append(lines, "option "+option)
+		}
+	}
+	for _, frontend := range frontends {
+		reload, errSet := c.sectionDirectivesSet(parser.Frontends, frontend, "option "+option, lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
 func (c *HAProxyController) handleGlobalAnnotations() (reloadRequested bool, err error) {
 	reloadRequested = false
 	maxProcs := goruntime.GOMAXPROCS(0)
@@ -267,34 +436,45 @@ func (c *HAProxyController) handleGlobalAnnotations() (reloadRequested bool, err
 
 // handles defaultBackned configured via cli param "default-backend-service"
 func (c *HAProxyController) handleDefaultService() (needsReload bool, err error) {
-	needsReload = false
+	namespace, ingress, path, err := c.defaultServicePath()
+	if err != nil || path == nil {
+		return false, err
+	}
+	return c.handlePath(namespace, ingress, &IngressRule{}, path)
+}
+
+// defaultServicePath returns the default backend path of "default-backend-service",
+// served like the default backend of an Ingress without annotations, so that
+// annotations of the Service (e.g. load-balance, timeouts, checks) apply to its backend.
+// path is nil when no default service is configured.
+func (c *HAProxyController) defaultServicePath() (namespace *Namespace, ingress *Ingress, path *IngressPath, err error) {
 	dsvcData, _ := GetValueFromAnnotations("default-backend-service")
 	dsvc := strings.Split(dsvcData.Value, "/")
 
 	if len(dsvc) != 2 {
-		return needsReload, fmt.Errorf("default service invalid data")
+		return nil, nil, nil, fmt.Errorf("default service invalid data")
 	}
 	if dsvc[0] == "" || dsvc[1] == "" {
-		return needsReload, nil
+		return nil, nil, nil, nil
 	}
 	namespace, ok := c.cfg.Namespace[dsvc[0]]
 	if !ok {
-		return needsReload, fmt.Errorf("default service invalid namespace " + dsvc[0])
+		return nil, nil, nil, fmt.Errorf("default service invalid namespace " + dsvc[0])
 	}
 	service, ok := namespace.Services[dsvc[1]]
 	if !ok {
-		return needsReload, fmt.Errorf("service '" + dsvc[1] + "' does not exist")
+		return nil, nil, nil, fmt.Errorf("service '" + dsvc[1] + "' does not exist")
 	}
-	ingress := &Ingress{
+	ingress = &Ingress{
 		Namespace:   namespace.Name,
 		Name:        "DefaultService",
 		Annotations: MapStringW{},
 		Rules:       map[string]*IngressRule{},
 	}
-	path := &IngressPath{
+	path = &IngressPath{
 		ServiceName:      service.Name,
 		ServicePortInt:   service.Ports[0].Port,
 		IsDefaultBackend: true,
 	}
-	return c.handlePath(namespace, ingress, &IngressRule{}, path)
+	return namespace, ingress, path, nil
 }
@@ -0,0 +1,69 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleFrontendOption(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+		err      bool
+	}{
+		{"true", true, false},
+		{"false", false, false},
+		{"maybe", false, true},
+	}
+	for _, test := range tests {
+		c, cleanup := newTestController(t)
+		c.cfg.ConfigMap.Annotations = MapStringW{"http-no-delay": &StringW{Value: test.value, Status: ADDED}}
+		var err error
+		config := c.testSync(t, func() {
+			_, err = c.handleFrontendOption("http-no-delay", FrontendHTTP, FrontendHTTPS)
+		})
+		cleanup()
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t, got %v", test.value, test.err, err)
+		}
+		for _, frontend := range []string{"frontend http", "frontend https"} {
+			if testSectionHas(config, frontend, "option http-no-delay") != test.expected {
+				t.Errorf("%s: %s: expected option %t:\n%s", test.value, frontend, test.expected, config)
+			}
+		}
+	}
+}
+
+func TestHandleFrontendOptionDeleted(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{"http-no-delay": &StringW{Value: "true", Status: ADDED}}
+	c.testSync(t, func() {
+		if reload, _ := c.handleFrontendOption("http-no-delay", FrontendHTTP, FrontendHTTPS); !reload {
+			t.Error("expected reload")
+		}
+	})
+	c.cfg.ConfigMap.Annotations["http-no-delay"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleFrontendOption("http-no-delay", FrontendHTTP, FrontendHTTPS); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "http-no-delay") {
+		t.Errorf("expected option removed:\n%s", config)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/haproxytech/config-parser/v2/params"
+)
+
+// hashKeys are the values accepted by "hash-key" server keyword
+var hashKeys = map[string]struct{}{
+	"id":        struct{}{},
+	"addr":      struct{}{},
+	"addr-port": struct{}{},
+}
+
+// handleBackendHashKey sets the key used to place servers on the consistent hashing
+// ring with "default-server hash-key <key>" in the backend of a service.
+func (c *HAProxyController) handleBackendHashKey(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	options := []params.ServerOption{}
+	annHashKey, _ := GetValueFromAnnotations("hash-key", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annHashKey != nil && annHashKey.Status != DELETED && c.featureSupported("hash-key") {
+		if _, ok := hashKeys[annHashKey.Value]; !ok {
+			err = fmt.Errorf("hash-key annotation: incorrect value '%s', expected id, addr or addr-port", annHashKey.Value)
+		} else {
+			options = append(options, &params.ServerOptionValue{Name: "hash-key", Value: annHashKey.Value})
+		}
+	}
+	needsReload, errSet := c.backendDefaultServerSet(backendName, []string{"hash-key"}, options)
+	if errSet != nil {
+		return false, errSet
+	}
+	if needsReload {
+		c.ActiveTransactionHasChanges = true
+	}
+	return needsReload, err
+}
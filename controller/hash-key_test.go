@@ -0,0 +1,113 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendHashKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+	}{
+		{
+			name:        "hash-key",
+			annotations: map[string]string{"hash-key": "addr"},
+			expected:    "default-server hash-key addr",
+		},
+		{
+			name:        "hash-key with eject",
+			annotations: map[string]string{"hash-key": "addr-port", "eject-5xx": "10", "check": "true"},
+			expected:    "default-server hash-key addr-port observe layer7 error-limit 10 on-error mark-down",
+		},
+		{
+			name:        "id read back as option",
+			annotations: map[string]string{"hash-key": "id", "eject-5xx": "3", "check": "true"},
+			expected:    "default-server hash-key id observe layer7 error-limit 3 on-error mark-down",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.haproxyVersion = HAProxyVersion{Major: 2, Minor: 6}
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			// the configuration file is read again by the second sync
+			for sync := 1; sync <= 2; sync++ {
+				config := c.testSync(t, func() {
+					reload, err := c.handleBackendHashKey(ingress, service, "default-app-80")
+					if err != nil {
+						t.Fatal(err)
+					}
+					if reload != (sync == 1) {
+						t.Errorf("sync %d: expected reload %t, got %t", sync, sync == 1, reload)
+					}
+					if _, err = c.handleBackendEject(ingress, service, "default-app-80"); err != nil {
+						t.Fatal(err)
+					}
+				})
+				if line := defaultServerLine(t, config, "default-app-80"); line != test.expected {
+					t.Errorf("sync %d: expected '%s', got '%s'", sync, test.expected, line)
+				}
+			}
+		})
+	}
+}
+
+func TestHandleBackendHashKeyRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{Major: 2, Minor: 6}
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"hash-key": "addr"})}
+	c.testSync(t, func() {
+		if _, err := c.handleBackendHashKey(ingress, service, "default-app-80"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	service.Annotations["hash-key"].Status = DELETED
+	config := c.testSync(t, func() {
+		reload, err := c.handleBackendHashKey(ingress, service, "default-app-80")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reload {
+			t.Error("expected reload when hash-key is removed")
+		}
+	})
+	if strings.Contains(config, "default-server") {
+		t.Errorf("expected no default-server line, got:\n%s", config)
+	}
+}
+
+func TestHandleBackendHashKeyUnsupported(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{Major: 2, Minor: 4}
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"hash-key": "addr"})}
+	config := c.testSync(t, func() {
+		if _, err := c.handleBackendHashKey(ingress, service, "default-app-80"); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "hash-key") {
+		t.Errorf("expected no hash-key with HAProxy 2.4, got:\n%s", config)
+	}
+}
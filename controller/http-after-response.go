@@ -0,0 +1,57 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// handleResponseSetHeader configures "http-after-response set-header" rules in HTTP frontends.
+// Unlike "http-response" rules, those are also applied to responses generated
+// by HAProxy itself (redirects, errors, ...).
+func (c *HAProxyController) handleResponseSetHeader() (needsReload bool, err error) {
+	annResponseSetHeader, errAnn := GetValueFromAnnotations("response-set-header", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annResponseSetHeader.Status == EMPTY {
+		return false, nil
+	}
+	rules := []string{}
+	if annResponseSetHeader.Status != DELETED && c.featureSupported("http-after-response") {
+		for _, param := range strings.Split(annResponseSetHeader.Value, "\n") {
+			param = strings.TrimSpace(param)
+			if param == "" {
+				continue
+			}
+			parts := strings.SplitN(param, " ", 2)
+			if len(parts) != 2 || strings.TrimSpace(parts[1]) == "" {
+				utils.LogErr(fmt.Errorf("response-set-header: incorrect param '%s', expected '<name> <fmt>'", param))
+				continue
+			}
+			rules = append(rules, fmt.Sprintf("http-after-response set-header %s %s", parts[0], strings.TrimSpace(parts[1])))
+		}
+	}
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		reload, errSet := c.sectionDirectivesSet(parser.Frontends, frontend, "http-after-response set-header", rules)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
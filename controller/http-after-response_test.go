@@ -0,0 +1,77 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleResponseSetHeader(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{"response-set-header": &StringW{
+		Value:  "X-Frame-Options DENY\nStrict-Transport-Security  max-age=31536000\nX-Invalid",
+		Status: ADDED,
+	}}
+	config := c.testSync(t, func() {
+		reload, err := c.handleResponseSetHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reload {
+			t.Error("expected reload")
+		}
+	})
+	// http-after-response rules apply to backend responses and to the ones generated by HAProxy
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		for _, expected := range []string{
+			"http-after-response set-header X-Frame-Options DENY",
+			"http-after-response set-header Strict-Transport-Security max-age=31536000",
+		} {
+			if !testSectionHas(config, frontend, expected) {
+				t.Errorf("%s: expected '%s':\n%s", frontend, expected, config)
+			}
+		}
+	}
+	if strings.Contains(config, "X-Invalid") {
+		t.Errorf("expected invalid header to be skipped:\n%s", config)
+	}
+
+	c.cfg.ConfigMap.Annotations["response-set-header"].Status = DELETED
+	config = c.testSync(t, func() {
+		if _, err := c.handleResponseSetHeader(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "http-after-response") {
+		t.Errorf("expected rules removed:\n%s", config)
+	}
+}
+
+func TestHandleResponseSetHeaderOldVersion(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{2, 1, 0}
+	c.cfg.ConfigMap.Annotations = MapStringW{"response-set-header": &StringW{Value: "X-Frame-Options DENY", Status: ADDED}}
+	config := c.testSync(t, func() {
+		if _, err := c.handleResponseSetHeader(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "http-after-response") {
+		t.Errorf("expected no rule with HAProxy 2.1:\n%s", config)
+	}
+}
@@ -26,8 +26,8 @@ import (
 	"github.com/haproxytech/models"
 )
 
-func (c *HAProxyController) cleanCertDir(usedCerts map[string]struct{}) error {
-	files, err := ioutil.ReadDir(HAProxyCertDir)
+func (c *HAProxyController) cleanCertDir(certDir string, usedCerts map[string]certOptions) error {
+	files, err := ioutil.ReadDir(certDir)
 	if err != nil {
 		return err
 	}
@@ -36,7 +36,7 @@ func (c *HAProxyController) cleanCertDir(usedCerts map[string]struct{}) error {
 		if f.IsDir() {
 			continue
 		}
-		filename := path.Join(HAProxyCertDir, f.Name())
+		filename := path.Join(certDir, f.Name())
 		_, isOK := usedCerts[filename]
 		if !isOK {
 			os.Remove(filename)
@@ -80,8 +80,22 @@ func (c *HAProxyController) writeCert(filename string, key, crt []byte) error {
 	return nil
 }
 
-func (c *HAProxyController) handleSecret(ingress Ingress, secret Secret, writeSecret bool, certs map[string]struct{}) (reloadRequested bool) {
+// certOptions are the per certificate settings, certificates with options
+// are loaded via the crt-list of HTTPS binds, see handleCrtList
+type certOptions struct {
+	Alpn string
+}
+
+func (c *HAProxyController) handleSecret(ingress Ingress, secret Secret, writeSecret bool, certs map[string]certOptions) (reloadRequested bool) {
 	reloadRequested = false
+	options := certOptions{}
+	if annAlpn, err := GetValueFromAnnotations("alpn", ingress.Annotations); err == nil && annAlpn.Status != DELETED {
+		options.Alpn = annAlpn.Value
+	}
+	certDir := HAProxyCertDir
+	if options != (certOptions{}) {
+		certDir = crtListCertDir()
+	}
 	//two options are allowed, tls, rsa+ecdsa
 	rsaKey, rsaKeyOK := secret.Data["rsa.key"]
 	rsaCrt, rsaCrtOK := secret.Data["rsa.crt"]
@@ -90,52 +104,62 @@ func (c *HAProxyController) handleSecret(ingress Ingress, secret Secret, writeSe
 	//log.Println(secretName.Value, rsaCrtOK, rsaKeyOK, ecdsaCrtOK, ecdsaKeyOK)
 	if rsaKeyOK && rsaCrtOK || ecdsaKeyOK && ecdsaCrtOK {
 		if rsaKeyOK && rsaCrtOK {
-			filename := path.Join(HAProxyCertDir, fmt.Sprintf("%s_%s_%s.pem.rsa", secret.Namespace, ingress.Name, secret.Name))
-			if writeSecret {
-				errCrt := c.writeCert(filename, rsaKey, rsaCrt)
+			filename := path.Join(certDir, fmt.Sprintf("%s_%s_%s.pem.rsa", secret.Namespace, ingress.Name, secret.Name))
+			if writeSecret || certMissing(filename, secret) {
+				reload, errCrt := c.storeCert(filename, rsaKey, rsaCrt)
 				if errCrt != nil {
 					err1 := c.removeHTTPSListeners()
 					utils.LogErr(err1)
 					return false
 				}
-				reloadRequested = true
+				reloadRequested = reloadRequested || reload
 			}
-			certs[filename] = struct{}{}
+			certs[filename] = options
 		}
 		if ecdsaKeyOK && ecdsaCrtOK {
-			filename := path.Join(HAProxyCertDir, fmt.Sprintf("%s_%s_%s.pem.ecdsa", secret.Namespace, ingress.Name, secret.Name))
-			if writeSecret {
-				errCrt := c.writeCert(filename, ecdsaKey, ecdsaCrt)
+			filename := path.Join(certDir, fmt.Sprintf("%s_%s_%s.pem.ecdsa", secret.Namespace, ingress.Name, secret.Name))
+			if writeSecret || certMissing(filename, secret) {
+				reload, errCrt := c.storeCert(filename, ecdsaKey, ecdsaCrt)
 				if errCrt != nil {
 					err1 := c.removeHTTPSListeners()
 					utils.LogErr(err1)
 					return false
 				}
-				reloadRequested = true
+				reloadRequested = reloadRequested || reload
 			}
-			certs[filename] = struct{}{}
+			certs[filename] = options
 		}
 	} else {
 		tlsKey, tlsKeyOK := secret.Data["tls.key"]
 		tlsCrt, tlsCrtOK := secret.Data["tls.crt"]
 		if tlsKeyOK && tlsCrtOK {
-			filename := path.Join(HAProxyCertDir, fmt.Sprintf("%s_%s_%s.pem", secret.Namespace, ingress.Name, secret.Name))
-			if writeSecret {
-				errCrt := c.writeCert(filename, tlsKey, tlsCrt)
+			filename := path.Join(certDir, fmt.Sprintf("%s_%s_%s.pem", secret.Namespace, ingress.Name, secret.Name))
+			if writeSecret || certMissing(filename, secret) {
+				reload, errCrt := c.storeCert(filename, tlsKey, tlsCrt)
 				if errCrt != nil {
 					err1 := c.removeHTTPSListeners()
 					utils.LogErr(err1)
 					return false
 				}
-				reloadRequested = true
+				reloadRequested = reloadRequested || reload
 			}
-			certs[filename] = struct{}{}
+			certs[filename] = options
 		}
 	}
 	return reloadRequested
 }
 
-func (c *HAProxyController) handleDefaultCertificate(certs map[string]struct{}) (reloadRequested bool) {
+// certMissing is true when the certificate of an unchanged secret is not on disk,
+// ie when it moved between certificate directories
+func certMissing(filename string, secret Secret) bool {
+	if secret.Status == DELETED {
+		return false
+	}
+	_, err := os.Stat(filename)
+	return os.IsNotExist(err)
+}
+
+func (c *HAProxyController) handleDefaultCertificate(certs map[string]certOptions) (reloadRequested bool) {
 	reloadRequested = false
 	secretAnn, defSecretErr := GetValueFromAnnotations("ssl-certificate", c.cfg.ConfigMap.Annotations)
 	writeSecret := true
@@ -161,7 +185,7 @@ func (c *HAProxyController) handleDefaultCertificate(certs map[string]struct{})
 	return false
 }
 
-func (c *HAProxyController) handleTLSSecret(ingress Ingress, tls IngressTLS, certs map[string]struct{}) (reloadRequested bool) {
+func (c *HAProxyController) handleTLSSecret(ingress Ingress, tls IngressTLS, certs map[string]certOptions) (reloadRequested bool) {
 	reloadRequested = false
 	secretData := strings.Split(tls.SecretName.Value, "/")
 	namespaceName := ingress.Namespace
@@ -196,17 +220,19 @@ func (c *HAProxyController) handleTLSSecret(ingress Ingress, tls IngressTLS, cer
 	return c.handleSecret(ingress, *secret, writeSecret, certs)
 }
 
-func (c *HAProxyController) handleHTTPS(usedCerts map[string]struct{}) (reloadRequested bool) {
+func (c *HAProxyController) handleHTTPS(usedCerts map[string]certOptions) (reloadRequested bool) {
 	// ssl-passthrough
 	if len(c.cfg.BackendSwitchingRules[FrontendSSL]) > 0 {
 		if !c.cfg.SSLPassthrough {
 			utils.PanicErr(c.enableSSLPassthrough())
 			c.cfg.SSLPassthrough = true
+			c.cfg.TCPRequestsStatus = MODIFIED
 			reloadRequested = true
 		}
 	} else if c.cfg.SSLPassthrough {
 		utils.PanicErr(c.disableSSLPassthrough())
 		c.cfg.SSLPassthrough = false
+		c.cfg.TCPRequestsStatus = MODIFIED
 		reloadRequested = true
 	}
 	// ssl-offload
@@ -222,7 +248,7 @@ func (c *HAProxyController) handleHTTPS(usedCerts map[string]struct{}) (reloadRe
 		reloadRequested = true
 	}
 	//remove certs that are not needed
-	utils.LogErr(c.cleanCertDir(usedCerts))
+	utils.LogErr(c.cleanCertDir(HAProxyCertDir, usedCerts))
 
 	return reloadRequested
 }
@@ -0,0 +1,86 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// ingressMatchVar is the variable holding <namespace>/<ingress> of the use_backend rule
+// matching the request, see handleIngressMatch
+const ingressMatchVar = "ingress"
+
+// handleIngressMatch sets, before any other http-request rule of HTTP frontends, the ingress
+// of the use_backend rule that will route the request, so that rules of an ingress (JWT, rate
+// limit whitelist, status rewrites...) only apply to its requests. The conditions of use_backend
+// rules overlap, path_beg of "/api" also matches "/api/v2" of another ingress, so they are
+// evaluated in the same order as use_backend rules and the first match wins.
+func (c *HAProxyController) handleIngressMatch() (needsReload bool) {
+	// rules lost by a TLS mode conflict are not evaluated
+	c.handleTLSConflicts()
+	maxRules, _ := c.maxRulesPerFrontend()
+	decoded, _ := c.pathMatchDecoded()
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		useBackendRules := c.cfg.BackendSwitchingRules[frontend]
+		rules := []models.HTTPRequestRule{}
+		created, _ := c.createdUseBackendKeys(frontend, "http", useBackendRules, sortedUseBackendKeys(useBackendRules), maxRules)
+		for _, key := range created {
+			rule := useBackendRules[key]
+			rules = append(rules, models.HTTPRequestRule{
+				ID:       utils.PtrInt64(0),
+				Type:     "set-var",
+				VarScope: "txn",
+				VarName:  ingressMatchVar,
+				VarExpr:  fmt.Sprintf("str(%s/%s)", rule.Namespace, rule.Ingress),
+				Cond:     "if",
+				CondTest: fmt.Sprintf("%s !{ var(txn.%s) -m found }", useBackendCond(rule, decoded), ingressMatchVar),
+			})
+		}
+		// use_backend rules are inserted on top, the last created one is evaluated first
+		for i, j := 0, len(rules)-1; i < j; i, j = i+1, j-1 {
+			rules[i], rules[j] = rules[j], rules[i]
+		}
+		if len(rules) == 0 && len(c.cfg.IngressMatchRules[frontend]) == 0 {
+			continue
+		}
+		if reflect.DeepEqual(rules, c.cfg.IngressMatchRules[frontend]) {
+			continue
+		}
+		c.cfg.IngressMatchRules[frontend] = rules
+		c.cfg.HTTPRequestsStatus = MODIFIED
+		needsReload = true
+	}
+	return needsReload
+}
+
+// ingressMatchConds returns the conditions matching requests routed to the paths of an ingress
+// by use_backend rules, none if the ingress has no rule in HTTP frontends
+func (c *HAProxyController) ingressMatchConds(ingress *Ingress) (conds []string) {
+	if ingress.Status == DELETED {
+		return nil
+	}
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		for _, rule := range c.cfg.BackendSwitchingRules[frontend] {
+			if rule.Namespace == ingress.Namespace && rule.Ingress == ingress.Name {
+				return []string{fmt.Sprintf("{ var(txn.%s) -m str %s/%s }", ingressMatchVar, ingress.Namespace, ingress.Name)}
+			}
+		}
+	}
+	return nil
+}
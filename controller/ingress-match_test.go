@@ -0,0 +1,77 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestHandleIngressMatch(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.addUseBackendRule("Rdefaultaexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-app-80", Namespace: "default", Ingress: "a"}, FrontendHTTP, FrontendHTTPS)
+	c.addUseBackendRule("Rdefaultbexample.com/api/v2", UseBackendRule{Host: "example.com", Path: "/api/v2", Backend: "default-app-80", Namespace: "default", Ingress: "b"}, FrontendHTTP, FrontendHTTPS)
+	config := c.testSync(t, func() {
+		if !c.handleIngressMatch() {
+			t.Error("expected reload")
+		}
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+		c.refreshBackendSwitching()
+	})
+	expected := []string{
+		"http-request set-var(txn.ingress) str(default/b) if { req.hdr(host) -i example.com } { path_beg /api/v2 } !{ var(txn.ingress) -m found }",
+		"http-request set-var(txn.ingress) str(default/a) if { req.hdr(host) -i example.com } { path_beg /api } !{ var(txn.ingress) -m found }",
+		"use_backend default-app-80 if { req.hdr(host) -i example.com } { path_beg /api/v2 }",
+		"use_backend default-app-80 if { req.hdr(host) -i example.com } { path_beg /api }",
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		index := 0
+		for _, line := range testSection(config, frontend) {
+			if index < len(expected) && line == expected[index] {
+				index++
+			}
+		}
+		if index != len(expected) {
+			t.Errorf("%s: expected rules in order:\n%v\ngot:\n%s", frontend, expected, config)
+		}
+	}
+	// unchanged rules
+	c.testSync(t, func() {
+		if c.handleIngressMatch() {
+			t.Error("expected no reload")
+		}
+	})
+}
+
+func TestIngressMatchConds(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	a := c.testIngress("default", "a")
+	b := c.testIngress("default", "b")
+	c.addUseBackendRule("Rdefaultaexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-app-80", Namespace: "default", Ingress: "a"}, FrontendHTTP, FrontendHTTPS)
+	conds := c.ingressMatchConds(a)
+	if len(conds) != 1 || conds[0] != "{ var(txn.ingress) -m str default/a }" {
+		t.Errorf("unexpected conditions %v", conds)
+	}
+	if conds = c.ingressMatchConds(b); len(conds) != 0 {
+		t.Errorf("expected no condition for ingress without rules, got %v", conds)
+	}
+	a.Status = DELETED
+	if conds = c.ingressMatchConds(a); len(conds) != 0 {
+		t.Errorf("expected no condition for deleted ingress, got %v", conds)
+	}
+}
@@ -0,0 +1,261 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// jwtClaimRegexp matches claim paths of "jwt-capture-claims", nested claims are separated by dots
+var jwtClaimRegexp = regexp.MustCompile(`^[A-Za-z0-9_-]+(\.[A-Za-z0-9_-]+)*$`)
+
+// handleJWTCaptureClaims decodes the payload of the bearer token of Authorization header
+// in HTTP frontends and stores each claim of "jwt-capture-claims" annotation in a
+// "txn.jwt_<claim>" variable, also captured so that it is logged (%hr).
+// The token is not verified, claims are only meant for logging and routing decisions.
+func (c *HAProxyController) handleJWTCaptureClaims() (needsReload bool, err error) {
+	annClaims, errAnn := GetValueFromAnnotations("jwt-capture-claims", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annClaims.Status == EMPTY {
+		return false, nil
+	}
+	rules := []models.HTTPRequestRule{}
+	if annClaims.Status != DELETED && c.featureSupported("jwt") {
+		claims, errParse := parseJWTClaims(annClaims.Value)
+		if errParse != nil {
+			return false, fmt.Errorf("jwt-capture-claims annotation: %s", errParse)
+		}
+		for _, claim := range claims {
+			varName := jwtClaimVar(claim)
+			rules = append(rules, models.HTTPRequestRule{
+				ID:       utils.PtrInt64(0),
+				Type:     "set-var",
+				VarScope: "txn",
+				VarName:  varName,
+				VarExpr:  fmt.Sprintf("http_auth_bearer,jwt_payload_query('$.%s')", claim),
+			}, models.HTTPRequestRule{
+				ID:            utils.PtrInt64(0),
+				Type:          "capture",
+				CaptureSample: fmt.Sprintf("var(txn.%s)", varName),
+				CaptureLen:    defaultCaptureLen,
+			})
+		}
+	}
+	c.cfg.HTTPRequests[JWT_CAPTURE] = rules
+	c.cfg.HTTPRequestsStatus = MODIFIED
+	return true, nil
+}
+
+// parseJWTClaims validates a comma or new line separated list of claim paths, e.g. "sub,realm_access.roles"
+func parseJWTClaims(value string) (claims []string, err error) {
+	for _, claim := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		claim = strings.TrimPrefix(strings.TrimSpace(claim), "$.")
+		if claim == "" {
+			continue
+		}
+		if !jwtClaimRegexp.MatchString(claim) {
+			return nil, fmt.Errorf("incorrect claim path '%s'", claim)
+		}
+		claims = append(claims, claim)
+	}
+	if len(claims) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return claims, nil
+}
+
+// jwtClaimVar returns the name of the variable holding a claim, e.g. "jwt_realm_access_roles"
+func jwtClaimVar(claim string) string {
+	return "jwt_" + strings.NewReplacer(".", "_", "-", "_").Replace(claim)
+}
+
+// jwtAlgorithms are the asymmetric algorithms accepted by "jwt-algorithm", the token
+// is verified with the public key of "jwt-key-secret", HMAC secrets are not supported
+// to keep them out of HAProxy configuration.
+var jwtAlgorithms = map[string]struct{}{
+	"RS256": {}, "RS384": {}, "RS512": {},
+	"ES256": {}, "ES384": {}, "ES512": {},
+	"PS256": {}, "PS384": {}, "PS512": {},
+}
+
+// jwtKeySecretKey is the key of "jwt-key-secret" secret holding the PEM public key or certificate
+const jwtKeySecretKey = "key.pem"
+
+// handleJWTAuth denies with 401 requests of the ingress paths when "auth-type" is "jwt"
+// and the bearer token is missing, its signature does not match the public key of
+// "jwt-key-secret", it has no expiration or is expired or its "iss"/"aud" claims differ from
+// "jwt-issuer"/"jwt-audience" ("aud" may also be an array containing "jwt-audience").
+// Rules of an ingress are enabled by a per ingress variable set for the requests it routes.
+// Keys are only read from secrets, fetching a JWKS from an URL is not supported.
+func (c *HAProxyController) handleJWTAuth(namespace *Namespace, ingress *Ingress) (needsReload bool, err error) {
+	key := fmt.Sprintf("JWT-%s-%s", namespace.Name, ingress.Name)
+	file := path.Join(HAProxyJWTDir, fmt.Sprintf("%s_%s.pem", namespace.Name, ingress.Name))
+	rules := []models.HTTPRequestRule{}
+	defer func() {
+		needsReload = c.setHTTPRequests(key, rules) || needsReload
+		if len(rules) == 0 {
+			if errRemove := os.Remove(file); errRemove != nil && !os.IsNotExist(errRemove) {
+				utils.LogErr(errRemove)
+			}
+		}
+	}()
+	annAuthType, _ := GetValueFromAnnotations("auth-type", ingress.Annotations)
+	if ingress.Status == DELETED || annAuthType == nil || annAuthType.Status == DELETED {
+		return false, nil
+	}
+	varName := "jwt_auth_" + strings.NewReplacer("-", "_", ".", "_").Replace(namespace.Name+"_"+ingress.Name)
+	for _, cond := range c.ingressMatchConds(ingress) {
+		rules = append(rules, models.HTTPRequestRule{
+			ID:       utils.PtrInt64(0),
+			Type:     "set-var",
+			VarScope: "txn",
+			VarName:  varName,
+			VarExpr:  "bool(true)",
+			Cond:     "if",
+			CondTest: cond,
+		})
+	}
+	if len(rules) == 0 {
+		return false, nil
+	}
+	enabled := fmt.Sprintf("{ var(txn.%s) -m bool }", varName)
+	denyIf := func(condTest string) models.HTTPRequestRule {
+		return models.HTTPRequestRule{
+			ID:         utils.PtrInt64(0),
+			Type:       "deny",
+			DenyStatus: 401,
+			Cond:       "if",
+			CondTest:   strings.TrimSpace(enabled + " " + condTest),
+		}
+	}
+	authRules, reload, err := c.jwtAuthRules(namespace, ingress, file, enabled, denyIf)
+	if err != nil {
+		// fail closed, a misconfigured ingress is not exposed without authentication
+		rules = append(rules, denyIf(""))
+		return reload, err
+	}
+	rules = append(rules, authRules...)
+	return reload, nil
+}
+
+// jwtAuthRules validates JWT annotations of an ingress, writes the public key file and
+// returns the rules checking the token of requests for which "enabled" condition is true.
+func (c *HAProxyController) jwtAuthRules(namespace *Namespace, ingress *Ingress, file string, enabled string,
+	denyIf func(condTest string) models.HTTPRequestRule) (rules []models.HTTPRequestRule, needsReload bool, err error) {
+	annAuthType, _ := GetValueFromAnnotations("auth-type", ingress.Annotations)
+	if annAuthType.Value != "jwt" {
+		return nil, false, fmt.Errorf("auth-type annotation: incorrect value '%s', expected 'jwt'", annAuthType.Value)
+	}
+	if !c.featureSupported("jwt") {
+		return nil, false, fmt.Errorf("auth-type annotation: JWT validation is not supported by HAProxy %s", c.haproxyVersion)
+	}
+	algorithm := "RS256"
+	if annAlg, _ := GetValueFromAnnotations("jwt-algorithm", ingress.Annotations); annAlg != nil && annAlg.Status != DELETED {
+		algorithm = strings.TrimSpace(annAlg.Value)
+		if _, ok := jwtAlgorithms[algorithm]; !ok {
+			return nil, false, fmt.Errorf("jwt-algorithm annotation: unsupported algorithm '%s'", algorithm)
+		}
+	}
+	claims := map[string]string{}
+	for _, claim := range []string{"issuer", "audience"} {
+		ann, _ := GetValueFromAnnotations("jwt-"+claim, ingress.Annotations)
+		if ann == nil || ann.Status == DELETED || ann.Value == "" {
+			continue
+		}
+		value := strings.TrimSpace(ann.Value)
+		if strings.ContainsAny(value, " \t\n'\"") {
+			return nil, false, fmt.Errorf("jwt-%s annotation: incorrect value '%s'", claim, ann.Value)
+		}
+		claims[claim] = value
+	}
+	annSecret, _ := GetValueFromAnnotations("jwt-key-secret", ingress.Annotations)
+	if annSecret == nil || annSecret.Status == DELETED {
+		return nil, false, fmt.Errorf("auth-type annotation: jwt-key-secret is not set")
+	}
+	publicKey, err := c.jwtPublicKey(namespace, annSecret.Value)
+	if err != nil {
+		return nil, false, fmt.Errorf("jwt-key-secret annotation: %s", err)
+	}
+	if current, errRead := ioutil.ReadFile(file); errRead != nil || !bytes.Equal(current, publicKey) {
+		if err = ioutil.WriteFile(file, publicKey, 0644); err != nil {
+			return nil, false, fmt.Errorf("jwt-key-secret annotation: %s", err)
+		}
+		needsReload = true
+	}
+
+	rules = append(rules,
+		denyIf(fmt.Sprintf("!{ http_auth_bearer,jwt_header_query('$.alg') -m str %s }", algorithm)),
+		denyIf(fmt.Sprintf("!{ http_auth_bearer,jwt_verify(%s,%s) -m int 1 }", algorithm, file)),
+		models.HTTPRequestRule{
+			ID:       utils.PtrInt64(0),
+			Type:     "set-var",
+			VarScope: "txn",
+			VarName:  "jwt_now",
+			VarExpr:  "date()",
+			Cond:     "if",
+			CondTest: enabled,
+		},
+		// a token without expiration would be valid forever
+		denyIf("!{ http_auth_bearer,jwt_payload_query('$.exp','int') -m found }"),
+		denyIf("{ http_auth_bearer,jwt_payload_query('$.exp','int'),sub(txn.jwt_now) -m int lt 0 }"),
+	)
+	if issuer, ok := claims["issuer"]; ok {
+		rules = append(rules, denyIf(fmt.Sprintf("!{ http_auth_bearer,jwt_payload_query('$.iss') -m str %s }", issuer)))
+	}
+	if audience, ok := claims["audience"]; ok {
+		// "aud" is either a string or an array of strings, returned as JSON by jwt_payload_query
+		rules = append(rules, denyIf(fmt.Sprintf("!{ http_auth_bearer,jwt_payload_query('$.aud') -m str %s } !{ http_auth_bearer,jwt_payload_query('$.aud') -m sub '\"%s\"' }", audience, audience)))
+	}
+	return rules, needsReload, nil
+}
+
+// jwtPublicKey returns the PEM key of the secret (<namespace>/<name>) referenced by
+// jwt-key-secret annotation, namespace defaults to the one of the ingress.
+func (c *HAProxyController) jwtPublicKey(namespace *Namespace, value string) ([]byte, error) {
+	nsName := namespace.Name
+	name := strings.TrimSpace(value)
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 {
+		nsName, name = parts[0], parts[1]
+	}
+	if name == "" || nsName == "" {
+		return nil, fmt.Errorf("expected <namespace>/<secret>, got '%s'", value)
+	}
+	if nsName != namespace.Name && !c.osArgs.AllowCrossNamespace {
+		return nil, fmt.Errorf("secret '%s' is in namespace '%s', cross namespace references are not allowed", name, nsName)
+	}
+	ns, ok := c.cfg.Namespace[nsName]
+	if !ok {
+		return nil, fmt.Errorf("namespace '%s' is not watched by the controller", nsName)
+	}
+	secret, ok := ns.Secret[name]
+	if !ok || secret.Status == DELETED {
+		return nil, fmt.Errorf("secret '%s/%s' does not exist", nsName, name)
+	}
+	publicKey, ok := secret.Data[jwtKeySecretKey]
+	if !ok || !bytes.Contains(publicKey, []byte("-----BEGIN ")) {
+		return nil, fmt.Errorf("secret '%s/%s' has no PEM '%s' key", nsName, name, jwtKeySecretKey)
+	}
+	return publicKey, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJWTClaims(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected []string
+		err      bool
+	}{
+		{"sub", []string{"sub"}, false},
+		{"sub, $.iss\nrealm_access.roles", []string{"sub", "iss", "realm_access.roles"}, false},
+		{"sub,,", []string{"sub"}, false},
+		{"sub iss", nil, true},
+		{"sub,realm_access..roles", nil, true},
+		{" , ", nil, true},
+	}
+	for _, test := range tests {
+		claims, err := parseJWTClaims(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("%q: expected error %t, got %v", test.value, test.err, err)
+		}
+		if !reflect.DeepEqual(claims, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.value, test.expected, claims)
+		}
+	}
+}
+
+func TestHandleJWTCaptureClaims(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{2, 6, 0}
+	c.cfg.ConfigMap.Annotations = MapStringW{"jwt-capture-claims": &StringW{Value: "sub, realm_access.roles", Status: ADDED}}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleJWTCaptureClaims(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	expected := []string{
+		"http-request set-var(txn.jwt_sub) http_auth_bearer,jwt_payload_query('$.sub')",
+		"http-request capture var(txn.jwt_sub) len 128",
+		"http-request set-var(txn.jwt_realm_access_roles) http_auth_bearer,jwt_payload_query('$.realm_access.roles')",
+		"http-request capture var(txn.jwt_realm_access_roles) len 128",
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		got := []string{}
+		for _, line := range testSection(config, frontend) {
+			if strings.Contains(line, "jwt_") {
+				got = append(got, line)
+			}
+		}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", frontend, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	c.cfg.ConfigMap.Annotations["jwt-capture-claims"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleJWTCaptureClaims(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "jwt_") {
+		t.Errorf("expected no claim capture:\n%s", config)
+	}
+}
+
+func TestHandleJWTCaptureClaimsUnsupported(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{2, 4, 0}
+	c.cfg.ConfigMap.Annotations = MapStringW{"jwt-capture-claims": &StringW{Value: "sub", Status: ADDED}}
+	config := c.testSync(t, func() {
+		if _, err := c.handleJWTCaptureClaims(); err != nil {
+			t.Error(err)
+		}
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "jwt_") {
+		t.Errorf("expected no claim capture before HAProxy 2.5:\n%s", config)
+	}
+	c.haproxyVersion = HAProxyVersion{2, 6, 0}
+	c.cfg.ConfigMap.Annotations["jwt-capture-claims"] = &StringW{Value: "sub iss", Status: MODIFIED}
+	c.testSync(t, func() {
+		if _, err := c.handleJWTCaptureClaims(); err == nil {
+			t.Error("expected error")
+		}
+	})
+}
+
+// testJWTAuth returns the conditions of the JWT rules of ingress default/a routing /api
+func testJWTAuth(t *testing.T, annotations map[string]string, secret bool) (conds []string, err error) {
+	dir, errDir := ioutil.TempDir("", "haproxy-ingress-jwt")
+	if errDir != nil {
+		t.Fatal(errDir)
+	}
+	defer os.RemoveAll(dir)
+	jwtDir := HAProxyJWTDir
+	HAProxyJWTDir = dir
+	defer func() { HAProxyJWTDir = jwtDir }()
+
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := c.testIngress("default", "a")
+	ingress.Annotations = testAnnotations(annotations)
+	namespace := c.cfg.GetNamespace("default")
+	if secret {
+		namespace.Secret["issuer-key"] = &Secret{Name: "issuer-key", Namespace: "default", Data: map[string][]byte{
+			"key.pem": []byte("-----BEGIN PUBLIC KEY-----\nMFkw\n-----END PUBLIC KEY-----\n"),
+		}}
+	}
+	c.addUseBackendRule("Rdefaultaexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-app-80", Namespace: "default", Ingress: "a"}, FrontendHTTP, FrontendHTTPS)
+	_, err = c.handleJWTAuth(namespace, ingress)
+	for _, rule := range c.cfg.HTTPRequests["JWT-default-a"] {
+		conds = append(conds, rule.Type+" "+rule.CondTest)
+	}
+	return conds, err
+}
+
+func TestHandleJWTAuth(t *testing.T) {
+	conds, err := testJWTAuth(t, map[string]string{
+		"auth-type":      "jwt",
+		"jwt-key-secret": "issuer-key",
+		"jwt-issuer":     "https://issuer.example.com/",
+		"jwt-audience":   "api",
+	}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enabled := "{ var(txn.jwt_auth_default_a) -m bool }"
+	expected := []string{
+		"set-var { var(txn.ingress) -m str default/a }",
+		"deny " + enabled + " !{ http_auth_bearer,jwt_header_query('$.alg') -m str RS256 }",
+		"deny " + enabled + " !{ http_auth_bearer,jwt_verify(RS256," + HAProxyJWTDir + "/default_a.pem) -m int 1 }",
+		"set-var " + enabled,
+		"deny " + enabled + " !{ http_auth_bearer,jwt_payload_query('$.exp','int') -m found }",
+		"deny " + enabled + " { http_auth_bearer,jwt_payload_query('$.exp','int'),sub(txn.jwt_now) -m int lt 0 }",
+		"deny " + enabled + " !{ http_auth_bearer,jwt_payload_query('$.iss') -m str https://issuer.example.com/ }",
+		"deny " + enabled + " !{ http_auth_bearer,jwt_payload_query('$.aud') -m str api } !{ http_auth_bearer,jwt_payload_query('$.aud') -m sub '\"api\"' }",
+	}
+	if len(conds) != len(expected) {
+		t.Fatalf("expected %d rules, got:\n%v", len(expected), conds)
+	}
+	for i := range expected {
+		// key file is written in the temporary directory
+		if i == 2 {
+			continue
+		}
+		if conds[i] != expected[i] {
+			t.Errorf("rule %d: expected\n%s\ngot\n%s", i, expected[i], conds[i])
+		}
+	}
+}
+
+func TestHandleJWTAuthFailClosed(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		secret      bool
+	}{
+		{"missing secret", map[string]string{"auth-type": "jwt", "jwt-key-secret": "issuer-key"}, false},
+		{"no key secret", map[string]string{"auth-type": "jwt"}, true},
+		{"unsupported algorithm", map[string]string{"auth-type": "jwt", "jwt-key-secret": "issuer-key", "jwt-algorithm": "HS256"}, true},
+		{"invalid audience", map[string]string{"auth-type": "jwt", "jwt-key-secret": "issuer-key", "jwt-audience": "a b"}, true},
+		{"unknown auth type", map[string]string{"auth-type": "basic", "jwt-key-secret": "issuer-key"}, true},
+	}
+	for _, test := range tests {
+		conds, err := testJWTAuth(t, test.annotations, test.secret)
+		if err == nil {
+			t.Errorf("%s: expected error", test.name)
+		}
+		expected := []string{"set-var { var(txn.ingress) -m str default/a }", "deny { var(txn.jwt_auth_default_a) -m bool }"}
+		if !reflect.DeepEqual(conds, expected) {
+			t.Errorf("%s: expected all requests denied, got %v", test.name, conds)
+		}
+	}
+}
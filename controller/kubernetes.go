@@ -38,7 +38,7 @@ var ErrIgnored = errors.New("Ignored resource") //nolint golint
 
 //K8s is structure with all data required to synchronize with k8s
 type K8s struct {
-	API *kubernetes.Clientset
+	API kubernetes.Interface
 }
 
 //GetKubernetesClient returns new client that communicates with k8s
@@ -93,12 +93,13 @@ func (k *K8s) EventsNamespaces(channel chan *Namespace, stop chan struct{}) {
 					status = DELETED
 				}
 				item := &Namespace{
-					Name:      data.GetName(),
-					Endpoints: make(map[string]*Endpoints),
-					Services:  make(map[string]*Service),
-					Ingresses: make(map[string]*Ingress),
-					Secret:    make(map[string]*Secret),
-					Status:    status,
+					Name:       data.GetName(),
+					Endpoints:  make(map[string]*Endpoints),
+					Services:   make(map[string]*Service),
+					Ingresses:  make(map[string]*Ingress),
+					Secret:     make(map[string]*Secret),
+					ConfigMaps: make(map[string]*ConfigMap),
+					Status:     status,
 				}
 				if DEBUG_API {
 					log.Printf("%s %s: %s \n", NAMESPACE, item.Status, item.Name)
@@ -109,12 +110,13 @@ func (k *K8s) EventsNamespaces(channel chan *Namespace, stop chan struct{}) {
 				data := obj.(*corev1.Namespace)
 				var status = DELETED
 				item := &Namespace{
-					Name:      data.GetName(),
-					Endpoints: make(map[string]*Endpoints),
-					Services:  make(map[string]*Service),
-					Ingresses: make(map[string]*Ingress),
-					Secret:    make(map[string]*Secret),
-					Status:    status,
+					Name:       data.GetName(),
+					Endpoints:  make(map[string]*Endpoints),
+					Services:   make(map[string]*Service),
+					Ingresses:  make(map[string]*Ingress),
+					Secret:     make(map[string]*Secret),
+					ConfigMaps: make(map[string]*ConfigMap),
+					Status:     status,
 				}
 				if DEBUG_API {
 					log.Printf("%s %s: %s \n", NAMESPACE, item.Status, item.Name)
@@ -276,6 +278,7 @@ func (k *K8s) EventsIngresses(channel chan *Ingress, stop chan struct{}) {
 					TLS:            ConvertIngressTLS(data.Spec.TLS),
 					Status:         status,
 				}
+				item.CreationTimestamp = data.GetCreationTimestamp().Time
 				if DEBUG_API {
 					log.Printf("%s %s: %s \n", INGRESS, item.Status, item.Name)
 				}
@@ -293,6 +296,7 @@ func (k *K8s) EventsIngresses(channel chan *Ingress, stop chan struct{}) {
 					TLS:            ConvertIngressTLS(data.Spec.TLS),
 					Status:         status,
 				}
+				item.CreationTimestamp = data.GetCreationTimestamp().Time
 				if DEBUG_API {
 					log.Printf("%s %s: %s \n", INGRESS, item.Status, item.Name)
 				}
@@ -311,6 +315,7 @@ func (k *K8s) EventsIngresses(channel chan *Ingress, stop chan struct{}) {
 					TLS:            ConvertIngressTLS(data1.Spec.TLS),
 					Status:         status,
 				}
+				item1.CreationTimestamp = data1.GetCreationTimestamp().Time
 				item2 := &Ingress{
 					Namespace:      data2.GetNamespace(),
 					Name:           data2.GetName(),
@@ -320,6 +325,7 @@ func (k *K8s) EventsIngresses(channel chan *Ingress, stop chan struct{}) {
 					TLS:            ConvertIngressTLS(data2.Spec.TLS),
 					Status:         status,
 				}
+				item2.CreationTimestamp = data2.GetCreationTimestamp().Time
 				if item2.Equal(item1) {
 					return
 				}
@@ -432,14 +438,17 @@ func (k *K8s) EventsServices(channel chan *Service, stop chan struct{}, publishS
 						Port:     int64(sp.Port),
 					})
 				}
-				if item2.Equal(item1) {
-					return
-				}
+				// load-balancer status of publish service is not part of Service equality,
+				// an address change is passed on so ingress statuses are patched
+				publishChanged := false
 				if publishSvc != nil {
 					if publishSvc.Namespace == item2.Namespace && publishSvc.Name == item2.Name {
-						k.GetPublishServiceAddresses(data2, publishSvc)
+						publishChanged = k.GetPublishServiceAddresses(data2, publishSvc)
 					}
 				}
+				if item2.Equal(item1) && !publishChanged {
+					return
+				}
 				if DEBUG_API {
 					log.Printf("%s %s: %s \n", SERVICE, item2.Status, item2.Name)
 				}
@@ -634,7 +643,8 @@ func (k *K8s) UpdateIngressStatus(ingress *Ingress, publishSvc *Service) (err er
 
 }
 
-func (k *K8s) GetPublishServiceAddresses(service *corev1.Service, publishSvc *Service) {
+//GetPublishServiceAddresses updates addresses of publishSvc from service, returns true if they changed
+func (k *K8s) GetPublishServiceAddresses(service *corev1.Service, publishSvc *Service) (changed bool) {
 	addresses := []string{}
 	switch service.Spec.Type {
 	case corev1.ServiceTypeExternalName:
@@ -658,22 +668,66 @@ func (k *K8s) GetPublishServiceAddresses(service *corev1.Service, publishSvc *Se
 		addresses = append(addresses, service.Spec.ExternalIPs...)
 	default:
 		log.Printf("Unable to extract IP address/es from service %v", service)
-		return
+		return false
 	}
 
 	equal := false
 	if len(publishSvc.Addresses) == len(addresses) {
 		equal = true
 		for i, address := range publishSvc.Addresses {
-			if address != publishSvc.Addresses[i] {
+			if address != addresses[i] {
 				equal = false
 				break
 			}
 		}
 	}
 	if equal {
-		return
+		return false
 	}
+	log.Printf("publish service %s/%s addresses changed from %v to %v", publishSvc.Namespace, publishSvc.Name, publishSvc.Addresses, addresses)
 	publishSvc.Addresses = addresses
 	publishSvc.Status = MODIFIED
+	return true
+}
+
+//CreatePodEvent records an event on the given pod
+func (k *K8s) CreatePodEvent(namespace, pod, eventType, reason, message string) (err error) {
+	return k.createEvent(corev1.ObjectReference{
+		Kind:       "Pod",
+		APIVersion: "v1",
+		Namespace:  namespace,
+		Name:       pod,
+	}, eventType, reason, message)
+}
+
+//CreateIngressEvent records an event on the given ingress
+func (k *K8s) CreateIngressEvent(namespace, ingress, eventType, reason, message string) (err error) {
+	return k.createEvent(corev1.ObjectReference{
+		Kind:       "Ingress",
+		APIVersion: "extensions/v1beta1",
+		Namespace:  namespace,
+		Name:       ingress,
+	}, eventType, reason, message)
+}
+
+func (k *K8s) createEvent(object corev1.ObjectReference, eventType, reason, message string) (err error) {
+	now := metav1.Now()
+	event := &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: object.Name + ".",
+			Namespace:    object.Namespace,
+		},
+		InvolvedObject: object,
+		Reason:         reason,
+		Message:        message,
+		Type:           eventType,
+		Source:         corev1.EventSource{Component: "haproxy-ingress-controller"},
+		FirstTimestamp: now,
+		LastTimestamp:  now,
+		Count:          1,
+	}
+	if _, err = k.API.CoreV1().Events(object.Namespace).Create(event); err != nil {
+		return fmt.Errorf("failed to create event %s on %s %s/%s: %v", reason, object.Kind, object.Namespace, object.Name, err)
+	}
+	return nil
 }
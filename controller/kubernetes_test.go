@@ -0,0 +1,78 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	extensions "k8s.io/api/extensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func testLoadBalancerService(addresses ...string) *corev1.Service {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "haproxy-controller", Name: "haproxy-ingress"},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeLoadBalancer},
+	}
+	for _, address := range addresses {
+		service.Status.LoadBalancer.Ingress = append(service.Status.LoadBalancer.Ingress, corev1.LoadBalancerIngress{IP: address})
+	}
+	return service
+}
+
+func TestPublishServiceAddressChange(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	c.cfg.PublishService = &Service{Namespace: "haproxy-controller", Name: "haproxy-ingress", Status: EMPTY}
+	if _, err := client.ExtensionsV1beta1().Ingresses("default").Create(&extensions.Ingress{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "a"}}); err != nil {
+		t.Fatal(err)
+	}
+	ns := c.cfg.GetNamespace("haproxy-controller")
+	c.testService("haproxy-controller", "haproxy-ingress", nil)
+	service := *ns.Services["haproxy-ingress"]
+	service.Status = MODIFIED
+
+	if !c.k8s.GetPublishServiceAddresses(testLoadBalancerService("192.0.2.1"), c.cfg.PublishService) {
+		t.Fatal("expected addresses change")
+	}
+	c.cfg.PublishService.Status = EMPTY
+	if c.k8s.GetPublishServiceAddresses(testLoadBalancerService("192.0.2.1"), c.cfg.PublishService) {
+		t.Error("expected no addresses change")
+	}
+	// an unchanged service does not require an update: no status to patch
+	if c.eventService(ns, &service) {
+		t.Error("expected no update")
+	}
+
+	if !c.k8s.GetPublishServiceAddresses(testLoadBalancerService("192.0.2.2"), c.cfg.PublishService) {
+		t.Fatal("expected addresses change")
+	}
+	if !c.eventService(ns, &service) {
+		t.Fatal("expected update of ingress statuses")
+	}
+	ingress := &Ingress{Namespace: "default", Name: "a", Status: EMPTY}
+	if err := c.k8s.UpdateIngressStatus(ingress, c.cfg.PublishService); err != nil {
+		t.Fatal(err)
+	}
+	patched, err := client.ExtensionsV1beta1().Ingresses("default").Get("a", metav1.GetOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lb := patched.Status.LoadBalancer.Ingress; len(lb) != 1 || lb[0].IP != "192.0.2.2" {
+		t.Errorf("expected status 192.0.2.2, got %v", lb)
+	}
+}
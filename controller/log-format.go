@@ -0,0 +1,95 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+)
+
+// handleLogFormatSD sets "log-format-sd" of the defaults section from --log-format-sd,
+// the structured-data part of RFC5424 syslog messages ("format rfc5424" of syslog-server).
+func (c *HAProxyController) handleLogFormatSD() (needsReload bool, err error) {
+	format := strings.Join(strings.Fields(c.osArgs.LogFormatSD), " ")
+	if format != "" {
+		if err = validateLogFormat(format); err != nil {
+			return false, fmt.Errorf("log-format-sd: %s", err)
+		}
+	}
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	current := ""
+	if data, errGet := config.Get(parser.Defaults, parser.DefaultSectionName, "log-format-sd"); errGet == nil {
+		current = data.(*types.StringC).Value
+	}
+	if current == format {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	if format == "" {
+		return true, config.Set(parser.Defaults, parser.DefaultSectionName, "log-format-sd", nil)
+	}
+	return true, config.Set(parser.Defaults, parser.DefaultSectionName, "log-format-sd", types.StringC{Value: format})
+}
+
+// validateLogFormat checks the syntax of %-variables of a log format:
+// %[<sample expression>], %<var> with optional %{<flags>} prefix, or %% for a literal %
+func validateLogFormat(format string) error {
+	if strings.Contains(format, "#") {
+		return fmt.Errorf("'#' is not allowed in '%s'", format)
+	}
+	if strings.Count(format, `"`)%2 != 0 {
+		return fmt.Errorf("unbalanced quotes in '%s'", format)
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		i++
+		if i < len(format) && format[i] == '%' {
+			continue
+		}
+		if i < len(format) && format[i] == '{' {
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return fmt.Errorf("missing '}' in '%s'", format)
+			}
+			i += end + 1
+		}
+		if i < len(format) && format[i] == '[' {
+			end := strings.IndexByte(format[i:], ']')
+			if end <= 1 {
+				return fmt.Errorf("invalid sample expression in '%s'", format)
+			}
+			i += end
+			continue
+		}
+		start := i
+		for i < len(format) && unicode.IsLetter(rune(format[i])) {
+			i++
+		}
+		if i == start {
+			return fmt.Errorf("missing variable after '%%' at position %d of '%s'", start, format)
+		}
+		i--
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleLogFormatSD(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   string
+		expected string
+		err      bool
+	}{
+		{
+			name:     "structured data",
+			format:   `[exampleSDID@32473 client="%ci" backend="%b"]`,
+			expected: `log-format-sd [exampleSDID@32473 client="%ci" backend="%b"]`,
+		},
+		{
+			name:     "sample expression and flags",
+			format:   `[meta@1 host="%[req.hdr(host)]" time="%{+Q}Tt" pct="100%%"]`,
+			expected: `log-format-sd [meta@1 host="%[req.hdr(host)]" time="%{+Q}Tt" pct="100%%"]`,
+		},
+		{
+			name:   "unbalanced quotes",
+			format: `[meta@1 client="%ci]`,
+			err:    true,
+		},
+		{
+			name:   "missing variable",
+			format: `[meta@1 client="% "]`,
+			err:    true,
+		},
+		{
+			name:   "empty sample expression",
+			format: `[meta@1 host="%[]"]`,
+			err:    true,
+		},
+		{
+			name:   "comment",
+			format: `[meta@1] # comment`,
+			err:    true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.osArgs.LogFormatSD = test.format
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleLogFormatSD()
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := ""
+			for _, line := range testSection(config, "defaults") {
+				if strings.HasPrefix(line, "log-format-sd") {
+					got = line
+				}
+			}
+			if got != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleLogFormatSDRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.LogFormatSD = `[meta@1 client="%ci"]`
+	c.testSync(t, func() {
+		if reload, err := c.handleLogFormatSD(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.osArgs.LogFormatSD = ""
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleLogFormatSD(); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "log-format-sd") {
+		t.Errorf("expected no log-format-sd:\n%s", config)
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// handleLogHealthChecks sets "option log-health-checks" in defaults section with
+// --log-health-checks flag, so that health check state transitions of servers of all
+// backends are logged. The annotation of the same name enables it for some backends only.
+func (c *HAProxyController) handleLogHealthChecks() (needsReload bool, err error) {
+	lines := []string{}
+	if c.osArgs.LogHealthChecks {
+		lines = append(lines, "option log-health-checks")
+	}
+	return c.sectionDirectivesSet(parser.Defaults, parser.DefaultSectionName, "option log-health-checks", lines)
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleLogHealthChecks(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.LogHealthChecks = true
+	config := c.testSync(t, func() {
+		reload, err := c.handleLogHealthChecks()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reload {
+			t.Error("expected reload")
+		}
+	})
+	if !testSectionHas(config, "defaults", "option log-health-checks") {
+		t.Errorf("expected option log-health-checks in defaults:\n%s", config)
+	}
+	// unchanged once the configuration is read again
+	c.testSync(t, func() {
+		if reload, _ := c.handleLogHealthChecks(); reload {
+			t.Error("expected no reload")
+		}
+	})
+	c.osArgs.LogHealthChecks = false
+	config = c.testSync(t, func() {
+		if _, err := c.handleLogHealthChecks(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "log-health-checks") {
+		t.Errorf("expected no option log-health-checks:\n%s", config)
+	}
+}
+
+func TestHandleBackendOptionLogHealthChecks(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected bool
+		err      bool
+	}{
+		{"true", true, false},
+		{"false", false, false},
+		{"yes please", false, true},
+	}
+	for _, test := range tests {
+		c, cleanup := newTestController(t)
+		service := &Service{Annotations: testAnnotations(map[string]string{"log-health-checks": test.value})}
+		var err error
+		config := c.testSync(t, func() {
+			_, err = c.handleBackendOption("log-health-checks", &Ingress{Annotations: MapStringW{}}, service, "default-app-80", true)
+		})
+		cleanup()
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t, got %v", test.value, test.err, err)
+		}
+		if testSectionHas(config, "backend default-app-80", "option log-health-checks") != test.expected {
+			t.Errorf("%s: expected option %t:\n%s", test.value, test.expected, config)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"github.com/haproxytech/kubernetes-ingress/controller/metrics"
+)
+
+var (
+	metricFrontendRules = metrics.NewGaugeVec("haproxy_ingress_frontend_rules",
+		"Number of use_backend rules per frontend.", "frontend")
+	metricNamespaceBackends = metrics.NewGaugeVec("haproxy_ingress_namespace_backends",
+		"Number of backends used by ingress rules per namespace.", "namespace")
+)
+
+// updateNamespaceBackendsMetric counts backends referenced by use_backend rules of each namespace
+func (c *HAProxyController) updateNamespaceBackendsMetric() {
+	backends := map[string]map[string]struct{}{}
+	for _, rules := range c.cfg.BackendSwitchingRules {
+		for _, rule := range rules {
+			if _, ok := backends[rule.Namespace]; !ok {
+				backends[rule.Namespace] = map[string]struct{}{}
+			}
+			backends[rule.Namespace][rule.Backend] = struct{}{}
+		}
+	}
+	metricNamespaceBackends.Reset()
+	for namespace, set := range backends {
+		metricNamespaceBackends.Set(float64(len(set)), namespace)
+	}
+}
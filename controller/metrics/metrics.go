@@ -0,0 +1,154 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics holds internal state metrics of the controller
+// exposed in Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	registryMutex sync.Mutex
+	registry      []*Vec
+)
+
+//Vec is a set of gauges or counters sharing the same name, partitioned by labels
+type Vec struct {
+	name       string
+	help       string
+	metricType string
+	labels     []string
+	mutex      sync.Mutex
+	values     map[string]metricValue
+}
+
+type metricValue struct {
+	labelValues []string
+	value       float64
+}
+
+//NewGaugeVec creates a gauge and registers it for export
+func NewGaugeVec(name, help string, labels ...string) *Vec {
+	return newVec(name, help, "gauge", labels)
+}
+
+//NewCounterVec creates a counter and registers it for export
+func NewCounterVec(name, help string, labels ...string) *Vec {
+	return newVec(name, help, "counter", labels)
+}
+
+func newVec(name, help, metricType string, labels []string) *Vec {
+	g := &Vec{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labels:     labels,
+		values:     map[string]metricValue{},
+	}
+	registryMutex.Lock()
+	registry = append(registry, g)
+	registryMutex.Unlock()
+	return g
+}
+
+//Set sets the value of the metric with given label values
+func (g *Vec) Set(value float64, labelValues ...string) {
+	if len(labelValues) != len(g.labels) {
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.values[strings.Join(labelValues, "\x00")] = metricValue{
+		labelValues: labelValues,
+		value:       value,
+	}
+}
+
+//Add adds delta to the value of the metric with given label values
+func (g *Vec) Add(delta float64, labelValues ...string) {
+	if len(labelValues) != len(g.labels) {
+		return
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	key := strings.Join(labelValues, "\x00")
+	g.values[key] = metricValue{
+		labelValues: labelValues,
+		value:       g.values[key].value + delta,
+	}
+}
+
+//Get returns the value of the metric with given label values
+func (g *Vec) Get(labelValues ...string) (value float64, ok bool) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	v, ok := g.values[strings.Join(labelValues, "\x00")]
+	return v.value, ok
+}
+
+//Delete removes the metric with given label values
+func (g *Vec) Delete(labelValues ...string) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	delete(g.values, strings.Join(labelValues, "\x00"))
+}
+
+//Reset removes all values
+func (g *Vec) Reset() {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	g.values = map[string]metricValue{}
+}
+
+func (g *Vec) write(w io.Writer) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", g.name, g.help, g.name, g.metricType)
+	keys := make([]string, 0, len(g.values))
+	for key := range g.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		v := g.values[key]
+		labels := make([]string, len(g.labels))
+		for i, label := range g.labels {
+			labels[i] = fmt.Sprintf("%s=%q", label, v.labelValues[i])
+		}
+		if len(labels) > 0 {
+			fmt.Fprintf(w, "%s{%s} %g\n", g.name, strings.Join(labels, ","), v.value)
+		} else {
+			fmt.Fprintf(w, "%s %g\n", g.name, v.value)
+		}
+	}
+}
+
+//Handler returns an http handler exporting all registered metrics
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		registryMutex.Lock()
+		defer registryMutex.Unlock()
+		for _, g := range registry {
+			g.write(w)
+		}
+	})
+}
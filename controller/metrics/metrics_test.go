@@ -0,0 +1,55 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	gauge := NewGaugeVec("test_gauge", "Test gauge.", "frontend")
+	counter := NewCounterVec("test_counter", "Test counter.")
+	gauge.Set(3, "https")
+	gauge.Set(2, "http")
+	gauge.Set(1, "http", "extra")
+	counter.Add(1)
+	counter.Add(2)
+
+	recorder := httptest.NewRecorder()
+	Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	expected := []string{
+		"# HELP test_gauge Test gauge.",
+		"# TYPE test_gauge gauge",
+		`test_gauge{frontend="http"} 2`,
+		`test_gauge{frontend="https"} 3`,
+		"# HELP test_counter Test counter.",
+		"# TYPE test_counter counter",
+		"test_counter 3",
+	}
+	if got := strings.TrimSpace(recorder.Body.String()); got != strings.Join(expected, "\n") {
+		t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(expected, "\n"), got)
+	}
+
+	gauge.Delete("http")
+	if _, ok := gauge.Get("http"); ok {
+		t.Error("expected deleted gauge")
+	}
+	gauge.Reset()
+	if _, ok := gauge.Get("https"); ok {
+		t.Error("expected reset gauge")
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestStateMetrics(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.addUseBackendRule("Rdefaultaexample.com/a", UseBackendRule{Host: "example.com", Path: "/a", Backend: "default-a-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaultaexample.com/b", UseBackendRule{Host: "example.com", Path: "/b", Backend: "default-b-80", Namespace: "default", Ingress: "a"}, FrontendHTTP, FrontendHTTPS)
+	c.addUseBackendRule("Rdefaultaexample.com/c", UseBackendRule{Host: "example.com", Path: "/c", Backend: "default-b-80", Namespace: "default", Ingress: "a"}, FrontendHTTP)
+	c.addUseBackendRule("Rotherbexample.org/", UseBackendRule{Host: "example.org", Path: "/", Backend: "other-app-80", Namespace: "other", Ingress: "b"}, FrontendHTTPS)
+	c.testSync(t, func() {
+		c.refreshBackendSwitching()
+	})
+	for frontend, expected := range map[string]float64{FrontendHTTP: 3, FrontendHTTPS: 2} {
+		if value, _ := metricFrontendRules.Get(frontend); value != expected {
+			t.Errorf("frontend %s: expected %v rules, got %v", frontend, expected, value)
+		}
+	}
+	for namespace, expected := range map[string]float64{"default": 2, "other": 1} {
+		if value, _ := metricNamespaceBackends.Get(namespace); value != expected {
+			t.Errorf("namespace %s: expected %v backends, got %v", namespace, expected, value)
+		}
+	}
+
+	// rules of a namespace are gone
+	c.deleteUseBackendRule("Rotherbexample.org/", FrontendHTTPS)
+	c.testSync(t, func() {
+		c.refreshBackendSwitching()
+	})
+	if value, _ := metricFrontendRules.Get(FrontendHTTPS); value != 1 {
+		t.Errorf("frontend https: expected 1 rule, got %v", value)
+	}
+	if _, ok := metricNamespaceBackends.Get("other"); ok {
+		t.Error("namespace other: expected no backends metric")
+	}
+}
@@ -16,6 +16,7 @@ package controller
 
 import (
 	"log"
+	"strings"
 	"time"
 )
 
@@ -110,30 +111,61 @@ func (c *HAProxyController) monitorChanges() {
 //All the changes must come through this function
 func (c *HAProxyController) SyncData(jobChan <-chan SyncDataEvent, chConfigMapReceivedAndProcessed chan bool) {
 	hadChanges := false
+	var workers *eventWorkers
+	if c.osArgs.EventWorkers > 1 {
+		workers = newEventWorkers(c.osArgs.EventWorkers, c.eventNamespaced)
+	}
 	for job := range jobChan {
 		ns := c.cfg.GetNamespace(job.Namespace)
+		if workers != nil {
+			switch job.SyncType {
+			case INGRESS, ENDPOINTS, SERVICE:
+				workers.dispatch(ns, job)
+				continue
+			}
+			for _, reason := range workers.wait() {
+				c.reloadEvents.addReason(reason)
+				hadChanges = true
+			}
+		}
 		change := false
 		switch job.SyncType {
 		case COMMAND:
-			if hadChanges {
+			c.updateQueueMetrics()
+			c.handleRebalance()
+			// forceReload is also set by drift checks, see checkDrift
+			if hadChanges || c.forceReload {
 				if err := c.updateHAProxy(); err != nil {
 					log.Println(err)
 				}
+				c.handleDrift()
 				continue
 			}
+		case FORCE_RELOAD:
+			log.Println("Forced resync and reload requested")
+			c.forceResync()
+			if err := c.updateHAProxy(); err != nil {
+				log.Println(err)
+			}
+			continue
+		case HAPROXY_RESTART:
+			c.restartHAProxy()
+			continue
+		case ROUTE_QUERY:
+			c.routeQueryAnswer(job.Data.(*routeQuery))
+			continue
 		case NAMESPACE:
 			change = c.eventNamespace(ns, job.Data.(*Namespace))
-		case INGRESS:
-			change = c.eventIngress(ns, job.Data.(*Ingress))
-		case ENDPOINTS:
-			change = c.eventEndpoints(ns, job.Data.(*Endpoints))
-		case SERVICE:
-			change = c.eventService(ns, job.Data.(*Service))
+		case INGRESS, ENDPOINTS, SERVICE:
+			change = c.eventNamespaced(ns, job)
 		case CONFIGMAP:
 			change = c.eventConfigMap(ns, job.Data.(*ConfigMap), chConfigMapReceivedAndProcessed)
 		case SECRET:
 			change = c.eventSecret(ns, job.Data.(*Secret))
 		}
+		if change {
+			c.reloadEvents.addReason(strings.ToLower(string(job.SyncType)))
+		}
 		hadChanges = hadChanges || change
 	}
 }
@@ -0,0 +1,65 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// fallbackBackend is the backend without servers of haproxy.cfg used
+// by HTTP frontends when there is no default backend service
+const fallbackBackend = "default_backend"
+
+// noHostMatchResponses are the responses of --no-host-match-action status codes
+var noHostMatchResponses = map[string]string{
+	"404": "Not Found",
+	"421": "Misdirected Request",
+}
+
+// handleNoHostMatch configures the response of fallbackBackend according to --no-host-match-action.
+// The backend has no servers so HAProxy answers with a 503 which is replaced
+// by a 404/421 or a custom raw HTTP response file via "errorfile 503".
+func (c *HAProxyController) handleNoHostMatch() (needsReload bool, err error) {
+	lines := []string{}
+	action := c.osArgs.NoHostMatchAction
+	switch action {
+	case "", "default-backend":
+	case "404", "421":
+		file := path.Join(HAProxyErrorsDir, action+".http")
+		content := []byte(fmt.Sprintf("HTTP/1.0 %[1]s %[2]s\r\nCache-Control: no-cache\r\nConnection: close\r\nContent-Type: text/html\r\n\r\n<html><body><h1>%[1]s %[2]s</h1>\nNo ingress rule matches the request.\n</body></html>\n", action, noHostMatchResponses[action]))
+		if current, errRead := ioutil.ReadFile(file); errRead != nil || !bytes.Equal(current, content) {
+			if err = ioutil.WriteFile(file, content, 0644); err != nil {
+				return false, fmt.Errorf("no-host-match-action: %s", err)
+			}
+		}
+		lines = append(lines, "errorfile 503 "+file)
+	default:
+		if !filepath.IsAbs(action) {
+			return false, fmt.Errorf("no-host-match-action: expected default-backend, 404, 421 or absolute path of a file, got '%s'", action)
+		}
+		if _, err = os.Stat(action); err != nil {
+			return false, fmt.Errorf("no-host-match-action: %s", err)
+		}
+		lines = append(lines, "errorfile 503 "+action)
+	}
+	return c.sectionDirectivesSet(parser.Backends, fallbackBackend, "errorfile 503", lines)
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandleNoHostMatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	errorsDir := HAProxyErrorsDir
+	HAProxyErrorsDir = dir
+	defer func() { HAProxyErrorsDir = errorsDir }()
+	custom := filepath.Join(dir, "custom.http")
+	if err = ioutil.WriteFile(custom, []byte("HTTP/1.0 410 Gone\r\n\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		action    string
+		errorfile string
+		status    string
+		err       bool
+	}{
+		{action: "default-backend"},
+		{action: "404", errorfile: filepath.Join(dir, "404.http"), status: "HTTP/1.0 404 Not Found\r\n"},
+		{action: "421", errorfile: filepath.Join(dir, "421.http"), status: "HTTP/1.0 421 Misdirected Request\r\n"},
+		{action: custom, errorfile: custom, status: "HTTP/1.0 410 Gone\r\n"},
+		{action: filepath.Join(dir, "missing.http"), err: true},
+		{action: "503", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.action, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.osArgs.NoHostMatchAction = test.action
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleNoHostMatch()
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.errorfile == "" {
+				if strings.Contains(config, "errorfile") {
+					t.Errorf("expected no errorfile:\n%s", config)
+				}
+				return
+			}
+			// requests matching no rule are answered by the backend without servers
+			if !testSectionHas(config, "backend default_backend", "errorfile 503 "+test.errorfile) {
+				t.Errorf("expected errorfile 503 %s:\n%s", test.errorfile, config)
+			}
+			content, errRead := ioutil.ReadFile(test.errorfile)
+			if errRead != nil {
+				t.Fatal(errRead)
+			}
+			if !strings.HasPrefix(string(content), test.status) {
+				t.Errorf("expected response '%s', got:\n%s", test.status, content)
+			}
+		})
+	}
+}
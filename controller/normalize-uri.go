@@ -0,0 +1,89 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// uriNormalizers are the normalizers accepted by "http-request normalize-uri"
+// with their optional argument
+var uriNormalizers = map[string]string{
+	"fragment-encode":           "",
+	"fragment-strip":            "",
+	"path-merge-slashes":        "",
+	"path-strip-dot":            "",
+	"path-strip-dotdot":         "full",
+	"percent-decode-unreserved": "strict",
+	"percent-to-uppercase":      "strict",
+	"query-sort-by-name":        "",
+}
+
+// handleNormalizeURI canonicalizes request URI in HTTP frontends with the
+// normalizers of "normalize-uri" annotation, so that "/a/../b" is routed as "/b".
+// http-request rules are evaluated before use_backend ones.
+func (c *HAProxyController) handleNormalizeURI() (needsReload bool, err error) {
+	annNormalize, errAnn := GetValueFromAnnotations("normalize-uri", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annNormalize.Status == EMPTY {
+		return false, nil
+	}
+	lines := []string{}
+	if annNormalize.Status != DELETED && c.featureSupported("normalize-uri") {
+		normalizers, errParse := parseNormalizeURI(annNormalize.Value)
+		if errParse != nil {
+			return false, fmt.Errorf("normalize-uri annotation: %s", errParse)
+		}
+		for _, normalizer := range normalizers {
+			lines = append(lines, "http-request normalize-uri "+normalizer)
+		}
+	}
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		reload, errSet := c.sectionDirectivesSet(parser.Frontends, frontend, "http-request normalize-uri", lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
+// parseNormalizeURI validates a comma or new line separated list of
+// "<normalizer> [<option>]" items
+func parseNormalizeURI(value string) (normalizers []string, err error) {
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		fields := strings.Fields(item)
+		if len(fields) == 0 {
+			continue
+		}
+		option, ok := uriNormalizers[fields[0]]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalizer '%s'", fields[0])
+		}
+		if len(fields) > 2 || (len(fields) == 2 && fields[1] != option) {
+			return nil, fmt.Errorf("invalid option '%s' for normalizer '%s'", strings.Join(fields[1:], " "), fields[0])
+		}
+		normalizers = append(normalizers, strings.Join(fields, " "))
+	}
+	if len(normalizers) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return normalizers, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleNormalizeURI(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		version  HAProxyVersion
+		expected []string
+		err      bool
+	}{
+		{
+			name:     "dotdot is removed before routing",
+			value:    "path-strip-dotdot",
+			expected: []string{"http-request normalize-uri path-strip-dotdot"},
+		},
+		{
+			name:  "several normalizers with options",
+			value: "path-strip-dotdot full, percent-decode-unreserved strict\nquery-sort-by-name",
+			expected: []string{
+				"http-request normalize-uri path-strip-dotdot full",
+				"http-request normalize-uri percent-decode-unreserved strict",
+				"http-request normalize-uri query-sort-by-name",
+			},
+		},
+		{
+			name:    "unsupported version",
+			value:   "path-strip-dotdot",
+			version: HAProxyVersion{2, 2, 0},
+		},
+		{
+			name:  "unknown normalizer",
+			value: "path-strip-dotdotdot",
+			err:   true,
+		},
+		{
+			name:  "invalid option",
+			value: "path-merge-slashes full",
+			err:   true,
+		},
+		{
+			name:  "empty",
+			value: " , ",
+			err:   true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.haproxyVersion = test.version
+			c.cfg.ConfigMap.Annotations["normalize-uri"] = &StringW{Value: test.value, Status: ADDED}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleNormalizeURI()
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			for _, frontend := range []string{"frontend http", "frontend https"} {
+				// HAProxy evaluates http-request rules before use_backend ones whatever their position
+				got := []string{}
+				for _, line := range testSection(config, frontend) {
+					if strings.HasPrefix(line, "http-request normalize-uri") {
+						got = append(got, line)
+					}
+				}
+				if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+					t.Errorf("%s: expected:\n%s\ngot:\n%s", frontend, strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+				}
+			}
+		})
+	}
+}
+
+func TestHandleNormalizeURIRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations["normalize-uri"] = &StringW{Value: "path-strip-dotdot", Status: ADDED}
+	c.testSync(t, func() {
+		if reload, err := c.handleNormalizeURI(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.cfg.ConfigMap.Annotations["normalize-uri"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleNormalizeURI(); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "normalize-uri") {
+		t.Errorf("expected no normalize-uri rule:\n%s", config)
+	}
+}
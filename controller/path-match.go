@@ -0,0 +1,52 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// pathMatchDecoded tells if ingress paths are compared to the percent-decoded request
+// path, with "path-match: decoded", instead of the raw path sent to backends
+func (c *HAProxyController) pathMatchDecoded() (decoded bool, status Status) {
+	annPathMatch, err := GetValueFromAnnotations("path-match", c.cfg.ConfigMap.Annotations)
+	if err != nil {
+		return false, EMPTY
+	}
+	if annPathMatch.Status == DELETED {
+		return false, DELETED
+	}
+	switch annPathMatch.Value {
+	case "raw":
+	case "decoded":
+		decoded = true
+	default:
+		if annPathMatch.Status == EMPTY {
+			break
+		}
+		utils.LogErr(fmt.Errorf("path-match annotation: incorrect value '%s', expected 'raw' or 'decoded'", annPathMatch.Value))
+	}
+	return decoded, annPathMatch.Status
+}
+
+// pathCond is the condition matching requests for path and its sub paths
+func pathCond(path string, decoded bool) string {
+	if decoded {
+		return fmt.Sprintf("{ path,url_dec -m beg %s }", path)
+	}
+	return fmt.Sprintf("{ path_beg %s }", path)
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{name: "default", expected: "use_backend default-api-80 if { req.hdr(host) -i example.com } { path_beg /api }"},
+		{name: "raw", value: "raw", expected: "use_backend default-api-80 if { req.hdr(host) -i example.com } { path_beg /api }"},
+		{name: "decoded", value: "decoded", expected: "use_backend default-api-80 if { req.hdr(host) -i example.com } { path,url_dec -m beg /api }"},
+		{name: "invalid", value: "both", expected: "use_backend default-api-80 if { req.hdr(host) -i example.com } { path_beg /api }"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			if test.value != "" {
+				c.cfg.ConfigMap.Annotations["path-match"] = &StringW{Value: test.value, Status: ADDED}
+			}
+			c.addUseBackendRule("Rdefaultwebexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-api-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+			config := c.testSync(t, func() { c.refreshBackendSwitching() })
+			if !testSectionHas(config, "frontend http", test.expected) {
+				t.Errorf("expected '%s':\n%s", test.expected, config)
+			}
+		})
+	}
+}
+
+func TestPathMatchUpdate(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.addUseBackendRule("Rdefaultwebexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-api-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+	c.testSync(t, func() { c.refreshBackendSwitching() })
+	c.cfg.Clean()
+
+	// existing rules are rewritten when the annotation changes
+	c.cfg.ConfigMap.Annotations["path-match"] = &StringW{Value: "decoded", Status: ADDED}
+	config := c.testSync(t, func() {
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if !strings.Contains(config, "{ path,url_dec -m beg /api }") {
+		t.Errorf("expected decoded path match:\n%s", config)
+	}
+	c.cfg.ConfigMap.Annotations["path-match"].Status = DELETED
+	config = c.testSync(t, func() {
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "url_dec") || !strings.Contains(config, "{ path_beg /api }") {
+		t.Errorf("expected raw path match:\n%s", config)
+	}
+}
+
+func TestPathMatchRouteQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		path    string
+		backend string
+	}{
+		{name: "raw plain", path: "/api/v1", backend: "default-api-80"},
+		{name: "raw encoded", path: "/%2561pi/v1", backend: "default-root-80"},
+		{name: "decoded plain", value: "decoded", path: "/api/v1", backend: "default-api-80"},
+		{name: "decoded encoded", value: "decoded", path: "/%2561pi/v1", backend: "default-api-80"},
+		{name: "decoded invalid encoding", value: "decoded", path: "/api%25zz", backend: "default_backend"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.osArgs.RouteQueryToken = "secret"
+			if test.value != "" {
+				c.cfg.ConfigMap.Annotations["path-match"] = &StringW{Value: test.value, Status: ADDED}
+			}
+			c.addUseBackendRule("Rdefaultwebexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-root-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+			c.addUseBackendRule("Rdefaultwebexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-api-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+			status, result := c.testRouteQuery(t, "/route?frontend=http&host=example.com&path="+test.path, "secret")
+			if status != http.StatusOK || result.Backend != test.backend {
+				t.Errorf("expected backend %s, got %d %s", test.backend, status, result.Backend)
+			}
+		})
+	}
+}
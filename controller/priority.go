@@ -0,0 +1,81 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// handleBackendPriority sets the priority of requests waiting in the queue of a backend
+// with "priority-class" (lower is dequeued first) and "priority-offset" (milliseconds
+// added to the queue time) annotations. Both apply to requests matching the optional
+// "priority-condition" ACL condition, e.g. "{ hdr(X-Tier) -m str gold }".
+func (c *HAProxyController) handleBackendPriority(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	condition := ""
+	annCondition, _ := GetValueFromAnnotations("priority-condition", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annCondition != nil && annCondition.Status != DELETED {
+		if condition, err = parsePriorityCondition(annCondition.Value); err != nil {
+			return false, fmt.Errorf("priority-condition annotation: %s", err)
+		}
+	}
+	priorities := []struct {
+		annotation string
+		action     string
+		min        int64
+		max        int64
+	}{
+		{"priority-class", "set-priority-class", -2047, 2047},
+		{"priority-offset", "set-priority-offset", -524287, 524287},
+	}
+	for _, priority := range priorities {
+		lines := []string{}
+		ann, _ := GetValueFromAnnotations(priority.annotation, service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		if ann != nil && ann.Status != DELETED {
+			value, errParse := strconv.ParseInt(strings.TrimSpace(ann.Value), 10, 64)
+			if errParse != nil || value < priority.min || value > priority.max {
+				err = fmt.Errorf("%s annotation: value should be an integer between %d and %d, got '%s'", priority.annotation, priority.min, priority.max, ann.Value)
+			} else {
+				lines = append(lines, strings.TrimSpace(fmt.Sprintf("http-request %s int(%d) %s", priority.action, value, condition)))
+			}
+		}
+		reload, errSet := c.sectionDirectivesSet(parser.Backends, backendName, "http-request "+priority.action, lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
+
+// parsePriorityCondition returns "if <condition>" from a condition
+// optionally starting with "if" or "unless"
+func parsePriorityCondition(value string) (string, error) {
+	condition := strings.Join(strings.Fields(value), " ")
+	if condition == "" {
+		return "", nil
+	}
+	if strings.Count(condition, "{") != strings.Count(condition, "}") {
+		return "", fmt.Errorf("unbalanced braces in '%s'", value)
+	}
+	if strings.HasPrefix(condition, "if ") || strings.HasPrefix(condition, "unless ") {
+		return condition, nil
+	}
+	return "if " + condition, nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendPriority(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{
+			name:        "class and offset",
+			annotations: map[string]string{"priority-class": "-10", "priority-offset": "500"},
+			expected: []string{
+				"http-request set-priority-class int(-10)",
+				"http-request set-priority-offset int(500)",
+			},
+		},
+		{
+			name:        "header condition",
+			annotations: map[string]string{"priority-class": "1", "priority-condition": "{ hdr(X-Tier) -m str gold }"},
+			expected:    []string{"http-request set-priority-class int(1) if { hdr(X-Tier) -m str gold }"},
+		},
+		{
+			name:        "path unless condition",
+			annotations: map[string]string{"priority-offset": "-1000", "priority-condition": "unless  { path_beg /batch }"},
+			expected:    []string{"http-request set-priority-offset int(-1000) unless { path_beg /batch }"},
+		},
+		{
+			name:        "class out of range",
+			annotations: map[string]string{"priority-class": "4096", "priority-offset": "10"},
+			expected:    []string{"http-request set-priority-offset int(10)"},
+			err:         true,
+		},
+		{
+			name:        "unbalanced condition",
+			annotations: map[string]string{"priority-class": "1", "priority-condition": "{ hdr(X-Tier) -m str gold"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendPriority(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "http-request set-priority") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// handlePrometheusExporter serves Prometheus metrics on /metrics of the stats frontend with
+// the exporter built into HAProxy 2.0 and newer, older versions only serve the stats page.
+func (c *HAProxyController) handlePrometheusExporter() (needsReload bool, err error) {
+	lines := []string{}
+	if c.featureSupported("prometheus-exporter") {
+		lines = append(lines, "http-request use-service prometheus-exporter if { path /metrics }")
+	}
+	return c.sectionDirectivesSet(parser.Frontends, "stats", "http-request use-service prometheus-exporter", lines)
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// handleProxyProtocol expects a PROXY protocol header on connections of HTTP and SSL frontends
+// coming from the IPs or CIDRs of "proxy-protocol" annotation, e.g. the addresses of L4 load balancers.
+// Connections from other sources are handled as plain connections.
+func (c *HAProxyController) handleProxyProtocol() (needsReload bool, err error) {
+	annProxyProtocol, errAnn := GetValueFromAnnotations("proxy-protocol", c.cfg.ConfigMap.Annotations)
+	if errAnn != nil || annProxyProtocol.Status == EMPTY {
+		return false, nil
+	}
+	rules := []models.TCPRequestRule{}
+	if annProxyProtocol.Status != DELETED {
+		sources, errParse := parseSources(annProxyProtocol.Value)
+		if errParse != nil {
+			return false, fmt.Errorf("proxy-protocol annotation: %s", errParse)
+		}
+		rules = append(rules, models.TCPRequestRule{
+			ID:       utils.PtrInt64(0),
+			Type:     "connection",
+			Action:   "expect-proxy layer4",
+			Cond:     "if",
+			CondTest: fmt.Sprintf("{ src %s }", strings.Join(sources, " ")),
+		})
+	}
+	c.cfg.TCPRequests[EXPECT_PROXY] = rules
+	c.cfg.TCPRequestsStatus = MODIFIED
+	return true, nil
+}
+
+// parseSources validates a comma or space separated list of IPs and CIDRs
+func parseSources(value string) (sources []string, err error) {
+	for _, source := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\n'
+	}) {
+		if net.ParseIP(source) == nil {
+			if _, _, errCIDR := net.ParseCIDR(source); errCIDR != nil {
+				return nil, fmt.Errorf("incorrect IP or CIDR '%s'", source)
+			}
+		}
+		sources = append(sources, source)
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return sources, nil
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleProxyProtocol(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{"proxy-protocol": &StringW{Value: "10.0.0.0/8, 192.168.1.1", Status: ADDED}}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleProxyProtocol(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.requestsTCPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	expected := "tcp-request connection expect-proxy layer4 if { src 10.0.0.0/8 192.168.1.1 }"
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		if !testSectionHas(config, frontend, expected) {
+			t.Errorf("%s: expected '%s':\n%s", frontend, expected, config)
+		}
+	}
+
+	c.cfg.ConfigMap.Annotations["proxy-protocol"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleProxyProtocol(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.requestsTCPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "expect-proxy") {
+		t.Errorf("expected no expect-proxy rule:\n%s", config)
+	}
+}
+
+func TestHandleProxyProtocolInvalid(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	for _, value := range []string{"10.0.0.300", "", "10.0.0.0/33"} {
+		c.cfg.ConfigMap.Annotations = MapStringW{"proxy-protocol": &StringW{Value: value, Status: ADDED}}
+		c.testSync(t, func() {
+			if reload, err := c.handleProxyProtocol(); err == nil || reload {
+				t.Errorf("'%s': expected error without reload, got %t %v", value, reload, err)
+			}
+		})
+	}
+}
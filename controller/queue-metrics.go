@@ -0,0 +1,134 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/metrics"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+var metricBackendQueue = metrics.NewGaugeVec("haproxy_ingress_backend_queue",
+	"Number of requests waiting in backend queue for a server.", "backend")
+
+var metricServerQueue = metrics.NewGaugeVec("haproxy_ingress_server_queue",
+	"Number of requests waiting in the queue of a server.", "backend", "server")
+
+var metricServerSessions = metrics.NewGaugeVec("haproxy_ingress_server_sessions",
+	"Number of current sessions of a server.", "backend", "server")
+
+var metricServerMaxconn = metrics.NewGaugeVec("haproxy_ingress_server_maxconn",
+	"Maximum number of sessions of a server (pod-maxconn), 0 if unlimited.", "backend", "server")
+
+// serverStat is the queue and connections usage of a server
+type serverStat struct {
+	Queued   int64
+	Sessions int64
+	Maxconn  int64
+}
+
+// updateQueueMetrics refreshes queued requests per backend from HAProxy runtime stats,
+// it is only done when metrics are exposed by the controller server.
+// Backpressure shows up here before requests fail with "timeout queue".
+func (c *HAProxyController) updateQueueMetrics() {
+	if c.osArgs.ControllerPort == 0 || c.osArgs.Test || c.NativeAPI == nil || c.NativeAPI.Runtime == nil {
+		return
+	}
+	queues, err := backendQueues(c.NativeAPI.Runtime.GetStats())
+	if err != nil {
+		utils.LogErr(err)
+		return
+	}
+	metricBackendQueue.Reset()
+	for backend, queued := range queues {
+		metricBackendQueue.Set(float64(queued), backend)
+	}
+	servers, err := serverStats(c.NativeAPI.Runtime.GetStats())
+	if err != nil {
+		utils.LogErr(err)
+		return
+	}
+	metricServerQueue.Reset()
+	metricServerSessions.Reset()
+	metricServerMaxconn.Reset()
+	for backend, backendServers := range servers {
+		for server, stat := range backendServers {
+			metricServerQueue.Set(float64(stat.Queued), backend, server)
+			metricServerSessions.Set(float64(stat.Sessions), backend, server)
+			metricServerMaxconn.Set(float64(stat.Maxconn), backend, server)
+		}
+	}
+}
+
+// backendQueues sums current queue (qcur) of backends over all HAProxy processes
+func backendQueues(stats models.NativeStats) (map[string]int64, error) {
+	queues := map[string]int64{}
+	for _, collection := range stats {
+		if collection == nil {
+			continue
+		}
+		if collection.Error != "" {
+			return nil, fmt.Errorf("runtime stats %s: %s", collection.RuntimeAPI, collection.Error)
+		}
+		for _, stat := range collection.Stats {
+			if stat == nil || stat.Type != models.NativeStatTypeBackend || stat.Stats == nil {
+				continue
+			}
+			queued := int64(0)
+			if stat.Stats.Qcur != nil {
+				queued = *stat.Stats.Qcur
+			}
+			queues[stat.Name] += queued
+		}
+	}
+	return queues, nil
+}
+
+// serverStats sums queue and sessions of servers per backend over all HAProxy processes,
+// servers in maintenance (empty slots of servers-increment) are skipped.
+// A server queue grows once its sessions reach pod-maxconn, showing a saturated pod.
+func serverStats(stats models.NativeStats) (map[string]map[string]serverStat, error) {
+	servers := map[string]map[string]serverStat{}
+	for _, collection := range stats {
+		if collection == nil {
+			continue
+		}
+		if collection.Error != "" {
+			return nil, fmt.Errorf("runtime stats %s: %s", collection.RuntimeAPI, collection.Error)
+		}
+		for _, stat := range collection.Stats {
+			if stat == nil || stat.Type != models.NativeStatTypeServer || stat.Stats == nil || stat.Stats.Status == "MAINT" {
+				continue
+			}
+			if _, ok := servers[stat.BackendName]; !ok {
+				servers[stat.BackendName] = map[string]serverStat{}
+			}
+			current := servers[stat.BackendName][stat.Name]
+			if stat.Stats.Qcur != nil {
+				current.Queued += *stat.Stats.Qcur
+			}
+			if stat.Stats.Scur != nil {
+				current.Sessions += *stat.Stats.Scur
+			}
+			if stat.Stats.Slim != nil {
+				current.Maxconn += *stat.Stats.Slim
+			}
+			servers[stat.BackendName][stat.Name] = current
+		}
+	}
+	return servers, nil
+}
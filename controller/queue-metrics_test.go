@@ -0,0 +1,123 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/metrics"
+)
+
+const testStats = `# pxname,svname,qcur,scur,slim,status,
+http,FRONTEND,,12,,OPEN,
+default-app-80,SRV_1,3,10,10,UP,
+default-app-80,SRV_2,0,4,10,UP,
+default-app-80,SRV_3,,,10,MAINT,
+default-app-80,BACKEND,7,14,,UP,
+default-web-80,SRV_1,0,1,,UP,
+default-web-80,BACKEND,0,1,,UP,
+`
+
+func TestUpdateQueueMetrics(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	runtime := newTestRuntime(t, func(command string) string {
+		if command == "show stat" {
+			return testStats
+		}
+		return "\n"
+	})
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	c.osArgs.Test = false
+	c.osArgs.ControllerPort = 6060
+	c.updateQueueMetrics()
+
+	for backend, expected := range map[string]float64{"default-app-80": 7, "default-web-80": 0} {
+		if value, ok := metricBackendQueue.Get(backend); !ok || value != expected {
+			t.Errorf("backend %s: expected queue %v, got %v", backend, expected, value)
+		}
+	}
+	servers := []struct {
+		backend, server         string
+		queued, sessions, limit float64
+	}{
+		{"default-app-80", "srv_1", 3, 10, 10},
+		{"default-app-80", "srv_2", 0, 4, 10},
+		{"default-web-80", "srv_1", 0, 1, 0},
+	}
+	for _, s := range servers {
+		if value, _ := metricServerQueue.Get(s.backend, s.server); value != s.queued {
+			t.Errorf("%s/%s: expected queue %v, got %v", s.backend, s.server, s.queued, value)
+		}
+		if value, _ := metricServerSessions.Get(s.backend, s.server); value != s.sessions {
+			t.Errorf("%s/%s: expected sessions %v, got %v", s.backend, s.server, s.sessions, value)
+		}
+		if value, _ := metricServerMaxconn.Get(s.backend, s.server); value != s.limit {
+			t.Errorf("%s/%s: expected maxconn %v, got %v", s.backend, s.server, s.limit, value)
+		}
+	}
+	// servers in maintenance are empty slots
+	if _, ok := metricServerQueue.Get("default-app-80", "srv_3"); ok {
+		t.Error("expected no metric for server in maintenance")
+	}
+}
+
+func TestUpdateQueueMetricsServers(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.Test = false
+	c.osArgs.ControllerPort = 6060
+	update := func(stats string) {
+		runtime := newTestRuntime(t, func(command string) string {
+			if command == "show stat" {
+				return stats
+			}
+			return "\n"
+		})
+		defer runtime.close()
+		c.NativeAPI.Runtime = runtime.client(t)
+		c.updateQueueMetrics()
+	}
+	update(testStats)
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	for _, expected := range []string{
+		"# TYPE haproxy_ingress_server_queue gauge",
+		`haproxy_ingress_server_queue{backend="default-app-80",server="srv_1"} 3`,
+		`haproxy_ingress_server_sessions{backend="default-app-80",server="srv_1"} 10`,
+		`haproxy_ingress_server_maxconn{backend="default-app-80",server="srv_1"} 10`,
+	} {
+		if !strings.Contains(recorder.Body.String(), expected) {
+			t.Errorf("expected '%s' in:\n%s", expected, recorder.Body.String())
+		}
+	}
+
+	// servers removed from HAProxy are no longer exposed
+	update(`# pxname,svname,qcur,scur,slim,status,
+default-app-80,SRV_1,0,2,10,UP,
+default-app-80,BACKEND,0,2,,UP,
+`)
+	if value, _ := metricServerQueue.Get("default-app-80", "srv_1"); value != 0 {
+		t.Errorf("expected queue 0, got %v", value)
+	}
+	for _, server := range [][]string{{"default-app-80", "srv_2"}, {"default-web-80", "srv_1"}} {
+		if _, ok := metricServerQueue.Get(server...); ok {
+			t.Errorf("%s/%s: expected no metric", server[0], server[1])
+		}
+	}
+}
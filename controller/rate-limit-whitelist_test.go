@@ -0,0 +1,106 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRateLimitWhitelist(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{"rate-limit": &StringW{Value: "true", Status: ADDED}}
+	ingress := c.testIngress("default", "web")
+	ingress.Annotations = testAnnotations(map[string]string{
+		"rate-limit-whitelist":        "10.0.0.0/8, 192.168.0.1",
+		"rate-limit-whitelist-header": "X-Monitoring: probe",
+	})
+	c.addUseBackendRule("Rdefaultwebexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "web"}, FrontendHTTP, FrontendHTTPS)
+	config := c.testSync(t, func() {
+		if _, err := c.handleRateLimiting(true); err != nil {
+			t.Fatal(err)
+		}
+		if reload, err := c.handleRateLimitWhitelist(c.cfg.GetNamespace("default"), ingress); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		c.handleIngressMatch()
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	// ingress is matched, then exempt requests flagged, then others are counted and denied
+	expected := []string{
+		"http-request set-var(txn.ingress) str(default/web) if { req.hdr(host) -i example.com } { path_beg / } !{ var(txn.ingress) -m found }",
+		"http-request set-var(txn.ratelimit_exempt) bool(true) if { var(txn.ingress) -m str default/web } { src 10.0.0.0/8 192.168.0.1 }",
+		"http-request set-var(txn.ratelimit_exempt) bool(true) if { var(txn.ingress) -m str default/web } { req.hdr(X-Monitoring) -m str probe }",
+		"http-request deny if !{ var(txn.ratelimit_exempt) -m bool } ratelimit_is_abuse ratelimit_inc_cnt_abuse",
+		"http-request deny if ratelimit_cnt_abuse",
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		got := []string{}
+		for _, line := range testSection(config, frontend) {
+			switch {
+			case strings.HasPrefix(line, "http-request deny"):
+				// only conditions of deny rules are compared
+				got = append(got, "http-request deny if "+strings.SplitN(line, " if ", 2)[1])
+			case strings.HasPrefix(line, "http-request set-var"):
+				got = append(got, line)
+			}
+		}
+		if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", frontend, strings.Join(expected, "\n"), strings.Join(got, "\n"))
+		}
+	}
+
+	// exemptions are removed with the annotations
+	ingress.Annotations["rate-limit-whitelist"].Status = DELETED
+	ingress.Annotations["rate-limit-whitelist-header"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleRateLimitWhitelist(c.cfg.GetNamespace("default"), ingress); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "set-var(txn.ratelimit_exempt)") {
+		t.Errorf("expected no exemption:\n%s", config)
+	}
+}
+
+func TestRateLimitWhitelistInvalid(t *testing.T) {
+	for name, annotations := range map[string]map[string]string{
+		"invalid source":               {"rate-limit-whitelist": "10.0.0.0/40"},
+		"header without value":         {"rate-limit-whitelist-header": "X-Monitoring"},
+		"header with a space":          {"rate-limit-whitelist-header": "X-Monitoring: a probe"},
+		"header with a condition":      {"rate-limit-whitelist-header": "X-Monitoring: probe }"},
+		"valid source, invalid header": {"rate-limit-whitelist": "10.0.0.1", "rate-limit-whitelist-header": ": probe"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := c.testIngress("default", "web")
+			ingress.Annotations = testAnnotations(annotations)
+			c.addUseBackendRule("Rdefaultwebexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+			if _, err := c.handleRateLimitWhitelist(c.cfg.GetNamespace("default"), ingress); err == nil {
+				t.Error("expected error")
+			}
+			if len(c.cfg.HTTPRequests["RLW-default-web"]) != 0 {
+				t.Errorf("expected no exemption, got %v", c.cfg.HTTPRequests["RLW-default-web"])
+			}
+		})
+	}
+}
@@ -42,6 +42,10 @@ var ratelimitACL3 = models.ACL{
 	Value:     "gt 0",
 }
 
+// rateLimitExemptCond is true for requests whitelisted by "rate-limit-whitelist" annotations,
+// it is checked before the request rate so that exempt requests are not counted as abuse.
+const rateLimitExemptCond = "{ var(txn.ratelimit_exempt) -m bool }"
+
 func (c *HAProxyController) handleRateLimiting(usingHTTPS bool) (needReload bool, err error) {
 	needReload = false
 	annRateLimit, _ := GetValueFromAnnotations("rate-limit", c.cfg.ConfigMap.Annotations)
@@ -96,7 +100,7 @@ func (c *HAProxyController) handleRateLimiting(usingHTTPS bool) (needReload bool
 		ID:       utils.PtrInt64(0),
 		Type:     "deny",
 		Cond:     "if",
-		CondTest: fmt.Sprintf("%s %s", ratelimitACL1.ACLName, ratelimitACL2.ACLName),
+		CondTest: fmt.Sprintf("!%s %s %s", rateLimitExemptCond, ratelimitACL1.ACLName, ratelimitACL2.ACLName),
 	}
 	httpRequest2 := &models.HTTPRequestRule{
 		ID:       utils.PtrInt64(0),
@@ -175,8 +179,12 @@ func (c *HAProxyController) handleRateLimitingAnnotations(ingress *Ingress, serv
 	annWhitelist, _ := GetValueFromAnnotations("whitelist", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	annWhitelistRL, _ := GetValueFromAnnotations("whitelist-with-rate-limit", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
 	allowRateLimiting := annWhitelistRL.Value != "" && annWhitelistRL.Value != "OFF"
+	decoded, annPathMatch := c.pathMatchDecoded()
 	status := annWhitelist.Status
 	if status == EMPTY {
+		if annPathMatch != EMPTY && annWhitelist.Value != "" {
+			status = MODIFIED
+		}
 		if annWhitelistRL.Status != EMPTY {
 			data, ok := c.cfg.HTTPRequests[fmt.Sprintf("WHT-%s", path.Path)]
 			if ok && len(data) > 0 {
@@ -194,13 +202,13 @@ func (c *HAProxyController) handleRateLimitingAnnotations(ingress *Ingress, serv
 				ID:       utils.PtrInt64(0),
 				Type:     "allow",
 				Cond:     "if",
-				CondTest: fmt.Sprintf("{ path_beg %s } { src %s }", path.Path, strings.Replace(annWhitelist.Value, ",", " ", -1)),
+				CondTest: fmt.Sprintf("%s { src %s }", pathCond(path.Path, decoded), strings.Replace(annWhitelist.Value, ",", " ", -1)),
 			}
 			httpRequest2 := &models.HTTPRequestRule{
 				ID:       utils.PtrInt64(0),
 				Type:     "deny",
 				Cond:     "if",
-				CondTest: fmt.Sprintf("{ path_beg %s }", path.Path),
+				CondTest: pathCond(path.Path, decoded),
 			}
 			if allowRateLimiting {
 				c.cfg.HTTPRequests[fmt.Sprintf("WHT-%s", path.Path)] = []models.HTTPRequestRule{
@@ -208,8 +216,8 @@ func (c *HAProxyController) handleRateLimitingAnnotations(ingress *Ingress, serv
 				}
 			} else {
 				c.cfg.HTTPRequests[fmt.Sprintf("WHT-%s", path.Path)] = []models.HTTPRequestRule{
-					*httpRequest2, //reverse order
 					*httpRequest1,
+					*httpRequest2,
 				}
 			}
 		} else {
@@ -220,3 +228,51 @@ func (c *HAProxyController) handleRateLimitingAnnotations(ingress *Ingress, serv
 		c.cfg.HTTPRequests[fmt.Sprintf("WHT-%s", path.Path)] = []models.HTTPRequestRule{}
 	}
 }
+
+// handleRateLimitWhitelist exempts requests to the paths of an ingress from rate limiting
+// when they come from the IPs or CIDRs of "rate-limit-whitelist" annotation or have the header
+// of "rate-limit-whitelist-header" ("<name>: <value>"), e.g. for monitoring or internal clients.
+// Connections of a source already flagged as abusive are still rejected.
+func (c *HAProxyController) handleRateLimitWhitelist(namespace *Namespace, ingress *Ingress) (needsReload bool, err error) {
+	key := fmt.Sprintf("RLW-%s-%s", namespace.Name, ingress.Name)
+	rules := []models.HTTPRequestRule{}
+	defer func() {
+		needsReload = c.setHTTPRequests(key, rules)
+	}()
+	if ingress.Status == DELETED {
+		return false, nil
+	}
+	exempt := []string{}
+	if annSources, _ := GetValueFromAnnotations("rate-limit-whitelist", ingress.Annotations); annSources != nil && annSources.Status != DELETED {
+		sources, errParse := parseSources(annSources.Value)
+		if errParse != nil {
+			return false, fmt.Errorf("rate-limit-whitelist annotation: %s", errParse)
+		}
+		exempt = append(exempt, fmt.Sprintf("{ src %s }", strings.Join(sources, " ")))
+	}
+	if annHeader, _ := GetValueFromAnnotations("rate-limit-whitelist-header", ingress.Annotations); annHeader != nil && annHeader.Status != DELETED {
+		parts := strings.SplitN(annHeader.Value, ":", 2)
+		name, value := strings.TrimSpace(parts[0]), ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+		if name == "" || value == "" || strings.ContainsAny(name+value, " \t\"'(){}") {
+			return false, fmt.Errorf("rate-limit-whitelist-header annotation: expected '<name>: <value>', got '%s'", annHeader.Value)
+		}
+		exempt = append(exempt, fmt.Sprintf("{ req.hdr(%s) -m str %s }", name, value))
+	}
+	for _, cond := range c.ingressMatchConds(ingress) {
+		for _, exemptCond := range exempt {
+			rules = append(rules, models.HTTPRequestRule{
+				ID:       utils.PtrInt64(0),
+				Type:     "set-var",
+				VarScope: "txn",
+				VarName:  "ratelimit_exempt",
+				VarExpr:  "bool(true)",
+				Cond:     "if",
+				CondTest: cond + " " + exemptCond,
+			})
+		}
+	}
+	return false, nil
+}
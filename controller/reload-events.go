@@ -0,0 +1,95 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// reloadEventInterval is the minimum interval between two "Reloaded" events,
+// reloads happening in between are reported by the next event
+const reloadEventInterval = 30 * time.Second
+
+// reloadEvents records a "Reloaded" Normal event on the controller pod,
+// given by POD_NAME and POD_NAMESPACE environment variables, after successful reloads.
+type reloadEvents struct {
+	namespace string
+	pod       string
+	reasons   map[string]struct{}
+	pending   int
+	lastEvent time.Time
+}
+
+func newReloadEvents() *reloadEvents {
+	return &reloadEvents{
+		namespace: os.Getenv("POD_NAMESPACE"),
+		pod:       os.Getenv("POD_NAME"),
+		reasons:   map[string]struct{}{},
+	}
+}
+
+// addReason records why configuration changed since last update
+func (r *reloadEvents) addReason(reason string) {
+	r.reasons[reason] = struct{}{}
+}
+
+// clearReasons drops reasons once reported or when changes did not need a reload
+func (r *reloadEvents) clearReasons() {
+	r.reasons = map[string]struct{}{}
+}
+
+// reportReload is called after each update, it emits the event of pending
+// reloads unless the last event is more recent than reloadEventInterval
+func (c *HAProxyController) reportReload(reloaded bool) {
+	r := c.reloadEvents
+	if r.pod == "" || r.namespace == "" || c.k8s == nil {
+		return
+	}
+	if reloaded {
+		r.pending++
+	}
+	if r.pending == 0 {
+		r.clearReasons()
+		return
+	}
+	if time.Since(r.lastEvent) < reloadEventInterval {
+		return
+	}
+	reasons := make([]string, 0, len(r.reasons))
+	for reason := range r.reasons {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	message := "HAProxy reloaded"
+	if r.pending > 1 {
+		message = fmt.Sprintf("HAProxy reloaded %d times", r.pending)
+	}
+	if len(reasons) > 0 {
+		message = fmt.Sprintf("%s (%s)", message, strings.Join(reasons, ", "))
+	}
+	r.pending = 0
+	r.lastEvent = time.Now()
+	r.clearReasons()
+	go func() {
+		utils.LogErr(c.k8s.CreatePodEvent(r.namespace, r.pod, corev1.EventTypeNormal, "Reloaded", message))
+	}()
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReportReload(t *testing.T) {
+	c := &HAProxyController{}
+	client := c.testK8s()
+	c.reloadEvents = &reloadEvents{namespace: "haproxy-controller", pod: "haproxy-ingress-1", reasons: map[string]struct{}{}}
+
+	// changes without reload are not reported
+	c.reloadEvents.addReason("endpoints")
+	c.reportReload(false)
+	testEvents(t, client, "haproxy-controller", 0)
+
+	c.reloadEvents.addReason("ingress")
+	c.reloadEvents.addReason("configmap")
+	c.reportReload(true)
+	events := testEvents(t, client, "haproxy-controller", 1)
+	event := events[0]
+	if event.Type != "Normal" || event.Reason != "Reloaded" || event.InvolvedObject.Kind != "Pod" || event.InvolvedObject.Name != "haproxy-ingress-1" {
+		t.Errorf("unexpected event %s %s on %s %s", event.Type, event.Reason, event.InvolvedObject.Kind, event.InvolvedObject.Name)
+	}
+	if event.Message != "HAProxy reloaded (configmap, ingress)" {
+		t.Errorf("unexpected message '%s'", event.Message)
+	}
+
+	// rate limited, reloads are reported by next event
+	c.reloadEvents.addReason("service")
+	c.reportReload(true)
+	c.reloadEvents.addReason("secret")
+	c.reportReload(true)
+	testEvents(t, client, "haproxy-controller", 1)
+	c.reloadEvents.lastEvent = time.Now().Add(-reloadEventInterval)
+	c.reportReload(false)
+	events = testEvents(t, client, "haproxy-controller", 2)
+	for _, event := range events {
+		if event.Message != "HAProxy reloaded (configmap, ingress)" && event.Message != "HAProxy reloaded 2 times (secret, service)" {
+			t.Errorf("unexpected message '%s'", event.Message)
+		}
+	}
+}
+
+func TestReportReloadWithoutPod(t *testing.T) {
+	c := &HAProxyController{}
+	client := c.testK8s()
+	c.reloadEvents = &reloadEvents{reasons: map[string]struct{}{}}
+	c.reportReload(true)
+	testEvents(t, client, "", 0)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// reloadCommand returns the shell command of --reload-command with placeholders replaced, see haproxyCommand
+func (c *HAProxyController) reloadCommand() string {
+	return c.expandCommand(c.osArgs.ReloadCommand)
+}
+
+// startCommand returns the shell command of --start-command with placeholders replaced, see haproxyCommand
+func (c *HAProxyController) startCommand() string {
+	return c.expandCommand(c.osArgs.StartCommand)
+}
+
+// expandCommand replaces "{binary}" by --haproxy-binary, "{config}" by HAProxy configuration file
+// and "{include}" by "-f <file>" of the --config-include file when one is installed, empty otherwise
+func (c *HAProxyController) expandCommand(command string) string {
+	include := ""
+	if _, err := os.Stat(HAProxyIncludeCFG); err == nil {
+		include = "-f " + HAProxyIncludeCFG
+	}
+	return strings.NewReplacer(
+		"{binary}", c.haproxyBinary(),
+		"{config}", HAProxyCFG,
+		"{include}", include,
+	).Replace(command)
+}
+
+// haproxyCommand returns the shell command starting or reloading HAProxy, HAPROXY_BINARY
+// environment variable is set to --haproxy-binary for the init script of the image
+func (c *HAProxyController) haproxyCommand(command string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "HAPROXY_BINARY="+c.haproxyBinary())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd
+}
+
+// haproxyBinary is the HAProxy binary used for version detection and configuration checks
+func (c *HAProxyController) haproxyBinary() string {
+	if c.osArgs.HAProxyBinary == "" {
+		return "haproxy"
+	}
+	return c.osArgs.HAProxyBinary
+}
+
+// checkHAProxyBinary returns an error if --haproxy-binary can not be found or
+// --reload-command or --start-command is empty
+func (c *HAProxyController) checkHAProxyBinary() error {
+	if _, err := exec.LookPath(c.haproxyBinary()); err != nil {
+		return fmt.Errorf("haproxy-binary: %s", err)
+	}
+	if strings.TrimSpace(c.osArgs.ReloadCommand) == "" {
+		return fmt.Errorf("reload-command: empty command")
+	}
+	if strings.TrimSpace(c.osArgs.StartCommand) == "" {
+		return fmt.Errorf("start-command: empty command")
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReloadCommand(t *testing.T) {
+	cfg := HAProxyCFG
+	HAProxyCFG = "/etc/haproxy/haproxy.cfg"
+	defer func() { HAProxyCFG = cfg }()
+	c := &HAProxyController{}
+	c.osArgs.HAProxyBinary = "/usr/local/sbin/haproxy"
+	c.osArgs.ReloadCommand = "{binary} -c -f {config} && /usr/bin/reload-wrapper {config}"
+	expected := "/usr/local/sbin/haproxy -c -f /etc/haproxy/haproxy.cfg && /usr/bin/reload-wrapper /etc/haproxy/haproxy.cfg"
+	if got := c.reloadCommand(); got != expected {
+		t.Errorf("expected '%s', got '%s'", expected, got)
+	}
+	c.osArgs.HAProxyBinary = ""
+	if got := c.haproxyBinary(); got != "haproxy" {
+		t.Errorf("expected default binary, got '%s'", got)
+	}
+}
+
+func TestStartCommandInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-start")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	cfg, include := HAProxyCFG, HAProxyIncludeCFG
+	HAProxyCFG, HAProxyIncludeCFG = filepath.Join(dir, "haproxy.cfg"), filepath.Join(dir, "include.cfg")
+	defer func() { HAProxyCFG, HAProxyIncludeCFG = cfg, include }()
+	c := &HAProxyController{}
+	c.osArgs.HAProxyBinary = "/usr/local/sbin/haproxy"
+	c.osArgs.StartCommand = "{binary} -f {config} {include} -p /var/run/haproxy.pid"
+	c.osArgs.ReloadCommand = "{binary} -f {config} {include} -sf $(cat /var/run/haproxy.pid)"
+	// include file is not installed
+	expected := "/usr/local/sbin/haproxy -f " + HAProxyCFG + "  -p /var/run/haproxy.pid"
+	if got := c.startCommand(); got != expected {
+		t.Errorf("expected '%s', got '%s'", expected, got)
+	}
+	if err = ioutil.WriteFile(HAProxyIncludeCFG, []byte("userlist users\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected = "/usr/local/sbin/haproxy -f " + HAProxyCFG + " -f " + HAProxyIncludeCFG + " -p /var/run/haproxy.pid"
+	if got := c.startCommand(); got != expected {
+		t.Errorf("expected '%s', got '%s'", expected, got)
+	}
+	expected = "/usr/local/sbin/haproxy -f " + HAProxyCFG + " -f " + HAProxyIncludeCFG + " -sf $(cat /var/run/haproxy.pid)"
+	if got := c.reloadCommand(); got != expected {
+		t.Errorf("expected '%s', got '%s'", expected, got)
+	}
+}
+
+func TestServiceProcessStart(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	dir := filepath.Dir(c.NativeAPI.Configuration.ConfigurationFile)
+	output := filepath.Join(dir, "start")
+	c.osArgs.HAProxyBinary = "/opt/haproxy/bin/haproxy"
+	// default init script runs $HAPROXY_BINARY
+	c.osArgs.StartCommand = "echo $HAPROXY_BINARY {binary} > " + output
+	process := serviceProcess{pidFile: filepath.Join(dir, "haproxy.pid"), command: func() *exec.Cmd {
+		return c.haproxyCommand(c.startCommand())
+	}}
+	if err := ioutil.WriteFile(process.pidFile, []byte("1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := process.Start(); err != nil {
+		t.Fatal(err)
+	}
+	expected := "/opt/haproxy/bin/haproxy /opt/haproxy/bin/haproxy"
+	deadline := time.Now().Add(2 * time.Second)
+	content, _ := ioutil.ReadFile(output)
+	for strings.TrimSpace(string(content)) != expected && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		content, _ = ioutil.ReadFile(output)
+	}
+	if strings.TrimSpace(string(content)) != expected {
+		t.Errorf("expected start command output '%s', got '%s'", expected, content)
+	}
+	if _, err := os.Stat(process.pidFile); !os.IsNotExist(err) {
+		t.Errorf("expected pidfile left by a previous master to be removed, got %v", err)
+	}
+}
+
+func TestCheckHAProxyBinary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "haproxy-ingress-binary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	binary := filepath.Join(dir, "haproxy")
+	if err = ioutil.WriteFile(binary, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	c := &HAProxyController{}
+	c.osArgs.HAProxyBinary = binary
+	c.osArgs.ReloadCommand = "service haproxy reload"
+	c.osArgs.StartCommand = "service haproxy start"
+	if err = c.checkHAProxyBinary(); err != nil {
+		t.Error(err)
+	}
+	c.osArgs.ReloadCommand = " "
+	if err = c.checkHAProxyBinary(); err == nil {
+		t.Error("expected error with empty reload command")
+	}
+	c.osArgs.ReloadCommand = "service haproxy reload"
+	c.osArgs.StartCommand = ""
+	if err = c.checkHAProxyBinary(); err == nil {
+		t.Error("expected error with empty start command")
+	}
+	c.osArgs.StartCommand = "service haproxy start"
+	c.osArgs.HAProxyBinary = filepath.Join(dir, "missing")
+	if err = c.checkHAProxyBinary(); err == nil {
+		t.Error("expected error with missing binary")
+	}
+}
+
+func TestHAProxyReloadCommand(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	runtime := newTestRuntime(t, func(command string) string { return "1\n" })
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	dir := filepath.Dir(c.NativeAPI.Configuration.ConfigurationFile)
+	output := filepath.Join(dir, "reload")
+	cfg := HAProxyCFG
+	HAProxyCFG = c.NativeAPI.Configuration.ConfigurationFile
+	defer func() { HAProxyCFG = cfg }()
+	c.osArgs.Test = false
+	c.osArgs.HAProxyBinary = "/opt/haproxy/bin/haproxy"
+	c.osArgs.ReloadCommand = "echo {binary} -f {config} > " + output
+
+	if err := c.HAProxyReload(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for atomic.LoadInt32(&c.reloadsInFlight) != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	content, err := ioutil.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "/opt/haproxy/bin/haproxy -f " + HAProxyCFG
+	if strings.TrimSpace(string(content)) != expected {
+		t.Errorf("expected reload command output '%s', got '%s'", expected, content)
+	}
+	if state, _ := ioutil.ReadFile(filepath.Join(dir, "global")); string(state) != "1" {
+		t.Errorf("expected server state saved before reload, got '%s'", state)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// replaceValue is a "<header> <match-regex> <replace-fmt>" item of replace-value annotations
+type replaceValue struct {
+	header  string
+	match   string
+	replace string
+}
+
+// handleBackendReplaceValue rewrites the values of comma separated request and response headers
+// (e.g. Cookie, Forwarded) in the backend of a service with "request-replace-value" and
+// "response-replace-value" annotations. Unlike set-header, values not matching the regex are kept.
+func (c *HAProxyController) handleBackendReplaceValue(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	requestItems, err := c.replaceValueAnnotation("request-replace-value", ingress, service)
+	if err != nil {
+		return false, err
+	}
+	responseItems, err := c.replaceValueAnnotation("response-replace-value", ingress, service)
+	if err != nil {
+		return false, err
+	}
+
+	wantedRequests := make([]models.HTTPRequestRule, 0, len(requestItems))
+	for _, item := range requestItems {
+		wantedRequests = append(wantedRequests, models.HTTPRequestRule{Type: "replace-value", HdrName: item.header, HdrMatch: item.match, HdrFormat: item.replace})
+	}
+	_, requests, err := c.NativeAPI.Configuration.GetHTTPRequestRules("backend", backendName, c.ActiveTransaction)
+	if err != nil {
+		return false, err
+	}
+	currentRequests := []*models.HTTPRequestRule{}
+	for _, rule := range requests {
+		if rule.Type == "replace-value" {
+			currentRequests = append(currentRequests, rule)
+		}
+	}
+	if !replaceValueRequestsEqual(currentRequests, wantedRequests) {
+		c.ActiveTransactionHasChanges = true
+		needsReload = true
+		// IDs of following rules shift after each deletion
+		for i := len(currentRequests) - 1; i >= 0; i-- {
+			if errDel := c.NativeAPI.Configuration.DeleteHTTPRequestRule(*currentRequests[i].ID, "backend", backendName, c.ActiveTransaction, 0); errDel != nil {
+				return true, errDel
+			}
+		}
+		//INFO: order is reversed, first you insert last ones
+		for i := len(wantedRequests) - 1; i >= 0; i-- {
+			rule := wantedRequests[i]
+			rule.ID = utils.PtrInt64(0)
+			if errCreate := c.NativeAPI.Configuration.CreateHTTPRequestRule("backend", backendName, &rule, c.ActiveTransaction, 0); errCreate != nil {
+				return true, errCreate
+			}
+		}
+	}
+
+	wantedResponses := make([]models.HTTPResponseRule, 0, len(responseItems))
+	for _, item := range responseItems {
+		wantedResponses = append(wantedResponses, models.HTTPResponseRule{Type: "replace-value", HdrName: item.header, HdrMatch: item.match, HdrFormat: item.replace})
+	}
+	_, responses, err := c.NativeAPI.Configuration.GetHTTPResponseRules("backend", backendName, c.ActiveTransaction)
+	if err != nil {
+		return needsReload, err
+	}
+	currentResponses := []*models.HTTPResponseRule{}
+	for _, rule := range responses {
+		if rule.Type == "replace-value" {
+			currentResponses = append(currentResponses, rule)
+		}
+	}
+	if replaceValueResponsesEqual(currentResponses, wantedResponses) {
+		return needsReload, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	for i := len(currentResponses) - 1; i >= 0; i-- {
+		if errDel := c.NativeAPI.Configuration.DeleteHTTPResponseRule(*currentResponses[i].ID, "backend", backendName, c.ActiveTransaction, 0); errDel != nil {
+			return true, errDel
+		}
+	}
+	for i := len(wantedResponses) - 1; i >= 0; i-- {
+		rule := wantedResponses[i]
+		rule.ID = utils.PtrInt64(0)
+		if errCreate := c.NativeAPI.Configuration.CreateHTTPResponseRule("backend", backendName, &rule, c.ActiveTransaction, 0); errCreate != nil {
+			return true, errCreate
+		}
+	}
+	return true, nil
+}
+
+// replaceValueAnnotation returns the parsed items of a replace-value annotation
+func (c *HAProxyController) replaceValueAnnotation(name string, ingress *Ingress, service *Service) ([]replaceValue, error) {
+	ann, _ := GetValueFromAnnotations(name, service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if ann == nil || ann.Status == DELETED {
+		return nil, nil
+	}
+	items, err := parseReplaceValues(ann.Value)
+	if err != nil {
+		return nil, fmt.Errorf("%s annotation: %s", name, err)
+	}
+	return items, nil
+}
+
+// parseReplaceValues validates a new line separated list of "<header> <match-regex> <replace-fmt>" items,
+// regex and format can not contain spaces ("\s" can be used in regex), quotes or "#"
+func parseReplaceValues(value string) (items []replaceValue, err error) {
+	for _, line := range strings.Split(value, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("incorrect value '%s', expected '<header> <match-regex> <replace-fmt>'", strings.TrimSpace(line))
+		}
+		if strings.ContainsAny(fields[0], `"'\`) {
+			return nil, fmt.Errorf("incorrect header name '%s'", fields[0])
+		}
+		if strings.ContainsAny(fields[1]+fields[2], `"'#`) {
+			return nil, fmt.Errorf("quotes and '#' are not allowed in '%s'", strings.TrimSpace(line))
+		}
+		if _, errRegexp := regexp.Compile(fields[1]); errRegexp != nil {
+			return nil, fmt.Errorf("incorrect regex '%s': %s", fields[1], errRegexp)
+		}
+		items = append(items, replaceValue{header: fields[0], match: fields[1], replace: fields[2]})
+	}
+	if len(items) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	return items, nil
+}
+
+func replaceValueRequestsEqual(current []*models.HTTPRequestRule, wanted []models.HTTPRequestRule) bool {
+	if len(current) != len(wanted) {
+		return false
+	}
+	for i, rule := range current {
+		if rule.HdrName != wanted[i].HdrName || rule.HdrMatch != wanted[i].HdrMatch || rule.HdrFormat != wanted[i].HdrFormat || rule.Cond != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func replaceValueResponsesEqual(current []*models.HTTPResponseRule, wanted []models.HTTPResponseRule) bool {
+	if len(current) != len(wanted) {
+		return false
+	}
+	for i, rule := range current {
+		if rule.HdrName != wanted[i].HdrName || rule.HdrMatch != wanted[i].HdrMatch || rule.HdrFormat != wanted[i].HdrFormat || rule.Cond != "" {
+			return false
+		}
+	}
+	return true
+}
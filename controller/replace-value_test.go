@@ -0,0 +1,102 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+const testCookieReplaceValue = `Cookie ^(.*;\s*)?session=[^;]*(.*)$ \1session=anonymous\2`
+
+func TestHandleBackendReplaceValue(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{
+		"request-replace-value":  "Forwarded ^(.*)proto=http(;.*)?$ \\1proto=https\\2\n" + testCookieReplaceValue + "\n",
+		"response-replace-value": "Cache-Control ^private$ no-store",
+	})}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleBackendReplaceValue(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	expected := []string{
+		`http-request replace-value Forwarded ^(.*)proto=http(;.*)?$ \1proto=https\2`,
+		"http-request " + strings.Replace(testCookieReplaceValue, "Cookie", "replace-value Cookie", 1),
+		"http-response replace-value Cache-Control ^private$ no-store",
+	}
+	got := []string{}
+	for _, line := range testSection(config, "backend default-app-80") {
+		if strings.Contains(line, "replace-value") {
+			got = append(got, line)
+		}
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendReplaceValue(ingress, service, "default-app-80"); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+
+	service.Annotations["response-replace-value"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleBackendReplaceValue(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "http-response replace-value") || strings.Count(config, "http-request replace-value") != 2 {
+		t.Errorf("expected request rules only:\n%s", config)
+	}
+}
+
+func TestReplaceValueCookie(t *testing.T) {
+	items, err := parseReplaceValues(testCookieReplaceValue)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("expected one item, got %v %v", items, err)
+	}
+	// HAProxy references regex groups with \1, Go with ${1}
+	match := regexp.MustCompile(items[0].match)
+	replace := regexp.MustCompile(`\\(\d)`).ReplaceAllString(items[0].replace, "$${$1}")
+	for cookie, expected := range map[string]string{
+		"session=abc":                      "session=anonymous",
+		"lang=en; session=abc; theme=dark": "lang=en; session=anonymous; theme=dark",
+		"lang=en; theme=dark":              "lang=en; theme=dark",
+	} {
+		if got := match.ReplaceAllString(cookie, replace); got != expected {
+			t.Errorf("%s: expected '%s', got '%s'", cookie, expected, got)
+		}
+	}
+}
+
+func TestParseReplaceValuesInvalid(t *testing.T) {
+	for _, value := range []string{
+		"",
+		"Cookie ^session$",
+		"Cookie ^session$ anonymous extra",
+		`Cookie "session" anonymous`,
+		"Cookie ^session$ #anonymous",
+		"Cookie ^(session$ anonymous",
+		`X\Header ^a$ b`,
+	} {
+		if items, err := parseReplaceValues(value); err == nil {
+			t.Errorf("'%s': expected error, got %v", value, items)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// handleBackendRequestBuffering waits for the whole request body before
+// connecting to the servers of a backend when "request-buffering" annotation is enabled.
+// "option http-buffer-request" is deprecated since HAProxy 2.4 in favor of
+// "http-request wait-for-body", the directive is chosen by the detected version.
+// While waiting for the body HAProxy answers "Expect: 100-continue" itself, so
+// "expect-continue" annotation overrides request-buffering: "haproxy" enables it,
+// "backend" disables it to let servers reject a request (e.g. 401) before its body is sent.
+func (c *HAProxyController) handleBackendRequestBuffering(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	enabled := false
+	annBuffering, _ := GetValueFromAnnotations("request-buffering", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annBuffering != nil && annBuffering.Status != DELETED {
+		if enabled, err = utils.GetBoolValue(annBuffering.Value, "request-buffering"); err != nil {
+			return false, fmt.Errorf("request-buffering annotation: %s", err)
+		}
+	}
+	annExpect, _ := GetValueFromAnnotations("expect-continue", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annExpect != nil && annExpect.Status != DELETED {
+		switch annExpect.Value {
+		case "haproxy":
+			enabled = true
+		case "backend":
+			enabled = false
+		default:
+			return false, fmt.Errorf("expect-continue annotation: incorrect value '%s', expected haproxy or backend", annExpect.Value)
+		}
+	}
+	waitForBody := enabled && c.featureSupported("wait-for-body")
+	bufferRequest := enabled && !waitForBody
+
+	lines := []string{}
+	if waitForBody {
+		annTimeout, _ := GetValueFromAnnotations("timeout-http-request", c.cfg.ConfigMap.Annotations)
+		lines = append(lines, "http-request wait-for-body time "+annTimeout.Value)
+	}
+	needsReload, err = c.sectionDirectivesSet(parser.Backends, backendName, "http-request wait-for-body", lines)
+	if err != nil {
+		return needsReload, err
+	}
+
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return needsReload, err
+	}
+	_, errGet := config.Get(parser.Backends, backendName, "option http-buffer-request")
+	if (errGet == nil) == bufferRequest {
+		return needsReload, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	if bufferRequest {
+		return true, config.Set(parser.Backends, backendName, "option http-buffer-request", types.SimpleOption{})
+	}
+	return true, config.Set(parser.Backends, backendName, "option http-buffer-request", nil)
+}
@@ -0,0 +1,135 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendRequestBuffering(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		version     HAProxyVersion
+		expected    string
+		err         bool
+	}{
+		{
+			name:        "wait-for-body on 2.4",
+			annotations: map[string]string{"request-buffering": "true"},
+			version:     HAProxyVersion{2, 4, 0},
+			expected:    "http-request wait-for-body time 5s",
+		},
+		{
+			name:        "http-buffer-request before 2.4",
+			annotations: map[string]string{"request-buffering": "true"},
+			version:     HAProxyVersion{2, 2, 9},
+			expected:    "option http-buffer-request",
+		},
+		{
+			name:        "undetected version",
+			annotations: map[string]string{"request-buffering": "true"},
+			expected:    "http-request wait-for-body time 5s",
+		},
+		{
+			name:        "disabled",
+			annotations: map[string]string{"request-buffering": "false"},
+			version:     HAProxyVersion{2, 4, 0},
+		},
+		{
+			name:        "expect-continue answered by haproxy",
+			annotations: map[string]string{"expect-continue": "haproxy"},
+			version:     HAProxyVersion{2, 0, 0},
+			expected:    "option http-buffer-request",
+		},
+		{
+			name:        "expect-continue answered by backend",
+			annotations: map[string]string{"request-buffering": "true", "expect-continue": "backend"},
+			version:     HAProxyVersion{2, 4, 0},
+		},
+		{
+			name:        "invalid expect-continue",
+			annotations: map[string]string{"expect-continue": "client"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.haproxyVersion = test.version
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendRequestBuffering(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "http-request wait-for-body") || line == "option http-buffer-request" {
+					got = append(got, line)
+				}
+			}
+			expected := []string{}
+			if test.expected != "" {
+				expected = append(expected, test.expected)
+			}
+			if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+				t.Errorf("expected '%s', got '%s'", test.expected, strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestHandleBackendExpectContinueUpdate(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.haproxyVersion = HAProxyVersion{2, 4, 0}
+	ingress := &Ingress{Annotations: MapStringW{}}
+	// configured in the ConfigMap for all backends
+	c.cfg.ConfigMap.Annotations = MapStringW{"expect-continue": &StringW{Value: "haproxy", Status: ADDED}}
+	service := &Service{Annotations: MapStringW{}}
+	buffering := func() string {
+		config := c.testSync(t, func() {
+			if _, err := c.handleBackendRequestBuffering(ingress, service, "default-app-80"); err != nil {
+				t.Fatal(err)
+			}
+		})
+		got := []string{}
+		for _, line := range testSection(config, "backend default-app-80") {
+			if strings.HasPrefix(line, "http-request wait-for-body") || line == "option http-buffer-request" {
+				got = append(got, line)
+			}
+		}
+		return strings.Join(got, "\n")
+	}
+	if got := buffering(); got != "http-request wait-for-body time 5s" {
+		t.Errorf("expected HAProxy to answer 100-continue, got '%s'", got)
+	}
+	// service annotation takes precedence
+	service.Annotations["expect-continue"] = &StringW{Value: "backend", Status: ADDED}
+	if got := buffering(); got != "" {
+		t.Errorf("expected backend to answer 100-continue, got '%s'", got)
+	}
+	// back to the ConfigMap value once removed
+	service.Annotations["expect-continue"].Status = DELETED
+	if got := buffering(); got != "http-request wait-for-body time 5s" {
+		t.Errorf("expected HAProxy to answer 100-continue, got '%s'", got)
+	}
+}
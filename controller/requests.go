@@ -15,38 +15,95 @@
 package controller
 
 import (
+	"reflect"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
 	"github.com/haproxytech/models"
 )
 
+//RulePhase defines in which order rules added by different features are evaluated,
+//rules of a lower phase are always emitted first regardless of enabled features
+type RulePhase int
+
+const (
+	PhaseIngressMatch RulePhase = iota
+	PhaseCapture
+	PhaseDeny
+	PhaseRedirect
+	PhaseRewrite
+	PhaseAuth
+	PhaseUseBackend
+)
+
+//rulePhases maps keys of HTTPRequests/TCPRequests to their phase,
+//keys not listed here are matched by prefix in rulePhase
+var rulePhases = map[string]RulePhase{
+	INGRESS_MATCH:     PhaseIngressMatch,
+	REQUEST_CAPTURE:   PhaseCapture,
+	EXPECT_PROXY:      PhaseCapture,
+	JWT_CAPTURE:       PhaseCapture,
+	RATE_LIMIT:        PhaseDeny,
+	CONN_RATE_LIMIT:   PhaseDeny,
+	TLS_RATE_LIMIT:    PhaseDeny,
+	HTTP_REDIRECT:     PhaseRedirect,
+	X_FORWARDED_PROTO: PhaseRewrite,
+}
+
+func rulePhase(key string) RulePhase {
+	if phase, ok := rulePhases[key]; ok {
+		return phase
+	}
+	switch {
+	case strings.HasPrefix(key, "WHT-"):
+		return PhaseDeny
+	case strings.HasPrefix(key, "JWT-"):
+		return PhaseAuth
+	case strings.HasPrefix(key, "RLW-"):
+		// rate limit whitelist is set before rate limit rules
+		return PhaseCapture
+	case strings.HasPrefix(key, "R"):
+		// use_backend rules, see handleService
+		return PhaseUseBackend
+	}
+	return PhaseRewrite
+}
+
+//sortRuleKeys sorts rule keys by phase, keys of a same phase are sorted alphabetically
+func sortRuleKeys(keys []string) {
+	sort.Slice(keys, func(i, j int) bool {
+		phaseI, phaseJ := rulePhase(keys[i]), rulePhase(keys[j])
+		if phaseI != phaseJ {
+			return phaseI < phaseJ
+		}
+		return keys[i] < keys[j]
+	})
+}
+
+// setHTTPRequests replaces HTTP request rules of key, HTTP frontends are
+// refreshed only if rules changed. Key is removed when there are no rules.
+func (c *HAProxyController) setHTTPRequests(key string, rules []models.HTTPRequestRule) (changed bool) {
+	current := c.cfg.HTTPRequests[key]
+	if len(rules) == 0 {
+		delete(c.cfg.HTTPRequests, key)
+	} else {
+		c.cfg.HTTPRequests[key] = rules
+	}
+	if reflect.DeepEqual(current, rules) || (len(current) == 0 && len(rules) == 0) {
+		return false
+	}
+	c.cfg.HTTPRequestsStatus = MODIFIED
+	return true
+}
+
 func (c *HAProxyController) RequestsHTTPRefresh() (needsReload bool, err error) {
 	needsReload = false
 	if c.cfg.HTTPRequestsStatus == EMPTY {
 		return needsReload, nil
 	}
 
-	c.frontendHTTPRequestRuleDeleteAll(FrontendHTTP)
-	c.frontendHTTPRequestRuleDeleteAll(FrontendHTTPS)
-	//INFO: order is reversed, first you insert last ones
-	if len(c.cfg.HTTPRequests[HTTP_REDIRECT]) > 0 {
-		err = c.frontendHTTPRequestRuleCreate(FrontendHTTP, c.cfg.HTTPRequests[HTTP_REDIRECT][0])
-		utils.LogErr(err)
-	}
-	if len(c.cfg.HTTPRequests[RATE_LIMIT]) > 0 {
-
-		err = c.frontendHTTPRequestRuleCreate(FrontendHTTP, c.cfg.HTTPRequests[RATE_LIMIT][1])
-		utils.LogErr(err)
-		err = c.frontendHTTPRequestRuleCreate(FrontendHTTP, c.cfg.HTTPRequests[RATE_LIMIT][0])
-		utils.LogErr(err)
-
-		err = c.frontendHTTPRequestRuleCreate(FrontendHTTPS, c.cfg.HTTPRequests[RATE_LIMIT][1])
-		utils.LogErr(err)
-		err = c.frontendHTTPRequestRuleCreate(FrontendHTTPS, c.cfg.HTTPRequests[RATE_LIMIT][0])
-		utils.LogErr(err)
-	}
 	xforwardedprotoRule := models.HTTPRequestRule{
 		ID:        utils.PtrInt64(0),
 		Type:      "set-header",
@@ -55,32 +112,34 @@ func (c *HAProxyController) RequestsHTTPRefresh() (needsReload bool, err error)
 		Cond:      "if",
 		CondTest:  "{ ssl_fc }",
 	}
-	err = c.frontendHTTPRequestRuleCreate(FrontendHTTPS, xforwardedprotoRule)
-	utils.LogErr(err)
-
-	sortedList := []string{}
-	exclude := map[string]struct{}{
-		HTTP_REDIRECT: struct{}{},
-		RATE_LIMIT:    struct{}{},
-	}
-	for name := range c.cfg.HTTPRequests {
-		_, excluding := exclude[name]
-		if !excluding {
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		c.frontendHTTPRequestRuleDeleteAll(frontend)
+		requests := make(map[string][]models.HTTPRequestRule, len(c.cfg.HTTPRequests)+1)
+		for name, rules := range c.cfg.HTTPRequests {
+			requests[name] = rules
+		}
+		if rules := c.cfg.IngressMatchRules[frontend]; len(rules) > 0 {
+			requests[INGRESS_MATCH] = rules
+		}
+		if frontend == FrontendHTTPS {
+			delete(requests, HTTP_REDIRECT)
+			requests[X_FORWARDED_PROTO] = []models.HTTPRequestRule{xforwardedprotoRule}
+		}
+		sortedList := make([]string, 0, len(requests))
+		for name := range requests {
 			sortedList = append(sortedList, name)
 		}
-	}
-	sort.Sort(sort.Reverse(sort.StringSlice(sortedList))) // reverse order
-	for _, name := range sortedList {
-		for _, request := range c.cfg.HTTPRequests[name] {
-			err = c.frontendHTTPRequestRuleCreate(FrontendHTTP, request)
-			utils.LogErr(err)
-			err = c.frontendHTTPRequestRuleCreate(FrontendHTTPS, request)
-			utils.LogErr(err)
+		sortRuleKeys(sortedList)
+		//INFO: order is reversed, first you insert last ones
+		for i := len(sortedList) - 1; i >= 0; i-- {
+			rules := requests[sortedList[i]]
+			for j := len(rules) - 1; j >= 0; j-- {
+				err = c.frontendHTTPRequestRuleCreate(frontend, rules[j])
+				utils.LogErr(err)
+			}
 		}
 	}
-	if c.cfg.HTTPRequestsStatus != EMPTY {
-		needsReload = true
-	}
+	needsReload = true
 
 	return needsReload, nil
 }
@@ -92,19 +151,22 @@ func (c *HAProxyController) requestsTCPRefresh() (needsReload bool, err error) {
 	}
 
 	// Frontends HTTP and HTTPS
-	c.frontendTCPRequestRuleDeleteAll(FrontendHTTP)
-	c.frontendTCPRequestRuleDeleteAll(FrontendHTTPS)
-
-	if len(c.cfg.TCPRequests[RATE_LIMIT]) > 0 {
-		err = c.frontendTCPRequestRuleCreate(FrontendHTTP, c.cfg.TCPRequests[RATE_LIMIT][0])
-		utils.LogErr(err)
-		err = c.frontendTCPRequestRuleCreate(FrontendHTTP, c.cfg.TCPRequests[RATE_LIMIT][1])
-		utils.LogErr(err)
-
-		err = c.frontendTCPRequestRuleCreate(FrontendHTTPS, c.cfg.TCPRequests[RATE_LIMIT][0])
-		utils.LogErr(err)
-		err = c.frontendTCPRequestRuleCreate(FrontendHTTPS, c.cfg.TCPRequests[RATE_LIMIT][1])
-		utils.LogErr(err)
+	//INFO: order is reversed, first you insert last ones
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		c.frontendTCPRequestRuleDeleteAll(frontend)
+		// PROXY protocol header is read first so rules see the real source address,
+		// global connection rate is checked before per source rate limiting
+		names := []string{RATE_LIMIT, CONN_RATE_LIMIT, EXPECT_PROXY}
+		if frontend == FrontendHTTPS && !c.cfg.SSLPassthrough {
+			names = []string{RATE_LIMIT, TLS_RATE_LIMIT, CONN_RATE_LIMIT, EXPECT_PROXY}
+		}
+		for _, name := range names {
+			rules := c.cfg.TCPRequests[name]
+			for i := len(rules) - 1; i >= 0; i-- {
+				err = c.frontendTCPRequestRuleCreate(frontend, rules[i])
+				utils.LogErr(err)
+			}
+		}
 	}
 
 	if !c.cfg.SSLPassthrough {
@@ -114,7 +176,7 @@ func (c *HAProxyController) requestsTCPRefresh() (needsReload bool, err error) {
 	// SSL Frontend
 	c.frontendTCPRequestRuleDeleteAll(FrontendSSL)
 
-	// Fixed SSLpassthrough rules
+	// Fixed SSLpassthrough rules, evaluated after all others
 	err = c.frontendTCPRequestRuleCreate(FrontendSSL, models.TCPRequestRule{
 		ID:       utils.PtrInt64(0),
 		Action:   "accept",
@@ -132,19 +194,16 @@ func (c *HAProxyController) requestsTCPRefresh() (needsReload bool, err error) {
 	utils.LogErr(err)
 
 	sortedList := []string{}
-	exclude := map[string]struct{}{
-		RATE_LIMIT: struct{}{},
-	}
 	for name := range c.cfg.TCPRequests {
-		_, excluding := exclude[name]
-		if !excluding {
+		if name != RATE_LIMIT && name != CONN_RATE_LIMIT {
 			sortedList = append(sortedList, name)
 		}
 	}
-	sort.Sort(sort.Reverse(sort.StringSlice(sortedList))) // reverse order
-	for _, name := range sortedList {
-		for _, request := range c.cfg.TCPRequests[name] {
-			err = c.frontendTCPRequestRuleCreate(FrontendSSL, request)
+	sortRuleKeys(sortedList)
+	for i := len(sortedList) - 1; i >= 0; i-- {
+		rules := c.cfg.TCPRequests[sortedList[i]]
+		for j := len(rules) - 1; j >= 0; j-- {
+			err = c.frontendTCPRequestRuleCreate(FrontendSSL, rules[j])
 			utils.LogErr(err)
 		}
 	}
@@ -192,6 +251,10 @@ func (c *HAProxyController) handleHTTPRedirect(usingHTTPS bool) (reloadRequested
 		Cond:       "if",
 		CondTest:   "!{ ssl_fc }",
 	}
+	if c.osArgs.ACMESolverService.Name != "" {
+		// ACME HTTP-01 challenges are only sent over HTTP
+		rule.CondTest = "!{ ssl_fc } !{ path_beg " + acmeChallengePath + " }"
+	}
 	switch state {
 	case MODIFIED:
 		c.cfg.HTTPRequests[HTTP_REDIRECT] = []models.HTTPRequestRule{rule}
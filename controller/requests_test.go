@@ -0,0 +1,82 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+func TestSortRuleKeys(t *testing.T) {
+	keys := []string{"JWT-default-a", HTTP_REDIRECT, "WHT-/api", X_FORWARDED_PROTO, RATE_LIMIT, REQUEST_CAPTURE, "RLW-default-a", INGRESS_MATCH, JWT_CAPTURE, "custom"}
+	sortRuleKeys(keys)
+	expected := []string{
+		INGRESS_MATCH,
+		"RLW-default-a", JWT_CAPTURE, REQUEST_CAPTURE,
+		"WHT-/api", RATE_LIMIT,
+		HTTP_REDIRECT,
+		"custom", X_FORWARDED_PROTO,
+		"JWT-default-a",
+	}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected %v, got %v", expected, keys)
+	}
+}
+
+// TestRequestsHTTPRefreshOrder checks the order of http-request rules of features enabled together:
+// captures, then rate limiting, redirects, rewrites and authentication.
+func TestRequestsHTTPRefreshOrder(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	rule := func(name string) models.HTTPRequestRule {
+		return models.HTTPRequestRule{ID: utils.PtrInt64(0), Type: "set-var", VarScope: "txn", VarName: name, VarExpr: "bool(true)"}
+	}
+	c.cfg.HTTPRequests["JWT-default-a"] = []models.HTTPRequestRule{rule("auth")}
+	c.cfg.HTTPRequests[HTTP_REDIRECT] = []models.HTTPRequestRule{{
+		ID: utils.PtrInt64(0), Type: "redirect", RedirCode: 302, RedirValue: "https", RedirType: "scheme", Cond: "if", CondTest: "!{ ssl_fc }",
+	}}
+	c.cfg.HTTPRequests[RATE_LIMIT] = []models.HTTPRequestRule{rule("deny1"), rule("deny2")}
+	c.cfg.HTTPRequests[REQUEST_CAPTURE] = []models.HTTPRequestRule{rule("capture")}
+	c.cfg.HTTPRequestsStatus = MODIFIED
+	config := c.testSync(t, func() {
+		if _, err := c.RequestsHTTPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	order := func(frontend string) (names []string) {
+		for _, line := range testSection(config, frontend) {
+			switch {
+			case strings.HasPrefix(line, "http-request set-var(txn."):
+				names = append(names, strings.TrimSuffix(strings.SplitN(line[len("http-request set-var(txn."):], ")", 2)[0], ")"))
+			case strings.HasPrefix(line, "http-request redirect"):
+				names = append(names, "redirect")
+			case strings.HasPrefix(line, "http-request set-header X-Forwarded-Proto"):
+				names = append(names, "x-forwarded-proto")
+			}
+		}
+		return names
+	}
+	if names := order("frontend http"); !reflect.DeepEqual(names, []string{"capture", "deny1", "deny2", "redirect", "auth"}) {
+		t.Errorf("frontend http: unexpected order %v:\n%s", names, config)
+	}
+	// no redirect in HTTPS frontend
+	if names := order("frontend https"); !reflect.DeepEqual(names, []string{"capture", "deny1", "deny2", "x-forwarded-proto", "auth"}) {
+		t.Errorf("frontend https: unexpected order %v:\n%s", names, config)
+	}
+}
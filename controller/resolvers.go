@@ -0,0 +1,154 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+//ResolversName is the name of the resolvers section managed by the controller
+const ResolversName = "kubernetes"
+
+// handleResolvers manages the "resolvers" section used for DNS resolution
+// with configured nameservers and hold timers.
+func (c *HAProxyController) handleResolvers() (needsReload bool, err error) {
+	annNameservers, errNs := GetValueFromAnnotations("resolvers-nameservers", c.cfg.ConfigMap.Annotations)
+	holdAnnotations := map[string]*StringW{}
+	changed := errNs == nil && annNameservers.Status != EMPTY
+	for _, hold := range []string{"valid", "nx", "timeout"} {
+		ann, errAnn := GetValueFromAnnotations("resolvers-hold-"+hold, c.cfg.ConfigMap.Annotations)
+		if errAnn != nil {
+			continue
+		}
+		if ann.Status != EMPTY {
+			changed = true
+		}
+		if ann.Status == DELETED {
+			continue
+		}
+		if _, errTime := utils.ParseTime(ann.Value); errTime != nil {
+			utils.LogErr(fmt.Errorf("resolvers-hold-%s annotation: %s", hold, errTime))
+			continue
+		}
+		holdAnnotations[hold] = ann
+	}
+	if !changed {
+		return false, nil
+	}
+
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	sections, _ := config.SectionsGet(parser.Resolvers)
+	exists := false
+	for _, section := range sections {
+		if section == ResolversName {
+			exists = true
+			break
+		}
+	}
+
+	nameservers := []types.Nameserver{}
+	if errNs == nil && annNameservers.Status != DELETED {
+		nameservers = parseNameservers(annNameservers.Value)
+	}
+	if len(nameservers) == 0 {
+		if exists {
+			c.ActiveTransactionHasChanges = true
+			return true, config.SectionsDelete(parser.Resolvers, ResolversName)
+		}
+		return false, nil
+	}
+
+	c.ActiveTransactionHasChanges = true
+	if !exists {
+		if err = config.SectionsCreate(parser.Resolvers, ResolversName); err != nil {
+			return false, err
+		}
+	}
+	if err = config.Set(parser.Resolvers, ResolversName, "nameserver", nameservers); err != nil {
+		return false, err
+	}
+	var holdValid *types.StringC
+	if ann, ok := holdAnnotations["valid"]; ok {
+		holdValid = &types.StringC{Value: ann.Value}
+	}
+	if err = config.Set(parser.Resolvers, ResolversName, "hold valid", holdValid); err != nil {
+		return false, err
+	}
+	// hold nx and hold timeout are not known to config-parser
+	for _, hold := range []string{"nx", "timeout"} {
+		lines := []string{}
+		if ann, ok := holdAnnotations[hold]; ok {
+			lines = append(lines, fmt.Sprintf("hold %s %s", hold, ann.Value))
+		}
+		if _, err = c.sectionDirectivesSet(parser.Resolvers, ResolversName, "hold "+hold, lines); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// parseNameservers returns the nameservers of a comma or space separated list of <ip>[:<port>]
+func parseNameservers(value string) []types.Nameserver {
+	nameservers := []types.Nameserver{}
+	for index, address := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n' || r == ' '
+	}) {
+		if !strings.Contains(address, ":") {
+			address += ":53"
+		}
+		nameservers = append(nameservers, types.Nameserver{
+			Name:    fmt.Sprintf("dns%d", index+1),
+			Address: address,
+		})
+	}
+	return nameservers
+}
+
+// handleBackendResolvers makes servers of a backend use the resolvers section once nameservers
+// are configured, with "default-server resolvers kubernetes [resolve-prefer <family>]".
+// Only servers having a hostname are resolved, pod addresses are left untouched.
+func (c *HAProxyController) handleBackendResolvers(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	options := []params.ServerOption{}
+	annNameservers, _ := GetValueFromAnnotations("resolvers-nameservers", c.cfg.ConfigMap.Annotations)
+	annPrefer, _ := GetValueFromAnnotations("resolve-prefer", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	prefer := annPrefer != nil && annPrefer.Status != DELETED
+	if annNameservers != nil && annNameservers.Status != DELETED && len(parseNameservers(annNameservers.Value)) > 0 {
+		options = append(options, &params.ServerOptionValue{Name: "resolvers", Value: ResolversName})
+		if prefer && annPrefer.Value != "ipv4" && annPrefer.Value != "ipv6" {
+			err = fmt.Errorf("resolve-prefer annotation: incorrect value '%s', expected ipv4 or ipv6", annPrefer.Value)
+		} else if prefer {
+			options = append(options, &params.ServerOptionValue{Name: "resolve-prefer", Value: annPrefer.Value})
+		}
+	} else if prefer {
+		err = fmt.Errorf("resolve-prefer annotation: requires resolvers-nameservers")
+	}
+	needsReload, errSet := c.backendDefaultServerSet(backendName, []string{"resolvers", "resolve-prefer"}, options)
+	if errSet != nil {
+		return false, errSet
+	}
+	if needsReload {
+		c.ActiveTransactionHasChanges = true
+	}
+	return needsReload, err
+}
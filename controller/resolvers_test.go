@@ -0,0 +1,105 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleResolvers(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMap.Annotations = MapStringW{
+		"resolvers-nameservers":  &StringW{Value: "10.96.0.10, 10.96.0.11:5353", Status: ADDED},
+		"resolvers-hold-valid":   &StringW{Value: "10s", Status: ADDED},
+		"resolvers-hold-nx":      &StringW{Value: "30s", Status: ADDED},
+		"resolvers-hold-timeout": &StringW{Value: "1m", Status: ADDED},
+	}
+	config := c.testSync(t, func() {
+		reload, err := c.handleResolvers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reload {
+			t.Error("expected reload")
+		}
+	})
+	for _, expected := range []string{
+		"resolvers kubernetes",
+		"nameserver dns1 10.96.0.10:53",
+		"nameserver dns2 10.96.0.11:5353",
+		"hold valid 10s",
+		"hold nx 30s",
+		"hold timeout 1m",
+	} {
+		if !strings.Contains(config, expected) {
+			t.Errorf("expected '%s' in configuration:\n%s", expected, config)
+		}
+	}
+
+	c.cfg.ConfigMap.Annotations["resolvers-nameservers"].Status = DELETED
+	config = c.testSync(t, func() {
+		if _, err := c.handleResolvers(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if strings.Contains(config, "resolvers kubernetes") {
+		t.Errorf("expected resolvers section to be deleted:\n%s", config)
+	}
+}
+
+func TestHandleBackendResolvers(t *testing.T) {
+	tests := []struct {
+		name        string
+		nameservers string
+		prefer      string
+		expected    string
+		err         bool
+	}{
+		{"resolvers", "10.96.0.10", "", "default-server resolvers kubernetes", false},
+		{"resolve-prefer", "10.96.0.10", "ipv6", "default-server resolvers kubernetes resolve-prefer ipv6", false},
+		{"invalid resolve-prefer", "10.96.0.10", "ipv5", "default-server resolvers kubernetes", true},
+		{"resolve-prefer without nameservers", "", "ipv4", "", true},
+		{"no nameservers", "", "", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.cfg.ConfigMap.Annotations = testAnnotations(map[string]string{"resolvers-nameservers": test.nameservers})
+			service := &Service{Annotations: MapStringW{}}
+			if test.prefer != "" {
+				service.Annotations = testAnnotations(map[string]string{"resolve-prefer": test.prefer})
+			}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendResolvers(&Ingress{Annotations: MapStringW{}}, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.expected == "" {
+				if strings.Contains(config, "default-server") {
+					t.Errorf("expected no default-server line, got:\n%s", config)
+				}
+				return
+			}
+			if line := defaultServerLine(t, config, "default-app-80"); line != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, line)
+			}
+		})
+	}
+}
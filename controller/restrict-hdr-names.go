@@ -0,0 +1,47 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	parser "github.com/haproxytech/config-parser/v2"
+)
+
+// handleRestrictReqHdrNames sets "option http-restrict-req-hdr-names" of HTTP frontends from
+// --http-restrict-req-hdr-names: request headers with characters other than letters, digits
+// and hyphen are preserved, deleted or make the request rejected (request smuggling hardening).
+func (c *HAProxyController) handleRestrictReqHdrNames() (needsReload bool, err error) {
+	policy := c.osArgs.RestrictHdrNames
+	switch policy {
+	case "preserve", "delete", "reject":
+	default:
+		return false, fmt.Errorf("http-restrict-req-hdr-names: expected preserve, delete or reject, got '%s'", policy)
+	}
+	lines := []string{}
+	// preserve is HAProxy default
+	if policy != "preserve" && c.featureSupported("http-restrict-req-hdr-names") {
+		lines = append(lines, "option http-restrict-req-hdr-names "+policy)
+	}
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		reload, errSet := c.sectionDirectivesSet(parser.Frontends, frontend, "option http-restrict-req-hdr-names", lines)
+		if errSet != nil {
+			err = errSet
+			continue
+		}
+		needsReload = needsReload || reload
+	}
+	return needsReload, err
+}
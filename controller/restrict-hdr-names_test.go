@@ -0,0 +1,89 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleRestrictReqHdrNames(t *testing.T) {
+	tests := []struct {
+		policy   string
+		version  HAProxyVersion
+		expected string
+		err      bool
+	}{
+		{policy: "delete", version: HAProxyVersion{2, 6, 0}, expected: "option http-restrict-req-hdr-names delete"},
+		{policy: "reject", version: HAProxyVersion{2, 8, 1}, expected: "option http-restrict-req-hdr-names reject"},
+		{policy: "reject", expected: "option http-restrict-req-hdr-names reject"},
+		{policy: "preserve", version: HAProxyVersion{2, 6, 0}},
+		{policy: "delete", version: HAProxyVersion{2, 4, 0}},
+		{policy: "drop", version: HAProxyVersion{2, 6, 0}, err: true},
+		{policy: "", version: HAProxyVersion{2, 6, 0}, err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.policy+"/"+test.version.String(), func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			c.haproxyVersion = test.version
+			c.osArgs.RestrictHdrNames = test.policy
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleRestrictReqHdrNames()
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			for _, frontend := range []string{"frontend http", "frontend https"} {
+				if test.expected == "" {
+					if strings.Contains(strings.Join(testSection(config, frontend), "\n"), "http-restrict-req-hdr-names") {
+						t.Errorf("%s: expected no http-restrict-req-hdr-names:\n%s", frontend, config)
+					}
+				} else if !testSectionHas(config, frontend, test.expected) {
+					t.Errorf("%s: expected '%s':\n%s", frontend, test.expected, config)
+				}
+			}
+			if strings.Contains(strings.Join(testSection(config, "frontend stats"), "\n"), "restrict") {
+				t.Errorf("expected no policy on stats frontend:\n%s", config)
+			}
+		})
+	}
+}
+
+func TestHandleRestrictReqHdrNamesUpdate(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.RestrictHdrNames = "reject"
+	c.testSync(t, func() {
+		if reload, err := c.handleRestrictReqHdrNames(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.testSync(t, func() {
+		if reload, err := c.handleRestrictReqHdrNames(); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+	c.osArgs.RestrictHdrNames = "preserve"
+	config := c.testSync(t, func() {
+		if reload, err := c.handleRestrictReqHdrNames(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "http-restrict-req-hdr-names") {
+		t.Errorf("expected option to be removed:\n%s", config)
+	}
+}
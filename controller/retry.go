@@ -0,0 +1,109 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// retryOnKeywords are the retry-on conditions accepted by HAProxy besides status codes
+var retryOnKeywords = map[string]struct{}{
+	"none":                 struct{}{},
+	"conn-failure":         struct{}{},
+	"empty-response":       struct{}{},
+	"junk-response":        struct{}{},
+	"response-timeout":     struct{}{},
+	"0rtt-rejected":        struct{}{},
+	"all-retryable-errors": struct{}{},
+}
+
+// retryOnStatusCodes are the response status codes accepted by retry-on
+var retryOnStatusCodes = map[int]struct{}{
+	404: struct{}{}, 408: struct{}{}, 425: struct{}{}, 500: struct{}{},
+	501: struct{}{}, 502: struct{}{}, 503: struct{}{}, 504: struct{}{},
+}
+
+// idempotentMethods are the only methods retried on layer 7 conditions
+// unless "retry-non-idempotent" annotation is enabled
+const idempotentMethods = "GET HEAD OPTIONS TRACE PUT DELETE"
+
+// handleBackendRetryOn configures "retry-on" conditions in the backend of a service.
+// When a condition requires HAProxy to look at the response (status codes, ...),
+// layer 7 retries are disabled for non idempotent methods.
+func (c *HAProxyController) handleBackendRetryOn(ingress *Ingress, service *Service, backendName string, newBackend bool) (needsReload bool, err error) {
+	annRetryOn, errAnn := GetValueFromAnnotations("retry-on", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if errAnn != nil {
+		return false, nil
+	}
+	annNonIdempotent, _ := GetValueFromAnnotations("retry-non-idempotent", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annRetryOn.Status == EMPTY && !newBackend && (annNonIdempotent == nil || annNonIdempotent.Status == EMPTY) {
+		return false, nil
+	}
+	retryOn := []string{}
+	disableL7Retry := []string{}
+	if annRetryOn.Status != DELETED {
+		conditions, layer7, errParse := parseRetryOn(annRetryOn.Value)
+		if errParse != nil {
+			return false, fmt.Errorf("retry-on annotation: %s", errParse)
+		}
+		retryOn = append(retryOn, "retry-on "+strings.Join(conditions, " "))
+		nonIdempotent := false
+		if annNonIdempotent != nil && annNonIdempotent.Status != DELETED {
+			if nonIdempotent, err = utils.GetBoolValue(annNonIdempotent.Value, "retry-non-idempotent"); err != nil {
+				return false, err
+			}
+		}
+		if layer7 && !nonIdempotent {
+			disableL7Retry = append(disableL7Retry, fmt.Sprintf("http-request disable-l7-retry if !{ method %s }", idempotentMethods))
+		}
+	}
+	needsReload, err = c.sectionDirectivesSet(parser.Backends, backendName, "retry-on", retryOn)
+	if err != nil {
+		return needsReload, err
+	}
+	reload, err := c.sectionDirectivesSet(parser.Backends, backendName, "http-request disable-l7-retry", disableL7Retry)
+	return needsReload || reload, err
+}
+
+// parseRetryOn validates retry-on conditions, layer7 is true
+// if any of them is not a connection level condition
+func parseRetryOn(value string) (conditions []string, layer7 bool, err error) {
+	for _, condition := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' '
+	}) {
+		if code, errCode := strconv.Atoi(condition); errCode == nil {
+			if _, ok := retryOnStatusCodes[code]; !ok {
+				return nil, false, fmt.Errorf("unsupported status code '%s'", condition)
+			}
+			layer7 = true
+		} else if _, ok := retryOnKeywords[condition]; ok {
+			if condition != "conn-failure" && condition != "none" && condition != "0rtt-rejected" {
+				layer7 = true
+			}
+		} else {
+			return nil, false, fmt.Errorf("unknown condition '%s'", condition)
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) == 0 {
+		return nil, false, fmt.Errorf("empty value")
+	}
+	return conditions, layer7, nil
+}
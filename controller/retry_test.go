@@ -0,0 +1,108 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendRetryOn(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{
+			name:        "503 is retried for idempotent methods only",
+			annotations: map[string]string{"retry-on": "503"},
+			expected: []string{
+				"retry-on 503",
+				"http-request disable-l7-retry if !{ method GET HEAD OPTIONS TRACE PUT DELETE }",
+			},
+		},
+		{
+			name:        "non idempotent methods allowed",
+			annotations: map[string]string{"retry-on": "conn-failure,503", "retry-non-idempotent": "true"},
+			expected:    []string{"retry-on conn-failure 503"},
+		},
+		{
+			name:        "connection level conditions",
+			annotations: map[string]string{"retry-on": "conn-failure 0rtt-rejected"},
+			expected:    []string{"retry-on conn-failure 0rtt-rejected"},
+		},
+		{
+			name:        "unsupported status code",
+			annotations: map[string]string{"retry-on": "503,429"},
+			err:         true,
+		},
+		{
+			name:        "unknown condition",
+			annotations: map[string]string{"retry-on": "timeout"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendRetryOn(ingress, service, "default-app-80", true)
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "retry-on") || strings.HasPrefix(line, "http-request disable-l7-retry") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestHandleBackendRetryOnRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"retry-on": "503"})}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendRetryOn(ingress, service, "default-app-80", true); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendRetryOn(ingress, service, "default-app-80", false); reload {
+			t.Error("expected no reload")
+		}
+	})
+	service.Annotations["retry-on"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleBackendRetryOn(ingress, service, "default-app-80", false); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "retry-on") || strings.Contains(config, "disable-l7-retry") {
+		t.Errorf("expected no retry directives:\n%s", config)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// routeQuery is sent to SyncData by the "/route" endpoint, so that rules
+// are not read while being updated, the result is sent back on reply
+type routeQuery struct {
+	Frontend string
+	Host     string
+	Path     string
+	// err is set before the reply when the query can not be answered
+	err   error
+	reply chan RouteQueryResult
+}
+
+// RouteQueryResult is the backend selected by use_backend rules of a frontend for a host and path
+type RouteQueryResult struct {
+	Frontend      string `json:"frontend"`
+	Host          string `json:"host"`
+	Path          string `json:"path"`
+	Backend       string `json:"backend"`
+	Namespace     string `json:"namespace,omitempty"`
+	Rule          string `json:"rule,omitempty"`
+	CanaryBackend string `json:"canaryBackend,omitempty"`
+	CanaryWeight  int64  `json:"canaryWeight,omitempty"`
+	DefaultRoute  bool   `json:"defaultBackend"`
+}
+
+// handleRouteQuery answers "GET /route?host=<host>&path=<path>[&frontend=<frontend>]" with the backend
+// which would serve the request. Request must have "Authorization: Bearer <token>", see --route-query-token.
+func (c *HAProxyController) handleRouteQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !authorized(r, c.osArgs.RouteQueryToken) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	query := &routeQuery{
+		Frontend: r.URL.Query().Get("frontend"),
+		Host:     r.URL.Query().Get("host"),
+		Path:     r.URL.Query().Get("path"),
+		reply:    make(chan RouteQueryResult, 1),
+	}
+	if query.Frontend == "" {
+		query.Frontend = FrontendHTTP
+	}
+	if query.Path == "" {
+		query.Path = "/"
+	}
+	c.eventChan <- SyncDataEvent{SyncType: ROUTE_QUERY, Data: query}
+	result := <-query.reply
+	if query.err != nil {
+		http.Error(w, query.err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// routeQueryAnswer simulates use_backend rules of the frontend as created by refreshBackendSwitching:
+// rules are evaluated in the reverse order of sorted keys and first match wins.
+// Any frontend of the configuration can be queried, e.g. the ones listed by "frontends" annotation,
+// rules of TCP frontends match the SNI only.
+func (c *HAProxyController) routeQueryAnswer(query *routeQuery) {
+	result := RouteQueryResult{
+		Frontend: query.Frontend,
+		Host:     query.Host,
+		Path:     query.Path,
+	}
+	defer func() { query.reply <- result }()
+	frontend, err := c.frontendGet(query.Frontend)
+	if err != nil {
+		query.err = fmt.Errorf("unknown frontend '%s'", query.Frontend)
+		return
+	}
+	tcp := frontend.Mode == "tcp"
+	rules := c.cfg.BackendSwitchingRules[query.Frontend]
+	sortedKeys := sortedUseBackendKeys(rules)
+	maxRules, _ := c.maxRulesPerFrontend()
+	created, _ := c.createdUseBackendKeys(query.Frontend, frontend.Mode, rules, sortedKeys, maxRules)
+	// with "path-match: decoded", like url_dec converter, no path matches an invalid encoding
+	path, pathValid := query.Path, true
+	if decoded, _ := c.pathMatchDecoded(); decoded {
+		unescaped, err := url.PathUnescape(query.Path)
+		path, pathValid = unescaped, err == nil
+	}
+	// like req.hdr(host) matching, a port in host is part of the compared value
+	for i := len(created) - 1; i >= 0; i-- {
+		rule := rules[created[i]]
+		if tcp {
+			if !strings.EqualFold(rule.Host, query.Host) {
+				continue
+			}
+		} else {
+			if rule.Host != "" && !strings.EqualFold(rule.Host, query.Host) {
+				continue
+			}
+			if rule.Path != "" && (!pathValid || !strings.HasPrefix(path, rule.Path)) {
+				continue
+			}
+		}
+		result.Backend = rule.Backend
+		result.Namespace = rule.Namespace
+		result.Rule = created[i]
+		if !tcp {
+			result.CanaryBackend = rule.CanaryBackend
+			result.CanaryWeight = rule.CanaryWeight
+		}
+		return
+	}
+	result.DefaultRoute = true
+	if tcp || c.cfg.DefaultBackendDisabled {
+		return
+	}
+	// frontends not managed by default-backend-service keep their own default_backend
+	if backend, ok := c.cfg.DefaultBackends[query.Frontend]; ok {
+		result.Backend = backend
+	} else {
+		result.Backend = frontend.DefaultBackend
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+// testFrontendCreate adds a frontend to the configuration, e.g. ssl frontend of SSL passthrough
+func (c *HAProxyController) testFrontendCreate(t *testing.T, name, mode string) {
+	c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: name, Mode: mode}); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// testRouteQuery sends the request to handleRouteQuery and answers it like SyncData
+func (c *HAProxyController) testRouteQuery(t *testing.T, target, token string) (status int, result RouteQueryResult) {
+	c.eventChan = make(chan SyncDataEvent, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for job := range c.eventChan {
+			if job.SyncType == ROUTE_QUERY {
+				c.routeQueryAnswer(job.Data.(*routeQuery))
+			}
+		}
+	}()
+	request := httptest.NewRequest("GET", target, nil)
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	recorder := httptest.NewRecorder()
+	c.handleRouteQuery(recorder, request)
+	close(c.eventChan)
+	<-done
+	if recorder.Code == http.StatusOK {
+		if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return recorder.Code, result
+}
+
+func TestRouteQuery(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.RouteQueryToken = "secret"
+	for _, rule := range []UseBackendRule{
+		{Host: "example.com", Path: "/", Backend: "default-root-80", Namespace: "default", Ingress: "web"},
+		{Host: "example.com", Path: "/a", Backend: "default-a-80", Namespace: "default", Ingress: "web"},
+		{Host: "example.com", Path: "/api", Backend: "default-api-80", Namespace: "default", Ingress: "web"},
+		{Host: "other.com", Path: "", Backend: "other-app-80", Namespace: "other", Ingress: "app"},
+		{Host: "", Path: "/static", Backend: "default-static-80", Namespace: "default", Ingress: "static"},
+	} {
+		key := "R" + rule.Namespace + rule.Ingress + rule.Host + rule.Path
+		c.addUseBackendRule(key, rule, FrontendHTTP, FrontendHTTPS)
+	}
+	c.addUseBackendRule("Rdefaultsslsecure.com", UseBackendRule{Host: "secure.com", Backend: "default-secure-443", Namespace: "default", Ingress: "ssl"}, FrontendSSL)
+	c.testFrontendCreate(t, FrontendSSL, "tcp")
+	tests := []struct {
+		target  string
+		backend string
+		rule    string
+	}{
+		{target: "/route?host=example.com&path=/api/v1", backend: "default-api-80", rule: "Rdefaultwebexample.com/api"},
+		{target: "/route?host=example.com&path=/apple", backend: "default-a-80", rule: "Rdefaultwebexample.com/a"},
+		{target: "/route?host=example.com&path=/b", backend: "default-root-80", rule: "Rdefaultwebexample.com/"},
+		{target: "/route?host=EXAMPLE.com", backend: "default-root-80", rule: "Rdefaultwebexample.com/"},
+		{target: "/route?host=other.com&path=/api", backend: "other-app-80", rule: "Rotherappother.com"},
+		{target: "/route?host=unknown.com&path=/static/app.js", backend: "default-static-80", rule: "Rdefaultstatic/static"},
+		{target: "/route?host=example.com:8080&path=/api"},
+		{target: "/route?host=secure.com&frontend=ssl", backend: "default-secure-443", rule: "Rdefaultsslsecure.com"},
+		{target: "/route?host=example.com&path=/api&frontend=https", backend: "default-api-80", rule: "Rdefaultwebexample.com/api"},
+	}
+	for _, test := range tests {
+		t.Run(test.target, func(t *testing.T) {
+			status, result := c.testRouteQuery(t, test.target, "secret")
+			if status != http.StatusOK {
+				t.Fatalf("expected status 200, got %d", status)
+			}
+			if test.rule == "" {
+				if !result.DefaultRoute || result.Backend != c.cfg.DefaultBackends[result.Frontend] {
+					t.Errorf("expected default backend, got %+v", result)
+				}
+				return
+			}
+			if result.Backend != test.backend || result.Rule != test.rule || result.DefaultRoute {
+				t.Errorf("expected backend %s from rule %s, got %+v", test.backend, test.rule, result)
+			}
+		})
+	}
+}
+
+func TestRouteQueryRequest(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.RouteQueryToken = "secret"
+	if status, _ := c.testRouteQuery(t, "/route?host=example.com", "other"); status != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", status)
+	}
+	c.osArgs.ForceReloadToken = "reload"
+	if status, _ := c.testRouteQuery(t, "/route?host=example.com", "reload"); status != http.StatusUnauthorized {
+		t.Errorf("expected status 401 with force-reload token, got %d", status)
+	}
+	if status, _ := c.testRouteQuery(t, "/route?host=example.com&frontend=missing", "secret"); status != http.StatusBadRequest {
+		t.Errorf("expected status 400, got %d", status)
+	}
+	status, result := c.testRouteQuery(t, "/route?host=example.com", "secret")
+	if status != http.StatusOK || result.Frontend != FrontendHTTP || result.Path != "/" || !result.DefaultRoute {
+		t.Errorf("expected default route of http frontend, got %d %+v", status, result)
+	}
+}
+
+func TestRouteQueryMaxRules(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.RouteQueryToken = "secret"
+	c.cfg.ConfigMap.Annotations = MapStringW{"max-rules-per-frontend": &StringW{Value: "2", Status: ADDED}}
+	c.addUseBackendRule("Rdefaultstatic/static", UseBackendRule{Path: "/static", Backend: "default-static-80", Namespace: "default", Ingress: "static"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaultwebexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-root-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+	c.addUseBackendRule("Rdefaultwebexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-api-80", Namespace: "default", Ingress: "web"}, FrontendHTTP)
+	c.testFrontendCreate(t, FrontendSSL, "tcp")
+	// rules without SNI are not created in TCP mode and do not count
+	c.addUseBackendRule("Rdefaultssl", UseBackendRule{Backend: "default-any-443", Namespace: "default", Ingress: "ssl"}, FrontendSSL)
+	c.addUseBackendRule("Rdefaultsslsecure.com", UseBackendRule{Host: "secure.com", Backend: "default-secure-443", Namespace: "default", Ingress: "ssl"}, FrontendSSL)
+	c.addUseBackendRule("Rdefaultsslsecure.org", UseBackendRule{Host: "secure.org", Backend: "default-org-443", Namespace: "default", Ingress: "ssl"}, FrontendSSL)
+	tests := []struct {
+		target  string
+		backend string
+	}{
+		{target: "/route?host=example.com&path=/api", backend: "default-api-80"},
+		{target: "/route?host=example.com&path=/static", backend: "default-root-80"},
+		{target: "/route?host=secure.com&frontend=ssl", backend: "default-secure-443"},
+		{target: "/route?host=secure.org&frontend=ssl", backend: "default-org-443"},
+	}
+	for _, test := range tests {
+		if _, result := c.testRouteQuery(t, test.target, "secret"); result.Backend != test.backend {
+			t.Errorf("%s: expected backend %s, got %+v", test.target, test.backend, result)
+		}
+	}
+	// the hostless rule is skipped, it would be evaluated last
+	if _, result := c.testRouteQuery(t, "/route?host=other.com&path=/static", "secret"); !result.DefaultRoute {
+		t.Errorf("expected default route, got %+v", result)
+	}
+}
+
+func TestRouteQueryFrontendsAnnotation(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.RouteQueryToken = "secret"
+	c.testFrontendCreate(t, "internal", "http")
+	c.testSync(t, func() {
+		frontend, err := c.frontendGet("internal")
+		if err != nil {
+			t.Fatal(err)
+		}
+		frontend.DefaultBackend = "default_backend"
+		if err = c.frontendEdit(frontend); err != nil {
+			t.Fatal(err)
+		}
+	})
+	c.addUseBackendRule("Rdefaultwebexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-api-80", Namespace: "default", Ingress: "web"}, "internal")
+	_, result := c.testRouteQuery(t, "/route?host=example.com&path=/api&frontend=internal", "secret")
+	if result.Frontend != "internal" || result.Backend != "default-api-80" || result.DefaultRoute {
+		t.Errorf("expected rule of internal frontend, got %+v", result)
+	}
+	// the rule is only in internal frontend
+	_, result = c.testRouteQuery(t, "/route?host=example.com&path=/api", "secret")
+	if !result.DefaultRoute || result.Backend != c.cfg.DefaultBackends[FrontendHTTP] {
+		t.Errorf("expected default route of http frontend, got %+v", result)
+	}
+	_, result = c.testRouteQuery(t, "/route?host=example.com&path=/&frontend=internal", "secret")
+	if !result.DefaultRoute || result.Backend != "default_backend" {
+		t.Errorf("expected default_backend of internal frontend, got %+v", result)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+// testRuleFrontends returns the frontends with a use_backend rule to backend
+func testRuleFrontends(config, backend string) (frontends []string) {
+	for _, frontend := range []string{"http", "https", "internal"} {
+		for _, line := range testSection(config, "frontend "+frontend) {
+			if strings.HasPrefix(line, "use_backend "+backend+" ") {
+				frontends = append(frontends, frontend)
+				break
+			}
+		}
+	}
+	sort.Strings(frontends)
+	return frontends
+}
+
+func TestRuleFrontends(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testServiceEndpoints("default", "app")
+	ingress := c.testIngress("default", "web")
+	ingress.Annotations = MapStringW{"frontends": &StringW{Value: "internal, https", Status: ADDED}}
+	rule, path := testRule(ingress, "example.com", "/", "app")
+	namespace := c.cfg.GetNamespace("default")
+	update := func() string {
+		return c.testSync(t, func() {
+			if _, err := c.handlePath(namespace, ingress, rule, path); err != nil {
+				t.Fatal(err)
+			}
+			c.refreshBackendSwitching()
+		})
+	}
+	c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: "internal", Mode: "http"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if got := testRuleFrontends(update(), "default-app-80"); strings.Join(got, ",") != "https,internal" {
+		t.Errorf("expected rule in https and internal frontends, got %v", got)
+	}
+
+	// rule is moved to the listed frontends
+	ingress.Annotations["frontends"] = &StringW{Value: "http", Status: MODIFIED}
+	path.Status = EMPTY
+	if got := testRuleFrontends(update(), "default-app-80"); strings.Join(got, ",") != "http" {
+		t.Errorf("expected rule in http frontend only, got %v", got)
+	}
+
+	// HTTP and HTTPS frontends without the annotation
+	ingress.Annotations["frontends"].Status = DELETED
+	if got := testRuleFrontends(update(), "default-app-80"); strings.Join(got, ",") != "http,https" {
+		t.Errorf("expected rule in http and https frontends, got %v", got)
+	}
+}
+
+func TestRuleFrontendsInvalid(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: "internal-tcp", Mode: "tcp"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	c.apiStartTransaction()
+	defer c.apiDisposeTransaction()
+	for _, value := range []string{"missing", "internal-tcp", "stats, internal-tcp", " , "} {
+		ingress := &Ingress{Annotations: MapStringW{"frontends": &StringW{Value: value, Status: ADDED}}}
+		frontends, err := c.ruleFrontends(ingress)
+		if err == nil {
+			t.Errorf("'%s': expected error", value)
+		}
+		if strings.Join(frontends, ",") != FrontendHTTP+","+FrontendHTTPS {
+			t.Errorf("'%s': expected default frontends, got %v", value, frontends)
+		}
+	}
+	frontends, err := c.ruleFrontends(&Ingress{Annotations: MapStringW{"frontends": &StringW{Value: "stats,stats", Status: ADDED}}})
+	if err != nil || strings.Join(frontends, ",") != "stats" {
+		t.Errorf("expected stats frontend once, got %v %v", frontends, err)
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/config-parser/v2/types"
+)
+
+// haproxyRuntimeSocket is the stats socket of HAProxy, set in global section
+const haproxyRuntimeSocket = "/var/run/haproxy-runtime-api.sock"
+
+// handleSeamlessReload sets "expose-fd listeners" on the runtime socket so that the new
+// process takes over listening sockets on reload and no connection is refused meanwhile.
+// It requires HAProxy 1.8, older versions are reloaded without it.
+func (c *HAProxyController) handleSeamlessReload() (needsReload bool, err error) {
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	data, err := config.Get(parser.Global, parser.GlobalSectionName, "stats socket")
+	if err != nil {
+		return false, nil
+	}
+	enabled := c.featureSupported("seamless-reload")
+	sockets := data.([]types.Socket)
+	changed := false
+	for i, socket := range sockets {
+		if socket.Path != haproxyRuntimeSocket {
+			continue
+		}
+		options := []params.BindOption{}
+		exposed := false
+		for _, option := range socket.Params {
+			if option.String() == "expose-fd listeners" {
+				exposed = true
+				if !enabled {
+					continue
+				}
+			}
+			options = append(options, option)
+		}
+		if exposed == enabled {
+			continue
+		}
+		if enabled {
+			options = append(options, &params.BindOptionDoubleWord{Name: "expose-fd", Value: "listeners"})
+		}
+		sockets[i].Params = options
+		changed = true
+	}
+	if !changed {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	return true, config.Set(parser.Global, parser.GlobalSectionName, "stats socket", sockets)
+}
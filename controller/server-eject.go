@@ -0,0 +1,168 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/params"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// eject5xxRateBackend holds the stick-table counting 5xx responses of each
+// backend having "eject-5xx-rate" annotation, over eject5xxRatePeriod
+const (
+	eject5xxRateBackend = "Eject5xxRate"
+	eject5xxRatePeriod  = "10s"
+)
+
+// handleBackendEject marks down a server of a backend after "eject-5xx"
+// consecutive errors: 5xx responses (except 501 and 505) or connection errors, with the
+// "observe layer7" passive health check of default-server. This is not a rate of errors,
+// a single successful response resets the count of the server.
+// Ejected servers come back once health checks succeed again, hence "check" is required.
+func (c *HAProxyController) handleBackendEject(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	options := []params.ServerOption{}
+	annEject, _ := GetValueFromAnnotations("eject-5xx", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annEject != nil && annEject.Status != DELETED {
+		limit, errParse := strconv.ParseInt(annEject.Value, 10, 64)
+		annCheck, _ := GetValueFromAnnotations("check", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		check, errCheck := utils.GetBoolValue(annCheck.Value, "check")
+		switch {
+		case errParse != nil || limit < 1:
+			err = fmt.Errorf("eject-5xx annotation: incorrect value '%s'", annEject.Value)
+		case errCheck != nil || !check:
+			err = fmt.Errorf("eject-5xx annotation: servers of backend %s can not recover without health checks", backendName)
+		default:
+			options = append(options,
+				&params.ServerOptionValue{Name: "observe", Value: "layer7"},
+				&params.ServerOptionValue{Name: "error-limit", Value: annEject.Value},
+				&params.ServerOptionValue{Name: "on-error", Value: "mark-down"},
+			)
+		}
+	}
+	needsReload, errSet := c.backendDefaultServerSet(backendName, []string{"observe", "error-limit", "on-error"}, options)
+	if errSet != nil {
+		return false, errSet
+	}
+	if needsReload {
+		c.ActiveTransactionHasChanges = true
+	}
+	return needsReload, err
+}
+
+// handleBackendEject5xxRate counts 5xx responses (except 501 and 505) of a backend in
+// eject5xxRateBackend stick-table when "eject-5xx-rate" is set. Use_backend rules of the
+// service are skipped while it answered more 5xx responses than the annotation value in
+// the last 10 seconds (see useBackendCond): requests go to the next matching rule, or to the
+// default backend, until the rate decays below the threshold.
+// Unlike "eject-5xx", the rate is counted for the whole backend and does not need health checks.
+func (c *HAProxyController) handleBackendEject5xxRate(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	limit, err := c.eject5xxRate(ingress, service)
+	if err != nil {
+		return false, err
+	}
+	backend, err := c.backendGet(backendName)
+	if err != nil {
+		return false, err
+	}
+	if backend.Mode != "http" {
+		if limit > 0 {
+			err = fmt.Errorf("eject-5xx-rate annotation: backend %s is not in http mode", backendName)
+		}
+		return false, err
+	}
+	counter, errCounter := c.eject5xxCounter()
+	if errCounter != nil {
+		if limit > 0 {
+			err = errCounter
+		}
+		return false, err
+	}
+	requests, responses := []string{}, []string{}
+	if limit > 0 {
+		if _, errGet := c.backendGet(eject5xxRateBackend); errGet != nil {
+			err = c.backendCreate(models.Backend{
+				Name: eject5xxRateBackend,
+				StickTable: &models.BackendStickTable{
+					Type:   "string",
+					Size:   utils.PtrInt64(10000),
+					Expire: utils.PtrInt64(60000),
+					Store:  fmt.Sprintf("gpc0_rate(%s)", eject5xxRatePeriod),
+				},
+			})
+			if err != nil {
+				return false, err
+			}
+			needsReload = true
+		}
+		requests = append(requests, fmt.Sprintf("http-request track-sc%d str(%s) table %s", counter, backendName, eject5xxRateBackend))
+		responses = append(responses, fmt.Sprintf("http-response sc-inc-gpc0(%d) if { status ge 500 } !{ status 501 505 }", counter))
+	}
+	reload, err := c.sectionDirectivesSet(parser.Backends, backendName, fmt.Sprintf("http-request track-sc%d", counter), requests)
+	if err != nil {
+		return false, err
+	}
+	needsReload = needsReload || reload
+	reload, err = c.sectionDirectivesSet(parser.Backends, backendName, fmt.Sprintf("http-response sc-inc-gpc0(%d)", counter), responses)
+	return needsReload || reload, err
+}
+
+// eject5xxRate returns the maximum number of 5xx responses of the service in
+// eject5xxRatePeriod given by "eject-5xx-rate" annotation, 0 if not set.
+func (c *HAProxyController) eject5xxRate(ingress *Ingress, service *Service) (int64, error) {
+	annRate, _ := GetValueFromAnnotations("eject-5xx-rate", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annRate == nil || annRate.Status == DELETED {
+		return 0, nil
+	}
+	limit, err := strconv.ParseInt(annRate.Value, 10, 64)
+	if err != nil || limit < 1 {
+		return 0, fmt.Errorf("eject-5xx-rate annotation: incorrect value '%s'", annRate.Value)
+	}
+	return limit, nil
+}
+
+// eject5xxCounter returns the sticky counter tracking backends in eject5xxRateBackend.
+// A counter already tracked by "tcp-request connection" rules of frontends would be
+// ignored by backends, sc0 is used by rate-limit, sc1 by --frontend-conn-rate-limit
+// and sc2 by --tls-handshake-rate-limit.
+func (c *HAProxyController) eject5xxCounter() (int, error) {
+	switch {
+	case c.osArgs.TLSHandshakeRateLimit <= 0:
+		return 2, nil
+	case c.osArgs.FrontendConnRateLimit <= 0:
+		return 1, nil
+	}
+	return 0, fmt.Errorf("eject-5xx-rate annotation: no sticky counter left with both --frontend-conn-rate-limit and --tls-handshake-rate-limit")
+}
+
+// ejectFallback returns true if use_backend rules of the service are skipped while
+// all its servers are down ("eject-fallback" annotation), requests then go to the
+// next matching rule, or to the default backend.
+func (c *HAProxyController) ejectFallback(ingress *Ingress, service *Service) bool {
+	annFallback, _ := GetValueFromAnnotations("eject-fallback", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annFallback == nil || annFallback.Status == DELETED {
+		return false
+	}
+	enabled, err := utils.GetBoolValue(annFallback.Value, "eject-fallback")
+	if err != nil {
+		utils.LogErr(fmt.Errorf("eject-fallback annotation: %s", err))
+		return false
+	}
+	return enabled
+}
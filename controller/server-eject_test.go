@@ -0,0 +1,193 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendEject(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    string
+		err         bool
+	}{
+		{
+			name:        "consecutive errors",
+			annotations: map[string]string{"eject-5xx": "5", "check": "true"},
+			expected:    "default-server observe layer7 error-limit 5 on-error mark-down",
+		},
+		{
+			name:        "invalid value",
+			annotations: map[string]string{"eject-5xx": "five", "check": "true"},
+			err:         true,
+		},
+		{
+			name:        "zero",
+			annotations: map[string]string{"eject-5xx": "0", "check": "true"},
+			err:         true,
+		},
+		{
+			name:        "without health checks",
+			annotations: map[string]string{"eject-5xx": "5", "check": "false"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendEject(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.err {
+				if strings.Contains(config, "default-server") {
+					t.Errorf("expected no default-server line, got:\n%s", config)
+				}
+				return
+			}
+			if line := defaultServerLine(t, config, "default-app-80"); line != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, line)
+			}
+		})
+	}
+}
+
+func TestEjectFallback(t *testing.T) {
+	tests := []struct {
+		value    string
+		status   Status
+		expected bool
+	}{
+		{"true", EMPTY, true},
+		{"false", EMPTY, false},
+		{"true", DELETED, false},
+		{"maybe", EMPTY, false},
+	}
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	for _, test := range tests {
+		ingress := &Ingress{Annotations: MapStringW{}}
+		service := &Service{Annotations: MapStringW{"eject-fallback": &StringW{Value: test.value, Status: test.status}}}
+		if result := c.ejectFallback(ingress, service); result != test.expected {
+			t.Errorf("eject-fallback '%s' (%s): expected %t, got %t", test.value, test.status, test.expected, result)
+		}
+	}
+}
+
+func TestHandleBackendEject5xxRate(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testServiceEndpoints("default", "app")
+	c.testServiceEndpoints("default", "fallback")
+	service := c.cfg.GetNamespace("default").Services["app"]
+	service.Annotations["eject-5xx-rate"] = &StringW{Value: "20", Status: ADDED}
+	ingress := c.testIngress("default", "web")
+	apiRule, apiPath := testRule(ingress, "example.com", "/api", "app")
+	rootRule, rootPath := testRule(ingress, "example.com", "/", "fallback")
+	namespace := c.cfg.GetNamespace("default")
+	update := func() string {
+		return c.testSync(t, func() {
+			for _, path := range []struct {
+				rule *IngressRule
+				path *IngressPath
+			}{{rootRule, rootPath}, {apiRule, apiPath}} {
+				if _, err := c.handlePath(namespace, ingress, path.rule, path.path); err != nil {
+					t.Fatal(err)
+				}
+			}
+			c.refreshBackendSwitching()
+		})
+	}
+	config := update()
+	if !testSectionHas(config, "backend "+eject5xxRateBackend, "stick-table type string size 10000 expire 60000 store gpc0_rate(10s)") {
+		t.Errorf("expected stick-table in %s backend, got:\n%s", eject5xxRateBackend, config)
+	}
+	for _, line := range []string{
+		"http-request track-sc2 str(default-app-80) table " + eject5xxRateBackend,
+		"http-response sc-inc-gpc0(2) if { status ge 500 } !{ status 501 505 }",
+	} {
+		if !testSectionHas(config, "backend default-app-80", line) {
+			t.Errorf("expected '%s' in backend, got:\n%s", line, config)
+		}
+	}
+	// above the rate, /api requests fall through to the rule of / until the rate decays
+	cond := "{ str(default-app-80),table_gpc0_rate(" + eject5xxRateBackend + ") le 20 }"
+	ruleLines := func(config, backend string) (lines []string) {
+		for _, line := range testSection(config, "frontend http") {
+			if strings.HasPrefix(line, "use_backend "+backend+" ") {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	lines := ruleLines(config, "default-app-80")
+	if len(lines) == 0 {
+		t.Fatalf("expected use_backend rule of default-app-80, got:\n%s", config)
+	}
+	for _, line := range lines {
+		if !strings.Contains(line, cond) {
+			t.Errorf("expected '%s' in '%s'", cond, line)
+		}
+	}
+	if lines := ruleLines(config, "default-fallback-80"); len(lines) != 1 || strings.Contains(lines[0], "table_gpc0_rate") {
+		t.Errorf("expected fallback rule without rate condition, got %v", lines)
+	}
+
+	service.Annotations["eject-5xx-rate"].Status = DELETED
+	apiPath.Status = MODIFIED
+	config = update()
+	for _, line := range testSection(config, "backend default-app-80") {
+		if strings.Contains(line, "track-sc") || strings.Contains(line, "sc-inc-gpc0") {
+			t.Errorf("expected no tracking once the annotation is removed, got '%s'", line)
+		}
+	}
+	for _, line := range ruleLines(config, "default-app-80") {
+		if strings.Contains(line, "table_gpc0_rate") {
+			t.Errorf("expected no rate condition once the annotation is removed, got '%s'", line)
+		}
+	}
+}
+
+func TestEject5xxCounter(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	tests := []struct {
+		connRate, tlsRate int64
+		expected          int
+		err               bool
+	}{
+		{0, 0, 2, false},
+		{100, 0, 2, false},
+		{0, 100, 1, false},
+		{100, 100, 0, true},
+	}
+	for _, test := range tests {
+		c.osArgs.FrontendConnRateLimit = test.connRate
+		c.osArgs.TLSHandshakeRateLimit = test.tlsRate
+		counter, err := c.eject5xxCounter()
+		if (err != nil) != test.err || (!test.err && counter != test.expected) {
+			t.Errorf("conn rate %d, tls rate %d: expected sc%d (error %t), got sc%d %v", test.connRate, test.tlsRate, test.expected, test.err, counter, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// handleBackendServerHeader removes ("remove" value) or overrides the Server header of responses
+// of a backend with "server-header" annotation, hiding the software of the pods.
+func (c *HAProxyController) handleBackendServerHeader(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	var wanted *models.HTTPResponseRule
+	annServer, _ := GetValueFromAnnotations("server-header", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annServer != nil && annServer.Status != DELETED {
+		value := strings.TrimSpace(annServer.Value)
+		switch {
+		case value == "remove":
+			wanted = &models.HTTPResponseRule{Type: "del-header", HdrName: "Server"}
+		case value == "" || strings.ContainsAny(value, "\"\\\r\n"):
+			return false, fmt.Errorf("server-header annotation: incorrect value '%s'", annServer.Value)
+		default:
+			wanted = &models.HTTPResponseRule{Type: "set-header", HdrName: "Server", HdrFormat: `"` + value + `"`}
+		}
+	}
+	_, rules, err := c.NativeAPI.Configuration.GetHTTPResponseRules("backend", backendName, c.ActiveTransaction)
+	if err != nil {
+		return false, err
+	}
+	current := []*models.HTTPResponseRule{}
+	for _, rule := range rules {
+		if strings.EqualFold(rule.HdrName, "Server") && (rule.Type == "del-header" || rule.Type == "set-header") && rule.Cond == "" {
+			current = append(current, rule)
+		}
+	}
+	if wanted == nil && len(current) == 0 {
+		return false, nil
+	}
+	if wanted != nil && len(current) == 1 && current[0].Type == wanted.Type && current[0].HdrFormat == wanted.HdrFormat {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	// IDs of following rules shift after each deletion
+	for i := len(current) - 1; i >= 0; i-- {
+		if errDel := c.NativeAPI.Configuration.DeleteHTTPResponseRule(*current[i].ID, "backend", backendName, c.ActiveTransaction, 0); errDel != nil {
+			return true, errDel
+		}
+	}
+	if wanted != nil {
+		wanted.ID = utils.PtrInt64(0)
+		err = c.NativeAPI.Configuration.CreateHTTPResponseRule("backend", backendName, wanted, c.ActiveTransaction, 0)
+	}
+	return true, err
+}
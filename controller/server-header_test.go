@@ -0,0 +1,98 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendServerHeader(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		err      bool
+	}{
+		{name: "remove", value: "remove", expected: "http-response del-header Server"},
+		{name: "override", value: "webserver", expected: `http-response set-header Server "webserver"`},
+		{name: "override with spaces", value: " my server ", expected: `http-response set-header Server "my server"`},
+		{name: "quote", value: `web"server`, err: true},
+		{name: "empty", value: " ", err: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(map[string]string{"server-header": test.value})}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendServerHeader(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.Contains(line, "Server") {
+					got = append(got, line)
+				}
+			}
+			if test.expected == "" {
+				if len(got) != 0 {
+					t.Errorf("expected no Server header rule, got %v", got)
+				}
+			} else if len(got) != 1 || got[0] != test.expected {
+				t.Errorf("expected '%s', got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleBackendServerHeaderUpdate(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"server-header": "remove"})}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendServerHeader(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendServerHeader(ingress, service, "default-app-80"); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+	service.Annotations["server-header"] = &StringW{Value: "webserver", Status: MODIFIED}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleBackendServerHeader(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if !testSectionHas(config, "backend default-app-80", `http-response set-header Server "webserver"`) || strings.Contains(config, "del-header Server") {
+		t.Errorf("expected Server header to be overridden only:\n%s", config)
+	}
+	service.Annotations["server-header"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleBackendServerHeader(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "Server") {
+		t.Errorf("expected no Server header rule:\n%s", config)
+	}
+}
@@ -15,6 +15,7 @@
 package server
 
 import (
+	"fmt"
 	"github.com/haproxytech/kubernetes-ingress/controller/utils"
 	"github.com/haproxytech/models"
 	"strconv"
@@ -67,3 +68,22 @@ func (s *Server) UpdateServerSsl(value string) error {
 	}
 	return nil
 }
+
+func (s *Server) UpdateAgentCheck(value string) error {
+	port, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("incorrect port '%s'", value)
+	}
+	s.AgentCheck = "enabled"
+	s.AgentPort = &port
+	return nil
+}
+
+func (s *Server) UpdateAgentInter(value string) error {
+	time, err := utils.ParseTime(value)
+	if err != nil {
+		return err
+	}
+	s.AgentInter = time
+	return nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// sseEnabled returns the value of "sse" annotation of a service
+func (c *HAProxyController) sseEnabled(ingress *Ingress, service *Service) (enabled bool, err error) {
+	annSSE, _ := GetValueFromAnnotations("sse", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annSSE == nil || annSSE.Status == DELETED {
+		return false, nil
+	}
+	if enabled, err = utils.GetBoolValue(annSSE.Value, "sse"); err != nil {
+		return false, fmt.Errorf("sse annotation: %s", err)
+	}
+	return enabled, nil
+}
+
+// handleBackendSSE configures the backend of a Server-Sent Events service ("sse" annotation):
+// events are forwarded as soon as they are received ("option http-no-delay") and the stream
+// is not cut between two events, "timeout server" is set to "timeout-tunnel-auto" unless
+// "timeout-server" is set on the service or ingress. "timeout tunnel" is set by handleBackendTunnelTimeout.
+func (c *HAProxyController) handleBackendSSE(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	enabled, err := c.sseEnabled(ingress, service)
+	if err != nil {
+		return false, err
+	}
+	lines := []string{}
+	timeout := ""
+	if enabled {
+		lines = append(lines, "option http-no-delay")
+		annAuto, _ := GetValueFromAnnotations("timeout-tunnel-auto", c.cfg.ConfigMap.Annotations)
+		timeout = annAuto.Value
+		for _, annotations := range []MapStringW{service.Annotations, ingress.Annotations} {
+			if ann, errAnn := annotations.Get("timeout-server"); errAnn == nil && ann.Status != DELETED {
+				timeout = ann.Value
+				break
+			}
+		}
+		if _, errTime := utils.ParseTime(timeout); errTime != nil {
+			return false, fmt.Errorf("sse annotation: incorrect server timeout '%s'", timeout)
+		}
+	}
+	needsReload, err = c.sectionDirectivesSet(parser.Backends, backendName, "option http-no-delay", lines)
+	if err != nil {
+		return needsReload, err
+	}
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return needsReload, err
+	}
+	current := ""
+	if data, errGet := config.Get(parser.Backends, backendName, "timeout server"); errGet == nil {
+		current = data.(*types.SimpleTimeout).Value
+	}
+	if current == timeout {
+		return needsReload, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	if timeout == "" {
+		return true, config.Set(parser.Backends, backendName, "timeout server", nil)
+	}
+	return true, config.Set(parser.Backends, backendName, "timeout server", types.SimpleTimeout{Value: timeout})
+}
@@ -0,0 +1,118 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendSSE(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{
+			name:        "enabled",
+			annotations: map[string]string{"sse": "true"},
+			expected:    []string{"option http-no-delay", "timeout server 24h", "timeout tunnel 24h"},
+		},
+		{
+			name:        "server timeout of service",
+			annotations: map[string]string{"sse": "true", "timeout-server": "10m"},
+			expected:    []string{"option http-no-delay", "timeout server 10m", "timeout tunnel 24h"},
+		},
+		{
+			name:        "tunnel timeout of service",
+			annotations: map[string]string{"sse": "true", "timeout-tunnel": "1h"},
+			expected:    []string{"option http-no-delay", "timeout server 24h", "timeout tunnel 1h"},
+		},
+		{
+			name:        "disabled",
+			annotations: map[string]string{"sse": "false"},
+			expected:    []string{},
+		},
+		{
+			name:        "invalid",
+			annotations: map[string]string{"sse": "maybe"},
+			err:         true,
+		},
+		{
+			name:        "invalid server timeout",
+			annotations: map[string]string{"sse": "true", "timeout-server": "soon"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				if _, errTunnel := c.handleBackendTunnelTimeout(ingress, service, "default-app-80"); errTunnel != nil {
+					t.Error(errTunnel)
+				}
+				_, err = c.handleBackendSSE(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if test.err {
+				return
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if line == "option http-no-delay" || strings.HasPrefix(line, "timeout server") || strings.HasPrefix(line, "timeout tunnel") {
+					got = append(got, line)
+				}
+			}
+			sort.Strings(got)
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestHandleBackendSSEDeleted(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"sse": "true"})}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendSSE(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendSSE(ingress, service, "default-app-80"); err != nil || reload {
+			t.Errorf("expected no reload, got %t %v", reload, err)
+		}
+	})
+	service.Annotations["sse"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, err := c.handleBackendSSE(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	if strings.Contains(config, "http-no-delay") || strings.Contains(config, "timeout server") {
+		t.Errorf("expected streaming settings to be removed:\n%s", config)
+	}
+}
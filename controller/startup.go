@@ -0,0 +1,64 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	startupBackoffMin = 100 * time.Millisecond
+	startupBackoffMax = 5 * time.Second
+)
+
+// waitForHAProxy is the startup readiness gate: HAProxy is started in background
+// so configuration and runtime clients are retried with exponential backoff
+// until they answer or --startup-timeout is reached.
+func (c *HAProxyController) waitForHAProxy() error {
+	deadline := time.Now().Add(c.osArgs.StartupTimeout)
+	backoff := startupBackoffMin
+	for {
+		err := c.haproxyReady()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("HAProxy not ready after %s: %s", c.osArgs.StartupTimeout, err)
+		}
+		log.Printf("waiting for HAProxy: %s, retrying in %s", err, backoff)
+		time.Sleep(backoff)
+		backoff *= 2
+		if backoff > startupBackoffMax {
+			backoff = startupBackoffMax
+		}
+	}
+}
+
+// haproxyReady checks that configuration can be read and, unless
+// running in test mode, that the runtime socket answers
+func (c *HAProxyController) haproxyReady() error {
+	if _, _, err := c.NativeAPI.Configuration.GetFrontends(""); err != nil {
+		return fmt.Errorf("configuration: %s", err)
+	}
+	if c.osArgs.Test {
+		return nil
+	}
+	if _, err := c.NativeAPI.Runtime.ExecuteRaw("show info"); err != nil {
+		return fmt.Errorf("runtime: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/haproxytech/client-native/runtime"
+)
+
+// testStartupController returns a controller whose runtime socket listens after delay, never if delay is 0
+func testStartupController(t *testing.T, delay time.Duration) (c *HAProxyController, cleanup func()) {
+	c, cleanupConfig := newTestController(t)
+	dir, err := ioutil.TempDir("", "haproxy-ingress-startup")
+	if err != nil {
+		cleanupConfig()
+		t.Fatal(err)
+	}
+	socket := filepath.Join(dir, "runtime.sock")
+	client := &runtime.Client{}
+	if err = client.InitWithSockets(map[int]string{0: socket}); err != nil {
+		os.RemoveAll(dir)
+		cleanupConfig()
+		t.Fatal(err)
+	}
+	c.NativeAPI.Runtime = client
+	c.osArgs.Test = false
+	c.osArgs.StartupTimeout = time.Second
+	done := make(chan struct{})
+	if delay > 0 {
+		go func() {
+			time.Sleep(delay)
+			listener, errListen := net.Listen("unix", socket)
+			if errListen != nil {
+				return
+			}
+			defer listener.Close()
+			go func() {
+				<-done
+				listener.Close()
+			}()
+			for {
+				conn, errAccept := listener.Accept()
+				if errAccept != nil {
+					return
+				}
+				conn.Write([]byte("Name: HAProxy\n"))
+				conn.Close()
+			}
+		}()
+	}
+	return c, func() {
+		close(done)
+		os.RemoveAll(dir)
+		cleanupConfig()
+	}
+}
+
+func TestWaitForHAProxy(t *testing.T) {
+	c, cleanup := testStartupController(t, 250*time.Millisecond)
+	defer cleanup()
+	start := time.Now()
+	if err := c.waitForHAProxy(); err != nil {
+		t.Fatal(err)
+	}
+	// retried until the runtime socket answered
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("expected retries, ready after %s", elapsed)
+	}
+}
+
+func TestWaitForHAProxyTimeout(t *testing.T) {
+	c, cleanup := testStartupController(t, 0)
+	defer cleanup()
+	start := time.Now()
+	if err := c.waitForHAProxy(); err == nil {
+		t.Fatal("expected error")
+	}
+	if elapsed := time.Since(start); elapsed > c.osArgs.StartupTimeout {
+		t.Errorf("expected to give up before %s, got %s", c.osArgs.StartupTimeout, elapsed)
+	}
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// ingressStatusRewrites returns "http-response set-status" rules rewriting status codes of
+// responses to the requests routed to an ingress with "response-status-rewrite" annotation,
+// e.g. "404:200" for single page applications. The ingress of a request is the one of its
+// use_backend rule, see handleIngressMatch, so other ingresses using the same service or a
+// longer path of the same host are not affected.
+func (c *HAProxyController) ingressStatusRewrites(ingress *Ingress) (rules []models.HTTPResponseRule, err error) {
+	annRewrite, _ := GetValueFromAnnotations("response-status-rewrite", ingress.Annotations)
+	if ingress.Status == DELETED || annRewrite == nil || annRewrite.Status == DELETED {
+		return nil, nil
+	}
+	rewrites, err := parseStatusRewrites(annRewrite.Value)
+	if err != nil {
+		return nil, fmt.Errorf("response-status-rewrite annotation: %s", err)
+	}
+	for _, cond := range c.ingressMatchConds(ingress) {
+		for _, rewrite := range rewrites {
+			rules = append(rules, models.HTTPResponseRule{
+				Type:     "set-status",
+				Status:   rewrite[1],
+				Cond:     "if",
+				CondTest: fmt.Sprintf("%s { status %d }", cond, rewrite[0]),
+			})
+		}
+	}
+	return rules, nil
+}
+
+// handleStatusRewrites replaces status rewrite rules of HTTP frontends by the ones of all ingresses,
+// other http-response rules of frontends are kept.
+func (c *HAProxyController) handleStatusRewrites(rules []models.HTTPResponseRule) (needsReload bool, err error) {
+	sort.Slice(rules, func(i, j int) bool {
+		return rules[i].CondTest < rules[j].CondTest
+	})
+	for _, frontend := range []string{FrontendHTTP, FrontendHTTPS} {
+		_, frontendRules, errGet := c.NativeAPI.Configuration.GetHTTPResponseRules("frontend", frontend, c.ActiveTransaction)
+		if errGet != nil {
+			err = errGet
+			continue
+		}
+		current := []*models.HTTPResponseRule{}
+		for _, rule := range frontendRules {
+			if rule.Type == "set-status" && strings.Contains(rule.CondTest, "var(txn."+ingressMatchVar+")") {
+				current = append(current, rule)
+			}
+		}
+		if statusRewritesEqual(current, rules) {
+			continue
+		}
+		c.ActiveTransactionHasChanges = true
+		needsReload = true
+		// IDs of following rules shift after each deletion
+		for i := len(current) - 1; i >= 0; i-- {
+			if errDel := c.NativeAPI.Configuration.DeleteHTTPResponseRule(*current[i].ID, "frontend", frontend, c.ActiveTransaction, 0); errDel != nil {
+				err = errDel
+			}
+		}
+		//INFO: order is reversed, first you insert last ones
+		for i := len(rules) - 1; i >= 0; i-- {
+			rule := rules[i]
+			rule.ID = utils.PtrInt64(0)
+			if errCreate := c.NativeAPI.Configuration.CreateHTTPResponseRule("frontend", frontend, &rule, c.ActiveTransaction, 0); errCreate != nil {
+				err = errCreate
+			}
+		}
+	}
+	return needsReload, err
+}
+
+func statusRewritesEqual(current []*models.HTTPResponseRule, rules []models.HTTPResponseRule) bool {
+	if len(current) != len(rules) {
+		return false
+	}
+	for i, rule := range current {
+		wanted := rules[i]
+		wanted.ID = rule.ID
+		if !reflect.DeepEqual(*rule, wanted) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseStatusRewrites validates a comma or new line separated list of "<status>:<new status>" items.
+// A new status can not be rewritten again by another item.
+func parseStatusRewrites(value string) (rewrites [][2]int64, err error) {
+	from := map[int64]bool{}
+	to := map[int64]bool{}
+	for _, item := range strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == '\n'
+	}) {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		parts := strings.Split(item, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("incorrect item '%s', expected '<status>:<new status>'", item)
+		}
+		var rewrite [2]int64
+		for i, part := range parts {
+			code, errConv := strconv.ParseInt(strings.TrimSpace(part), 10, 64)
+			if errConv != nil || code < 100 || code > 599 {
+				return nil, fmt.Errorf("incorrect status code '%s'", strings.TrimSpace(part))
+			}
+			rewrite[i] = code
+		}
+		if from[rewrite[0]] {
+			return nil, fmt.Errorf("status code %d rewritten twice", rewrite[0])
+		}
+		from[rewrite[0]] = true
+		to[rewrite[1]] = true
+		rewrites = append(rewrites, rewrite)
+	}
+	if len(rewrites) == 0 {
+		return nil, fmt.Errorf("empty value")
+	}
+	for code := range to {
+		if from[code] {
+			return nil, fmt.Errorf("status code %d is both rewritten and a new status", code)
+		}
+	}
+	return rewrites, nil
+}
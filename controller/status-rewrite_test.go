@@ -0,0 +1,94 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+func TestParseStatusRewrites(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected [][2]int64
+		err      bool
+	}{
+		{"404:200", [][2]int64{{404, 200}}, false},
+		{"404:200, 503:500\n502 : 500", [][2]int64{{404, 200}, {503, 500}, {502, 500}}, false},
+		{"404", nil, true},
+		{"404:2000", nil, true},
+		{"abc:200", nil, true},
+		{"404:200,404:204", nil, true},
+		{"404:503,503:500", nil, true},
+		{", ", nil, true},
+	}
+	for _, test := range tests {
+		rewrites, err := parseStatusRewrites(test.value)
+		if (err != nil) != test.err {
+			t.Errorf("%q: expected error %t, got %v", test.value, test.err, err)
+		}
+		if !reflect.DeepEqual(rewrites, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.value, test.expected, rewrites)
+		}
+	}
+}
+
+func TestHandleStatusRewrites(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	spa := c.testIngress("default", "spa")
+	spa.Annotations = testAnnotations(map[string]string{"response-status-rewrite": "404:200"})
+	api := c.testIngress("default", "api")
+	c.addUseBackendRule("Rdefaultspaexample.com/", UseBackendRule{Host: "example.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "spa"}, FrontendHTTP, FrontendHTTPS)
+	c.addUseBackendRule("Rdefaultapiexample.com/api", UseBackendRule{Host: "example.com", Path: "/api", Backend: "default-app-80", Namespace: "default", Ingress: "api"}, FrontendHTTP, FrontendHTTPS)
+
+	sync := func() (config string, needsReload bool) {
+		config = c.testSync(t, func() {
+			rules := []models.HTTPResponseRule{}
+			for _, ingress := range []*Ingress{spa, api} {
+				rewrites, err := c.ingressStatusRewrites(ingress)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rules = append(rules, rewrites...)
+			}
+			var err error
+			if needsReload, err = c.handleStatusRewrites(rules); err != nil {
+				t.Fatal(err)
+			}
+		})
+		return config, needsReload
+	}
+	config, reload := sync()
+	if !reload {
+		t.Error("expected reload")
+	}
+	expected := "http-response set-status 200 if { var(txn.ingress) -m str default/spa } { status 404 }"
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		if !testSectionHas(config, frontend, expected) {
+			t.Errorf("%s: expected '%s':\n%s", frontend, expected, config)
+		}
+	}
+	if _, reload = sync(); reload {
+		t.Error("expected no reload")
+	}
+	spa.Annotations["response-status-rewrite"].Status = DELETED
+	config, _ = sync()
+	if testSectionHas(config, "frontend http", expected) {
+		t.Errorf("expected rule removed:\n%s", config)
+	}
+}
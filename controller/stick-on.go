@@ -0,0 +1,105 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// stickExpressionRegexp matches a sample fetch followed by optional converters,
+// e.g. "req.hdr(X-User),lower" or "url_param(session)"
+var stickExpressionRegexp = regexp.MustCompile(`^[a-z][a-z0-9_.]*(\([^()\s]*\))?(,[a-z][a-z0-9_]*(\([^()\s]*\))?)*$`)
+
+// handleBackendStickOn configures stickiness of a backend on the sample expression
+// of "stick-on" annotation with a "stick on <expr>" rule and the backend stick-table.
+// Entries expire after "stick-on-expire" of inactivity.
+// WebSocket backends stick on source address by default, see websocketAffinity.
+func (c *HAProxyController) handleBackendStickOn(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	var stickTable *types.StickTable
+	var sticks []types.Stick
+	annStickOn, _ := GetValueFromAnnotations("stick-on", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annStickOn == nil || annStickOn.Status == DELETED {
+		annStickOn, err = c.websocketAffinity(ingress, service)
+	}
+	if annStickOn != nil && annStickOn.Status != DELETED {
+		if !stickExpressionRegexp.MatchString(annStickOn.Value) {
+			err = fmt.Errorf("stick-on annotation: incorrect sample expression '%s'", annStickOn.Value)
+		} else {
+			annExpire, _ := GetValueFromAnnotations("stick-on-expire", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+			if _, errTime := utils.ParseTime(annExpire.Value); errTime != nil {
+				return false, fmt.Errorf("stick-on-expire annotation: incorrect value '%s'", annExpire.Value)
+			}
+			stickTable = &types.StickTable{
+				Type:   "string",
+				Length: "128",
+				Size:   "100k",
+				Expire: annExpire.Value,
+			}
+			sticks = []types.Stick{{Type: "on", Pattern: annStickOn.Value}}
+		}
+	}
+	config, errCfg := c.ActiveConfiguration()
+	if errCfg != nil {
+		return false, errCfg
+	}
+	var currentTable *types.StickTable
+	if data, errGet := config.Get(parser.Backends, backendName, "stick-table"); errGet == nil {
+		currentTable = data.(*types.StickTable)
+	}
+	var currentSticks []types.Stick
+	if data, errGet := config.Get(parser.Backends, backendName, "stick"); errGet == nil {
+		currentSticks = data.([]types.Stick)
+	}
+	if reflect.DeepEqual(currentTable, stickTable) && reflect.DeepEqual(currentSticks, sticks) {
+		return false, err
+	}
+	c.ActiveTransactionHasChanges = true
+	if stickTable == nil {
+		utils.LogErr(config.Set(parser.Backends, backendName, "stick", nil))
+		utils.LogErr(config.Set(parser.Backends, backendName, "stick-table", nil))
+		return true, err
+	}
+	utils.LogErr(config.Set(parser.Backends, backendName, "stick-table", *stickTable))
+	utils.LogErr(config.Set(parser.Backends, backendName, "stick", sticks))
+	return true, err
+}
+
+// websocketAffinity returns a "src" stick-on expression for WebSocket backends ("backend-protocol: ws")
+// without cookie-persistence, so that a client reconnecting after a dropped upgrade reaches
+// the same pod. It is disabled with "websocket-affinity" annotation.
+func (c *HAProxyController) websocketAffinity(ingress *Ingress, service *Service) (*StringW, error) {
+	annProtocol, _ := GetValueFromAnnotations("backend-protocol", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	if annProtocol == nil || annProtocol.Status == DELETED || annProtocol.Value != "ws" {
+		return nil, nil
+	}
+	if annCookie, _ := GetValueFromAnnotations("cookie-persistence", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations); annCookie != nil && annCookie.Status != DELETED && annCookie.Value != "" {
+		return nil, nil
+	}
+	annAffinity, _ := GetValueFromAnnotations("websocket-affinity", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+	enabled, err := utils.GetBoolValue(annAffinity.Value, "websocket-affinity")
+	if err != nil {
+		return nil, fmt.Errorf("websocket-affinity annotation: %s", err)
+	}
+	if !enabled {
+		return nil, nil
+	}
+	return &StringW{Value: "src"}, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendStickOn(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		expected    []string
+		err         bool
+	}{
+		{
+			name:        "header",
+			annotations: map[string]string{"stick-on": "req.hdr(X-User),lower"},
+			expected: []string{
+				"stick on req.hdr(X-User),lower",
+				"stick-table type string len 128 size 100k expire 30m",
+			},
+		},
+		{
+			name:        "url parameter with expire",
+			annotations: map[string]string{"stick-on": "url_param(session)", "stick-on-expire": "2h"},
+			expected: []string{
+				"stick on url_param(session)",
+				"stick-table type string len 128 size 100k expire 2h",
+			},
+		},
+		{
+			name:        "websocket",
+			annotations: map[string]string{"backend-protocol": "ws"},
+			expected: []string{
+				"stick on src",
+				"stick-table type string len 128 size 100k expire 30m",
+			},
+		},
+		{
+			name:        "websocket with stick-on",
+			annotations: map[string]string{"backend-protocol": "ws", "stick-on": "req.cook(session)"},
+			expected: []string{
+				"stick on req.cook(session)",
+				"stick-table type string len 128 size 100k expire 30m",
+			},
+		},
+		{
+			name:        "websocket with cookie-persistence",
+			annotations: map[string]string{"backend-protocol": "ws", "cookie-persistence": "session"},
+		},
+		{
+			name:        "websocket affinity disabled",
+			annotations: map[string]string{"backend-protocol": "ws", "websocket-affinity": "false"},
+		},
+		{
+			name:        "invalid websocket affinity",
+			annotations: map[string]string{"backend-protocol": "ws", "websocket-affinity": "sometimes"},
+			err:         true,
+		},
+		{
+			name:        "no affinity without websocket",
+			annotations: map[string]string{"backend-protocol": "h1"},
+		},
+		{
+			name:        "invalid expression",
+			annotations: map[string]string{"stick-on": "req.hdr(X-User) if TRUE"},
+			err:         true,
+		},
+		{
+			name:        "invalid expire",
+			annotations: map[string]string{"stick-on": "src", "stick-on-expire": "soon"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendStickOn(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			got := []string{}
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "stick") {
+					got = append(got, line)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.expected, "\n") {
+				t.Errorf("expected:\n%s\ngot:\n%s", strings.Join(test.expected, "\n"), strings.Join(got, "\n"))
+			}
+		})
+	}
+}
+
+func TestHandleBackendStickOnRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"stick-on": "req.hdr(X-User)"})}
+	c.testSync(t, func() {
+		if reload, err := c.handleBackendStickOn(ingress, service, "default-app-80"); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+	})
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendStickOn(ingress, service, "default-app-80"); reload {
+			t.Error("expected no reload")
+		}
+	})
+	service.Annotations["stick-on"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleBackendStickOn(ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "stick") {
+		t.Errorf("expected no stick rule nor table:\n%s", config)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/metrics"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// haproxyPidFile is the pidfile of HAProxy master process, set in global section
+	haproxyPidFile = "/var/run/haproxy.pid"
+	// supervisorInterval is the interval between two checks of HAProxy process
+	supervisorInterval = 2 * time.Second
+	// supervisorFailures is the number of consecutive failed checks before HAProxy
+	// is restarted, the pidfile is rewritten on each reload
+	supervisorFailures = 2
+)
+
+var (
+	metricHAProxyRestarts = metrics.NewCounterVec("haproxy_ingress_haproxy_restarts_total",
+		"Number of restarts of HAProxy after its process exited unexpectedly.")
+	metricHAProxyUp = metrics.NewGaugeVec("haproxy_ingress_haproxy_up",
+		"Whether HAProxy process is running (1) or not (0).")
+)
+
+// haproxyProcess starts HAProxy and tells whether it is still running,
+// so that supervision does not depend on how HAProxy is run
+type haproxyProcess interface {
+	Start() error
+	Running() bool
+}
+
+// serviceProcess runs HAProxy with --start-command, the init script of the image by default.
+// The master process is found with its pidfile.
+type serviceProcess struct {
+	pidFile string
+	command func() *exec.Cmd
+}
+
+func (p serviceProcess) Start() error {
+	// a pidfile left by a crashed master would make "service haproxy start" a no-op
+	if err := os.Remove(p.pidFile); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	// the command is built on each start, the include file may have been installed since
+	cmd := p.command()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() {
+		_ = cmd.Wait()
+	}()
+	return nil
+}
+
+func (p serviceProcess) Running() bool {
+	content, err := ioutil.ReadFile(p.pidFile)
+	if err != nil {
+		return false
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(content)))
+	if err != nil || pid <= 0 {
+		return false
+	}
+	// the daemonized master is reparented to the controller when it runs as pid 1,
+	// a master that exited stays a zombie until it is reaped
+	var status syscall.WaitStatus
+	if wpid, errWait := syscall.Wait4(pid, &status, syscall.WNOHANG, nil); errWait == nil && wpid == pid {
+		return false
+	}
+	return syscall.Kill(pid, 0) == nil
+}
+
+// superviseHAProxy checks HAProxy process every supervisorInterval, when it exited
+// the controller is marked as not ready and a restart is requested to SyncData
+// so that it does not happen during an update
+func (c *HAProxyController) superviseHAProxy() {
+	failures := 0
+	for range time.Tick(supervisorInterval) {
+		failures = c.checkHAProxyProcess(failures)
+	}
+}
+
+// checkHAProxyProcess checks HAProxy process once, it returns the number of consecutive failed checks
+func (c *HAProxyController) checkHAProxyProcess(failures int) int {
+	if c.haproxyProcess.Running() {
+		if c.setHAProxyUp(true) {
+			log.Println("HAProxy is running")
+		}
+		return 0
+	}
+	failures++
+	if failures < supervisorFailures {
+		return failures
+	}
+	if c.setHAProxyUp(false) {
+		log.Println("HAProxy process is not running")
+		c.eventChan <- SyncDataEvent{SyncType: HAPROXY_RESTART}
+	} else if failures%supervisorFailures == 0 {
+		// previous restart failed
+		c.eventChan <- SyncDataEvent{SyncType: HAPROXY_RESTART}
+	}
+	return failures
+}
+
+// restartHAProxy starts HAProxy again with the last configuration written, which
+// is the last valid one since transactions are checked before being committed.
+// Servers changed at runtime since last reload are fixed as drifts.
+func (c *HAProxyController) restartHAProxy() {
+	if c.haproxyProcess.Running() {
+		return
+	}
+	log.Println("HAProxy exited unexpectedly, restarting with", HAProxyCFG)
+	metricHAProxyRestarts.Add(1)
+	message := "HAProxy restarted after its process exited unexpectedly"
+	err := c.haproxyProcess.Start()
+	if err == nil {
+		err = c.waitForHAProxy()
+	}
+	if err != nil {
+		utils.LogErr(err)
+		message = "HAProxy exited unexpectedly and could not be restarted: " + err.Error()
+	} else {
+		c.checkDrift()
+	}
+	r := c.reloadEvents
+	if r.pod == "" || r.namespace == "" || c.k8s == nil {
+		return
+	}
+	go func() {
+		utils.LogErr(c.k8s.CreatePodEvent(r.namespace, r.pod, corev1.EventTypeWarning, "HAProxyRestarted", message))
+	}()
+}
+
+// setHAProxyUp records HAProxy process state, it returns true if it changed
+func (c *HAProxyController) setHAProxyUp(up bool) bool {
+	var value int32
+	if !up {
+		value = 1
+	}
+	metricHAProxyUp.Set(float64(1 - value))
+	return atomic.SwapInt32(&c.haproxyDown, value) != value
+}
+
+// haproxyUp is read by the readiness endpoint of the controller server
+func (c *HAProxyController) haproxyUp() bool {
+	return atomic.LoadInt32(&c.haproxyDown) == 0
+}
@@ -0,0 +1,167 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// testProcess is a fake HAProxy process, Start makes it run unless startErr is set
+type testProcess struct {
+	mu       sync.Mutex
+	running  bool
+	starts   int
+	startErr error
+}
+
+func (p *testProcess) Start() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.starts++
+	if p.startErr != nil {
+		return p.startErr
+	}
+	p.running = true
+	return nil
+}
+
+func (p *testProcess) Running() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running
+}
+
+// exit simulates an unexpected exit of HAProxy
+func (p *testProcess) exit() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.running = false
+}
+
+func testRestartEvents(c *HAProxyController) (count int) {
+	for {
+		select {
+		case event := <-c.eventChan:
+			if event.SyncType == HAPROXY_RESTART {
+				count++
+			}
+		default:
+			return count
+		}
+	}
+}
+
+func TestCheckHAProxyProcess(t *testing.T) {
+	process := &testProcess{running: true}
+	c := &HAProxyController{eventChan: make(chan SyncDataEvent, 10), haproxyProcess: process}
+	failures := c.checkHAProxyProcess(0)
+	if failures != 0 || !c.haproxyUp() || testRestartEvents(c) != 0 {
+		t.Fatalf("expected running HAProxy, got %d failures", failures)
+	}
+
+	process.exit()
+	// a single failed check can be a reload rewriting the pidfile
+	failures = c.checkHAProxyProcess(failures)
+	if !c.haproxyUp() || testRestartEvents(c) != 0 {
+		t.Error("expected no restart after first failed check")
+	}
+	failures = c.checkHAProxyProcess(failures)
+	if c.haproxyUp() || testRestartEvents(c) != 1 {
+		t.Error("expected HAProxy down and a restart")
+	}
+	// restart failed, it is requested again every supervisorFailures checks
+	failures = c.checkHAProxyProcess(failures)
+	if testRestartEvents(c) != 0 {
+		t.Error("expected no restart until next supervisorFailures checks")
+	}
+	failures = c.checkHAProxyProcess(failures)
+	if testRestartEvents(c) != 1 {
+		t.Error("expected restart to be requested again")
+	}
+
+	process.Start()
+	if failures = c.checkHAProxyProcess(failures); failures != 0 || !c.haproxyUp() {
+		t.Errorf("expected HAProxy up again, got %d failures", failures)
+	}
+}
+
+func TestRestartHAProxy(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	runtime := newTestRuntime(t, func(command string) string { return "1\n" })
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	c.reloadEvents = &reloadEvents{namespace: "haproxy-controller", pod: "haproxy-ingress-1", reasons: map[string]struct{}{}}
+	process := &testProcess{}
+	c.haproxyProcess = process
+	restarts, _ := metricHAProxyRestarts.Get()
+
+	c.restartHAProxy()
+	if process.starts != 1 || !process.Running() {
+		t.Fatalf("expected HAProxy to be started once, got %d starts", process.starts)
+	}
+	if value, _ := metricHAProxyRestarts.Get(); value != restarts+1 {
+		t.Errorf("expected restarts metric to be incremented, got %v", value)
+	}
+	// runtime state is checked for drifts after restart
+	checked := false
+	for _, command := range runtime.received() {
+		checked = checked || command == "show servers state"
+	}
+	if !checked {
+		t.Errorf("expected drift check, got %v", runtime.received())
+	}
+	events := testEvents(t, client, "haproxy-controller", 1)
+	if event := events[0]; event.Type != "Warning" || event.Reason != "HAProxyRestarted" || event.InvolvedObject.Name != "haproxy-ingress-1" {
+		t.Errorf("unexpected event %s %s on %s", event.Type, event.Reason, event.InvolvedObject.Name)
+	}
+
+	// restart requested while HAProxy was started again
+	c.restartHAProxy()
+	if process.starts != 1 {
+		t.Errorf("expected no restart of running HAProxy, got %d starts", process.starts)
+	}
+}
+
+func TestRestartHAProxyFailed(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	c.reloadEvents = &reloadEvents{namespace: "haproxy-controller", pod: "haproxy-ingress-1", reasons: map[string]struct{}{}}
+	c.haproxyProcess = &testProcess{startErr: errors.New("exit status 1")}
+	c.restartHAProxy()
+	events := testEvents(t, client, "haproxy-controller", 1)
+	if !strings.Contains(events[0].Message, "could not be restarted: exit status 1") {
+		t.Errorf("unexpected message '%s'", events[0].Message)
+	}
+}
+
+func TestHandleReady(t *testing.T) {
+	c := &HAProxyController{}
+	for _, up := range []bool{true, false, true} {
+		c.setHAProxyUp(up)
+		recorder := httptest.NewRecorder()
+		c.handleReady(recorder, httptest.NewRequest("GET", "/ready", nil))
+		if expected := map[bool]int{true: http.StatusOK, false: http.StatusServiceUnavailable}[up]; recorder.Code != expected {
+			t.Errorf("HAProxy up %t: expected status %d, got %d", up, expected, recorder.Code)
+		}
+	}
+}
@@ -19,13 +19,16 @@ type SyncType string
 
 //SyncType values
 const (
-	COMMAND   SyncType = "COMMAND"
-	CONFIGMAP SyncType = "CONFIGMAP"
-	ENDPOINTS SyncType = "ENDPOINTS"
-	INGRESS   SyncType = "INGRESS"
-	NAMESPACE SyncType = "NAMESPACE"
-	SERVICE   SyncType = "SERVICE"
-	SECRET    SyncType = "SECRET"
+	COMMAND         SyncType = "COMMAND"
+	CONFIGMAP       SyncType = "CONFIGMAP"
+	ENDPOINTS       SyncType = "ENDPOINTS"
+	FORCE_RELOAD    SyncType = "FORCE_RELOAD" //nolint
+	HAPROXY_RESTART SyncType = "HAPROXY_RESTART" //nolint
+	INGRESS         SyncType = "INGRESS"
+	NAMESPACE       SyncType = "NAMESPACE"
+	ROUTE_QUERY     SyncType = "ROUTE_QUERY" //nolint
+	SERVICE         SyncType = "SERVICE"
+	SECRET          SyncType = "SECRET"
 )
 
 //SyncDataEvent represents converted k8s received message
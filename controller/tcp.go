@@ -2,6 +2,7 @@ package controller
 
 import (
 	"fmt"
+	"log"
 	"strconv"
 	"strings"
 
@@ -16,6 +17,18 @@ func (c *HAProxyController) handleTCPServices() (needsReload bool, err error) {
 	for port, svc := range c.cfg.ConfigMapTCPServices.Annotations {
 		// Get TCP service from ConfigMap
 		parts := strings.Split(svc.Value, ":")
+		if len(parts) > 2 && strings.EqualFold(parts[2], "udp") {
+			// HAProxy does not proxy UDP in any version, do not create a TCP frontend instead
+			if svc.Status == ADDED || svc.Status == MODIFIED {
+				log.Printf("tcp-services: port %s: UDP service '%s' is not supported, skipped\n", port, svc.Value)
+			}
+			if svc.Status == MODIFIED && c.frontendDelete(fmt.Sprintf("tcp-%s", port)) == nil {
+				// previous TCP service of the port
+				needsReload = true
+				c.deleteFrontendRules(fmt.Sprintf("tcp-%s", port))
+			}
+			continue
+		}
 		portDest := parts[1]
 		parts = strings.Split(parts[0], "/")
 		namespace := parts[0]
@@ -29,14 +42,16 @@ func (c *HAProxyController) handleTCPServices() (needsReload bool, err error) {
 		}
 		switch svc.Status {
 		case DELETED:
+			// binds are removed with the frontend, its backend is removed
+			// by refreshBackendSwitching once no frontend uses it
 			err = c.frontendDelete(frontendName)
 			utils.PanicErr(err)
 			needsReload = true
-			c.cfg.BackendSwitchingStatus["tcp-services"] = struct{}{}
+			c.deleteFrontendRules(frontendName)
 			continue
 		case MODIFIED:
 			frontend, errFt := c.frontendGet(frontendName)
-			if err != nil {
+			if errFt != nil {
 				utils.PanicErr(errFt)
 				continue
 			}
@@ -45,6 +60,8 @@ func (c *HAProxyController) handleTCPServices() (needsReload bool, err error) {
 				utils.PanicErr(err)
 				continue
 			}
+			// previous backend is no longer used
+			c.cfg.BackendSwitchingStatus[frontendName] = struct{}{}
 		case ADDED:
 			frontend := models.Frontend{
 				Name:           frontendName,
@@ -0,0 +1,107 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+func TestHandleTCPServicesUDP(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.cfg.ConfigMapTCPServices = &ConfigMap{Annotations: MapStringW{
+		"53": &StringW{Value: "kube-system/dns:53:UDP", Status: ADDED},
+	}}
+	config := c.testSync(t, func() {
+		reload, err := c.handleTCPServices()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if reload {
+			t.Error("expected no reload")
+		}
+	})
+	if testSectionHas(config, "frontend tcp-53", "mode tcp") {
+		t.Errorf("expected no frontend for UDP service:\n%s", config)
+	}
+
+	// TCP service of the port changed to UDP
+	config = c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: "tcp-53", Mode: "tcp"}); err != nil {
+			t.Fatal(err)
+		}
+		c.cfg.ConfigMapTCPServices.Annotations["53"].Status = MODIFIED
+		reload, err := c.handleTCPServices()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reload {
+			t.Error("expected reload")
+		}
+	})
+	if testSectionHas(config, "frontend tcp-53", "mode tcp") {
+		t.Errorf("expected frontend of previous TCP service removed:\n%s", config)
+	}
+}
+
+func TestHandleTCPServicesDeleted(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testService("default", "db", nil)
+	c.cfg.ConfigMapTCPServices = &ConfigMap{Annotations: MapStringW{
+		"9000": &StringW{Value: "default/db:80", Status: ADDED},
+	}}
+	config := c.testSync(t, func() {
+		if reload, err := c.handleTCPServices(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		c.refreshBackendSwitching()
+	})
+	for _, line := range []string{"bind 0.0.0.0:9000 name bind_1", "default_backend default-db-80"} {
+		if !testSectionHas(config, "frontend tcp-9000", line) {
+			t.Fatalf("expected '%s' in frontend tcp-9000:\n%s", line, config)
+		}
+	}
+	if !strings.Contains(config, "backend default-db-80") {
+		t.Fatalf("expected backend default-db-80:\n%s", config)
+	}
+
+	// rules of the frontend, if any, are removed with it
+	c.cfg.BackendSwitchingRules["tcp-9000"] = UseBackendRules{}
+	c.cfg.ConfigMapTCPServices.Annotations["9000"].Status = DELETED
+	config = c.testSync(t, func() {
+		if reload, err := c.handleTCPServices(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "tcp-9000") || strings.Contains(config, ":9000") {
+		t.Errorf("expected frontend tcp-9000 and its binds removed:\n%s", config)
+	}
+	if strings.Contains(config, "default-db-80") {
+		t.Errorf("expected backend default-db-80 removed:\n%s", config)
+	}
+	if _, ok := c.cfg.BackendSwitchingRules["tcp-9000"]; ok {
+		t.Error("expected no use_backend rules of tcp-9000")
+	}
+	if len(c.cfg.BackendSwitchingStatus) != 0 {
+		t.Errorf("expected no backend switching status, got %v", c.cfg.BackendSwitchingStatus)
+	}
+}
@@ -0,0 +1,192 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// TLS mode applied to a host requested with both ssl-passthrough and TLS termination
+const (
+	tlsModePassthrough = "passthrough"
+	tlsModeTermination = "termination"
+)
+
+// handleTLSConflicts detects hosts routed by SNI in SSL frontend (ssl-passthrough) by some ingresses, and
+// in HTTPS frontend (TLS termination) by others. A host can only have one mode, the one of "tls-conflict-mode"
+// annotation wins ("passthrough" by default) and the use_backend rules of the other frontend are skipped.
+// Frontends are refreshed and Warning events recorded on the ingresses when conflicts change.
+func (c *HAProxyController) handleTLSConflicts() {
+	winner := tlsModePassthrough
+	annMode, errAnn := GetValueFromAnnotations("tls-conflict-mode", c.cfg.ConfigMap.Annotations)
+	if errAnn == nil && annMode.Status != DELETED {
+		switch annMode.Value {
+		case tlsModePassthrough, tlsModeTermination:
+			winner = annMode.Value
+		default:
+			utils.LogErr(fmt.Errorf("tls-conflict-mode annotation: expected passthrough or termination, got '%s'", annMode.Value))
+		}
+	}
+	passthrough := tlsHostIngresses(c.cfg.BackendSwitchingRules[FrontendSSL])
+	termination := tlsHostIngresses(c.cfg.BackendSwitchingRules[FrontendHTTPS])
+	conflicts := map[string]string{}
+	for host, ingresses := range passthrough {
+		if _, ok := termination[host]; !ok {
+			continue
+		}
+		conflicts[host] = winner
+		if c.tlsConflicts[host] == winner {
+			continue
+		}
+		message := fmt.Sprintf("host %s: ssl-passthrough of %s conflicts with TLS termination of %s, %s is applied",
+			host, strings.Join(ingresses, ", "), strings.Join(termination[host], ", "), winner)
+		log.Printf("WARNING: %s", message)
+		for _, ingress := range append(ingresses, termination[host]...) {
+			c.recordIngressWarning(ingress, "TLSConflict", message)
+		}
+	}
+	changed := len(conflicts) != len(c.tlsConflicts)
+	for host, mode := range c.tlsConflicts {
+		if conflicts[host] != mode {
+			changed = true
+		}
+	}
+	if changed {
+		c.cfg.BackendSwitchingStatus[FrontendSSL] = struct{}{}
+		c.cfg.BackendSwitchingStatus[FrontendHTTPS] = struct{}{}
+	}
+	c.tlsConflicts = conflicts
+}
+
+// tlsConflictSkip returns true if the use_backend rule of the frontend lost a TLS mode conflict
+func (c *HAProxyController) tlsConflictSkip(frontend string, rule UseBackendRule) bool {
+	switch c.tlsConflicts[strings.ToLower(rule.Host)] {
+	case tlsModePassthrough:
+		return frontend == FrontendHTTPS
+	case tlsModeTermination:
+		return frontend == FrontendSSL
+	}
+	return false
+}
+
+// tlsHostIngresses returns sorted <namespace>/<ingress> names of use_backend rules per host
+func tlsHostIngresses(rules UseBackendRules) map[string][]string {
+	hosts := map[string]map[string]struct{}{}
+	for _, rule := range rules {
+		if rule.Host == "" {
+			continue
+		}
+		host := strings.ToLower(rule.Host)
+		if hosts[host] == nil {
+			hosts[host] = map[string]struct{}{}
+		}
+		hosts[host][rule.Namespace+"/"+rule.Ingress] = struct{}{}
+	}
+	result := make(map[string][]string, len(hosts))
+	for host, ingresses := range hosts {
+		for ingress := range ingresses {
+			result[host] = append(result[host], ingress)
+		}
+		sort.Strings(result[host])
+	}
+	return result
+}
+
+// tlsSecretOwner is an ingress providing a TLS secret for a host
+type tlsSecretOwner struct {
+	ingress *Ingress
+	secret  string
+}
+
+// handleTLSSecretConflicts detects hosts for which ingresses provide different TLS secrets. Certificates
+// of a same host would be chosen by HAProxy in file order, the oldest ingress (by creation time, then by
+// namespace and name) wins and the TLS entries of the other ingresses for that host are skipped.
+// A Warning event is recorded on the ingresses that lost when conflicts change.
+// It returns skipped hosts by <namespace>/<ingress>.
+func (c *HAProxyController) handleTLSSecretConflicts() (skipped map[string]map[string]struct{}) {
+	owners := map[string][]tlsSecretOwner{}
+	for _, namespace := range c.cfg.Namespace {
+		if !namespace.Relevant {
+			continue
+		}
+		for _, ingress := range namespace.Ingresses {
+			annClass, _ := GetValueFromAnnotations("ingress.class", ingress.Annotations)
+			if ingress.Status == DELETED || (annClass.Value != "" && annClass.Value != c.osArgs.IngressClass) {
+				continue
+			}
+			for _, tls := range ingress.TLS {
+				if tls.Status == DELETED || tls.Host == "" {
+					continue
+				}
+				secret := tls.SecretName.Value
+				if !strings.Contains(secret, "/") {
+					secret = ingress.Namespace + "/" + secret
+				}
+				// a missing secret can not win over an existing one
+				parts := strings.SplitN(secret, "/", 2)
+				if ns, ok := c.cfg.Namespace[parts[0]]; !ok || ns.Secret[parts[1]] == nil || ns.Secret[parts[1]].Status == DELETED {
+					continue
+				}
+				host := strings.ToLower(tls.Host)
+				owners[host] = append(owners[host], tlsSecretOwner{ingress: ingress, secret: secret})
+			}
+		}
+	}
+	skipped = map[string]map[string]struct{}{}
+	conflicts := map[string]string{}
+	for host, hostOwners := range owners {
+		sort.Slice(hostOwners, func(i, j int) bool {
+			a, b := hostOwners[i].ingress, hostOwners[j].ingress
+			if !a.CreationTimestamp.Equal(b.CreationTimestamp) {
+				return a.CreationTimestamp.Before(b.CreationTimestamp)
+			}
+			return a.Namespace+"/"+a.Name < b.Namespace+"/"+b.Name
+		})
+		winner := hostOwners[0]
+		winnerName := winner.ingress.Namespace + "/" + winner.ingress.Name
+		losers := []string{}
+		for _, owner := range hostOwners[1:] {
+			if owner.secret == winner.secret {
+				continue
+			}
+			name := owner.ingress.Namespace + "/" + owner.ingress.Name
+			if skipped[name] == nil {
+				skipped[name] = map[string]struct{}{}
+			}
+			skipped[name][host] = struct{}{}
+			losers = append(losers, name)
+		}
+		if len(losers) == 0 {
+			continue
+		}
+		message := fmt.Sprintf("host %s: TLS secret %s of oldest ingress %s is used, secrets of %s are ignored",
+			host, winner.secret, winnerName, strings.Join(losers, ", "))
+		conflicts[host] = message
+		if c.tlsSecretConflicts[host] == message {
+			continue
+		}
+		log.Printf("WARNING: %s", message)
+		for _, loser := range losers {
+			c.recordIngressWarning(loser, "TLSSecretConflict", message)
+		}
+	}
+	c.tlsSecretConflicts = conflicts
+	return skipped
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/haproxytech/models"
+)
+
+// testTLSConflict creates the SSL frontend and routes secure.com with ssl-passthrough
+// from ingress "default/passthrough" and with TLS termination from "default/termination"
+func (c *HAProxyController) testTLSConflict(t *testing.T) {
+	c.testIngress("default", "passthrough")
+	c.testIngress("default", "termination")
+	c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: FrontendSSL, Mode: "tcp", DefaultBackend: "default_backend"}); err != nil {
+			t.Fatal(err)
+		}
+	})
+	c.addUseBackendRule("Rdefaultpassthroughsecure.com", UseBackendRule{Host: "secure.com", Backend: "default-app-443", Namespace: "default", Ingress: "passthrough"}, FrontendSSL)
+	c.addUseBackendRule("Rdefaultterminationsecure.com/", UseBackendRule{Host: "secure.com", Path: "/", Backend: "default-app-80", Namespace: "default", Ingress: "termination"}, FrontendHTTP, FrontendHTTPS)
+}
+
+func TestTLSConflictPassthrough(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	c.testTLSConflict(t)
+	config := c.testSync(t, func() {
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if !testSectionHas(config, "frontend ssl", "use_backend default-app-443 if { req_ssl_sni -i secure.com }") {
+		t.Errorf("expected ssl-passthrough rule:\n%s", config)
+	}
+	if strings.Contains(strings.Join(testSection(config, "frontend https"), "\n"), "use_backend") {
+		t.Errorf("expected no HTTPS rule:\n%s", config)
+	}
+	if !strings.Contains(strings.Join(testSection(config, "frontend http"), "\n"), "use_backend default-app-80") {
+		t.Errorf("expected HTTP rule to be kept:\n%s", config)
+	}
+	events := testEvents(t, client, "default", 2)
+	involved := []string{}
+	for _, event := range events {
+		if event.Reason != "TLSConflict" || event.Type != "Warning" || !strings.Contains(event.Message, "passthrough is applied") {
+			t.Errorf("unexpected event %s %s: %s", event.Type, event.Reason, event.Message)
+		}
+		involved = append(involved, event.InvolvedObject.Kind+" "+event.InvolvedObject.Name)
+	}
+	if strings.Join(involved, ",") != "Ingress passthrough,Ingress termination" && strings.Join(involved, ",") != "Ingress termination,Ingress passthrough" {
+		t.Errorf("expected events on both ingresses, got %v", involved)
+	}
+
+	// no new event while the conflict is unchanged
+	c.cfg.BackendSwitchingStatus[FrontendHTTPS] = struct{}{}
+	c.testSync(t, func() { c.refreshBackendSwitching() })
+	testEvents(t, client, "default", 2)
+}
+
+func TestTLSConflictTermination(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testTLSConflict(t)
+	c.cfg.ConfigMap.Annotations["tls-conflict-mode"] = &StringW{Value: "termination", Status: ADDED}
+	config := c.testSync(t, func() {
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(strings.Join(testSection(config, "frontend ssl"), "\n"), "use_backend") {
+		t.Errorf("expected no ssl-passthrough rule:\n%s", config)
+	}
+	for _, frontend := range []string{"frontend http", "frontend https"} {
+		if !strings.Contains(strings.Join(testSection(config, frontend), "\n"), "use_backend default-app-80") {
+			t.Errorf("%s: expected TLS termination rule:\n%s", frontend, config)
+		}
+	}
+
+	// conflict is resolved when the termination ingress is removed
+	c.deleteUseBackendRule("Rdefaultterminationsecure.com/", FrontendHTTP, FrontendHTTPS)
+	c.cfg.ConfigMap.Annotations["tls-conflict-mode"].Status = EMPTY
+	config = c.testSync(t, func() {
+		if !c.refreshBackendSwitching() {
+			t.Error("expected reload")
+		}
+	})
+	if !testSectionHas(config, "frontend ssl", "use_backend default-app-443 if { req_ssl_sni -i secure.com }") {
+		t.Errorf("expected ssl-passthrough rule:\n%s", config)
+	}
+	if len(c.tlsConflicts) != 0 {
+		t.Errorf("expected no conflict, got %v", c.tlsConflicts)
+	}
+}
+
+// testTLSSecret adds ingress <name> of default namespace created at <created>,
+// providing TLS for secure.com with its existing secret <secret>
+func (c *HAProxyController) testTLSSecret(name, secret string, created time.Time) *Ingress {
+	ingress := c.testIngress("default", name)
+	ingress.CreationTimestamp = created
+	ingress.TLS[secret] = &IngressTLS{Host: "Secure.com", SecretName: StringW{Value: secret}, Status: ADDED}
+	c.cfg.GetNamespace("default").Secret[secret] = &Secret{Namespace: "default", Name: secret, Status: ADDED}
+	return ingress
+}
+
+func TestHandleTLSSecretConflicts(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	client := c.testK8s()
+	c.tlsSecretConflicts = map[string]string{}
+	created := time.Now()
+	c.testTLSSecret("newer", "newer-cert", created)
+	c.testTLSSecret("older", "older-cert", created.Add(-time.Hour))
+	c.testTLSSecret("same", "older-cert", created)
+
+	skipped := c.handleTLSSecretConflicts()
+	if len(skipped) != 1 || len(skipped["default/newer"]) != 1 {
+		t.Fatalf("expected secure.com of default/newer to be skipped, got %v", skipped)
+	}
+	if _, ok := skipped["default/newer"]["secure.com"]; !ok {
+		t.Errorf("expected lower case host to be skipped, got %v", skipped)
+	}
+	events := testEvents(t, client, "default", 1)
+	if event := events[0]; event.Reason != "TLSSecretConflict" || event.Type != "Warning" || event.InvolvedObject.Name != "newer" ||
+		!strings.Contains(event.Message, "TLS secret default/older-cert of oldest ingress default/older is used") {
+		t.Errorf("unexpected event on %s %s %s: %s", event.InvolvedObject.Name, event.Type, event.Reason, event.Message)
+	}
+
+	// no new event while the conflict is unchanged
+	c.handleTLSSecretConflicts()
+	testEvents(t, client, "default", 1)
+
+	// conflict is resolved when the older ingress is removed
+	c.cfg.GetNamespace("default").Ingresses["older"].Status = DELETED
+	skipped = c.handleTLSSecretConflicts()
+	if len(skipped) != 1 || len(skipped["default/same"]) != 1 {
+		t.Errorf("expected secure.com of default/same to be skipped, got %v", skipped)
+	}
+	c.cfg.GetNamespace("default").Ingresses["same"].Status = DELETED
+	if skipped = c.handleTLSSecretConflicts(); len(skipped) != 0 || len(c.tlsSecretConflicts) != 0 {
+		t.Errorf("expected no conflict, got %v %v", skipped, c.tlsSecretConflicts)
+	}
+}
+
+func TestHandleTLSSecretConflictsOrder(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.tlsSecretConflicts = map[string]string{}
+	created := time.Now()
+	c.testTLSSecret("b", "b-cert", created)
+	c.testTLSSecret("a", "a-cert", created)
+	// a missing secret can not win
+	c.testTLSSecret("missing", "missing-cert", created.Add(-time.Hour))
+	delete(c.cfg.GetNamespace("default").Secret, "missing-cert")
+
+	skipped := c.handleTLSSecretConflicts()
+	if _, ok := skipped["default/b"]["secure.com"]; !ok || len(skipped) != 1 {
+		t.Errorf("expected ingresses of same age to be ordered by name, got %v", skipped)
+	}
+}
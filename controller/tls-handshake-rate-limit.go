@@ -0,0 +1,70 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+	"github.com/haproxytech/models"
+)
+
+// tlsHandshakeRateBackend holds the stick-table tracking TLS connections per source address
+const tlsHandshakeRateBackend = "TLSHandshakeRate"
+
+// handleTLSHandshakeRateLimit rejects TLS connections of a source address once it opened more than
+// --tls-handshake-rate-limit of them in the last 10 seconds, before the handshake is done.
+// Rules are in HTTPS frontend, or in SSL frontend with SSL passthrough since HTTPS frontend
+// then receives connections from the SSL one, see requestsTCPRefresh.
+func (c *HAProxyController) handleTLSHandshakeRateLimit() (needsReload bool, err error) {
+	limit := c.osArgs.TLSHandshakeRateLimit
+	if limit <= 0 {
+		return false, nil
+	}
+	if _, errGet := c.backendGet(tlsHandshakeRateBackend); errGet != nil {
+		err = c.backendCreate(models.Backend{
+			Name: tlsHandshakeRateBackend,
+			StickTable: &models.BackendStickTable{
+				Type:   "ip",
+				Size:   utils.PtrInt64(100000),
+				Expire: utils.PtrInt64(30000),
+				Store:  "conn_rate(10s)",
+			},
+		})
+		if err != nil {
+			return false, err
+		}
+		needsReload = true
+	}
+	if len(c.cfg.TCPRequests[TLS_RATE_LIMIT]) == 0 {
+		c.cfg.TCPRequests[TLS_RATE_LIMIT] = []models.TCPRequestRule{
+			{
+				ID:     utils.PtrInt64(0),
+				Type:   "connection",
+				Action: "track-sc2 src table " + tlsHandshakeRateBackend,
+			},
+			{
+				ID:       utils.PtrInt64(0),
+				Type:     "connection",
+				Action:   "reject",
+				Cond:     "if",
+				CondTest: fmt.Sprintf("{ sc2_conn_rate(%s) gt %d }", tlsHandshakeRateBackend, limit),
+			},
+		}
+		c.cfg.TCPRequestsStatus = MODIFIED
+		needsReload = true
+	}
+	return needsReload, nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/haproxytech/models"
+)
+
+func TestHandleTLSHandshakeRateLimit(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.TLSHandshakeRateLimit = 20
+	config := c.testSync(t, func() {
+		if reload, err := c.handleTLSHandshakeRateLimit(); err != nil || !reload {
+			t.Errorf("expected reload, got %t %v", reload, err)
+		}
+		if _, err := c.requestsTCPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "backend TLSHandshakeRate", "stick-table type ip size 100000 expire 30000 store conn_rate(10s)") {
+		t.Errorf("expected stick-table in backend TLSHandshakeRate:\n%s", config)
+	}
+	expected := []string{
+		"tcp-request connection track-sc2 src table TLSHandshakeRate",
+		"tcp-request connection reject if { sc2_conn_rate(TLSHandshakeRate) gt 20 }",
+	}
+	for _, line := range expected {
+		if !testSectionHas(config, "frontend https", line) {
+			t.Errorf("expected '%s' in frontend https:\n%s", line, config)
+		}
+	}
+	if strings.Contains(strings.Join(testSection(config, "frontend http"), "\n"), "TLSHandshakeRate") {
+		t.Errorf("expected no TLS handshake rate limit in frontend http:\n%s", config)
+	}
+	// rules are generated once
+	c.testSync(t, func() {
+		if reload, _ := c.handleTLSHandshakeRateLimit(); reload {
+			t.Error("expected no reload")
+		}
+	})
+}
+
+func TestHandleTLSHandshakeRateLimitSSLPassthrough(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.osArgs.TLSHandshakeRateLimit = 20
+	c.cfg.SSLPassthrough = true
+	config := c.testSync(t, func() {
+		if err := c.frontendCreate(models.Frontend{Name: FrontendSSL, Mode: "tcp", DefaultBackend: "default_backend"}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.handleTLSHandshakeRateLimit(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.requestsTCPRefresh(); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if !testSectionHas(config, "frontend ssl", "tcp-request connection reject if { sc2_conn_rate(TLSHandshakeRate) gt 20 }") {
+		t.Errorf("expected TLS handshake rate limit in frontend ssl:\n%s", config)
+	}
+	if strings.Contains(strings.Join(testSection(config, "frontend https"), "\n"), "TLSHandshakeRate") {
+		t.Errorf("expected no TLS handshake rate limit in frontend https:\n%s", config)
+	}
+}
+
+func TestHandleTLSHandshakeRateLimitDisabled(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleTLSHandshakeRateLimit(); reload {
+			t.Error("expected no reload")
+		}
+	})
+	if strings.Contains(config, "TLSHandshakeRate") {
+		t.Errorf("expected no TLS handshake rate limit:\n%s", config)
+	}
+}
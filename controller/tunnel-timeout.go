@@ -0,0 +1,75 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+
+	parser "github.com/haproxytech/config-parser/v2"
+	"github.com/haproxytech/config-parser/v2/types"
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// handleBackendTunnelTimeout sets "timeout tunnel" in the backend of a service.
+// Backends with "timeout-tunnel" service or ingress annotation use its value,
+// WebSocket and h2 backends ("backend-protocol" annotation) and SSE backends ("sse" annotation) get "timeout-tunnel-auto"
+// so long lived streams are not cut, other backends use the defaults section value.
+func (c *HAProxyController) handleBackendTunnelTimeout(ingress *Ingress, service *Service, backendName string) (needsReload bool, err error) {
+	timeout := ""
+	for _, annotations := range []MapStringW{service.Annotations, ingress.Annotations} {
+		if ann, errAnn := annotations.Get("timeout-tunnel"); errAnn == nil && ann.Status != DELETED {
+			timeout = ann.Value
+			break
+		}
+	}
+	if sse, _ := c.sseEnabled(ingress, service); sse && timeout == "" {
+		annAuto, _ := GetValueFromAnnotations("timeout-tunnel-auto", c.cfg.ConfigMap.Annotations)
+		timeout = annAuto.Value
+	}
+	if timeout == "" {
+		annProtocol, _ := GetValueFromAnnotations("backend-protocol", service.Annotations, ingress.Annotations, c.cfg.ConfigMap.Annotations)
+		if annProtocol != nil && annProtocol.Status != DELETED {
+			switch annProtocol.Value {
+			case "http":
+			case "ws", "h2":
+				annAuto, _ := GetValueFromAnnotations("timeout-tunnel-auto", c.cfg.ConfigMap.Annotations)
+				timeout = annAuto.Value
+			default:
+				utils.LogErr(fmt.Errorf("backend-protocol annotation: unknown protocol '%s', expected http, ws or h2", annProtocol.Value))
+			}
+		}
+	}
+	if timeout != "" {
+		if _, errTime := utils.ParseTime(timeout); errTime != nil {
+			return false, fmt.Errorf("timeout-tunnel annotation: incorrect value '%s'", timeout)
+		}
+	}
+	config, err := c.ActiveConfiguration()
+	if err != nil {
+		return false, err
+	}
+	current := ""
+	if data, errGet := config.Get(parser.Backends, backendName, "timeout tunnel"); errGet == nil {
+		current = data.(*types.SimpleTimeout).Value
+	}
+	if current == timeout {
+		return false, nil
+	}
+	c.ActiveTransactionHasChanges = true
+	if timeout == "" {
+		return true, config.Set(parser.Backends, backendName, "timeout tunnel", nil)
+	}
+	return true, config.Set(parser.Backends, backendName, "timeout tunnel", types.SimpleTimeout{Value: timeout})
+}
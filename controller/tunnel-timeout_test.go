@@ -0,0 +1,109 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHandleBackendTunnelTimeout(t *testing.T) {
+	tests := []struct {
+		name        string
+		annotations map[string]string
+		auto        string
+		expected    string
+		err         bool
+	}{
+		{
+			name:        "websocket backend",
+			annotations: map[string]string{"backend-protocol": "ws"},
+			expected:    "timeout tunnel 24h",
+		},
+		{
+			name:        "h2 backend with configured auto timeout",
+			annotations: map[string]string{"backend-protocol": "h2"},
+			auto:        "1h",
+			expected:    "timeout tunnel 1h",
+		},
+		{
+			name:        "explicit annotation overrides auto timeout",
+			annotations: map[string]string{"backend-protocol": "ws", "timeout-tunnel": "10m"},
+			expected:    "timeout tunnel 10m",
+		},
+		{
+			name:        "http backend",
+			annotations: map[string]string{"backend-protocol": "http"},
+		},
+		{
+			name:        "invalid timeout",
+			annotations: map[string]string{"timeout-tunnel": "soon"},
+			err:         true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, cleanup := newTestController(t)
+			defer cleanup()
+			if test.auto != "" {
+				c.cfg.ConfigMap.Annotations["timeout-tunnel-auto"] = &StringW{Value: test.auto, Status: ADDED}
+			}
+			ingress := &Ingress{Annotations: MapStringW{}}
+			service := &Service{Annotations: testAnnotations(test.annotations)}
+			var err error
+			config := c.testSync(t, func() {
+				_, err = c.handleBackendTunnelTimeout(ingress, service, "default-app-80")
+			})
+			if (err != nil) != test.err {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			timeout := ""
+			for _, line := range testSection(config, "backend default-app-80") {
+				if strings.HasPrefix(line, "timeout tunnel") {
+					timeout = line
+				}
+			}
+			if timeout != test.expected {
+				t.Errorf("expected '%s', got '%s'", test.expected, timeout)
+			}
+		})
+	}
+}
+
+func TestHandleBackendTunnelTimeoutRemoved(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	ingress := &Ingress{Annotations: MapStringW{}}
+	service := &Service{Annotations: testAnnotations(map[string]string{"backend-protocol": "ws"})}
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendTunnelTimeout(ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	c.testSync(t, func() {
+		if reload, _ := c.handleBackendTunnelTimeout(ingress, service, "default-app-80"); reload {
+			t.Error("expected no reload")
+		}
+	})
+	service.Annotations["backend-protocol"].Status = DELETED
+	config := c.testSync(t, func() {
+		if reload, _ := c.handleBackendTunnelTimeout(ingress, service, "default-app-80"); !reload {
+			t.Error("expected reload")
+		}
+	})
+	if strings.Contains(config, "timeout tunnel") {
+		t.Errorf("expected no timeout tunnel:\n%s", config)
+	}
+}
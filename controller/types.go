@@ -15,6 +15,8 @@
 package controller
 
 import (
+	"time"
+
 	extensions "k8s.io/api/extensions/v1beta1"
 )
 
@@ -26,9 +28,12 @@ const (
 
 var (
 	HAProxyCFG        string
+	HAProxyIncludeCFG string
 	HAProxyCertDir    string
 	HAProxyStateDir   string
 	HAProxyCaptureDir string
+	HAProxyErrorsDir  string
+	HAProxyJWTDir     string
 )
 
 //ServicePort describes port of a service
@@ -65,6 +70,8 @@ type Endpoints struct {
 	Ports       *EndpointPorts
 	Addresses   *EndpointIPs
 	Status      Status
+	// Rebalance is set when servers were enabled or disabled via runtime API, see handleRebalance
+	Rebalance bool
 }
 
 //Service is usefull data from k8s structures about service
@@ -87,7 +94,9 @@ type Namespace struct {
 	Endpoints map[string]*Endpoints
 	Services  map[string]*Service
 	Secret    map[string]*Secret
-	Status    Status
+	// ConfigMaps referenced by annotations (e.g. errorfile-503)
+	ConfigMaps map[string]*ConfigMap
+	Status     Status
 }
 
 //IngressPath is usefull data from k8s structures about ingress path
@@ -100,6 +109,7 @@ type IngressPath struct {
 	IsTCPService      bool
 	IsSSLPassthrough  bool
 	IsDefaultBackend  bool
+	IsCanary          bool
 	Status            Status
 }
 
@@ -119,6 +129,8 @@ type Ingress struct {
 	DefaultBackend *IngressPath
 	TLS            map[string]*IngressTLS
 	Status         Status
+	// CreationTimestamp decides which ingress wins a TLS secret conflict, see handleTLSSecretConflicts
+	CreationTimestamp time.Time
 }
 
 // IngressTLS describes the transport layer security associated with an Ingress.
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 )
 
 //NamespaceValue used to automatically distinct namespace/name string
@@ -32,6 +33,7 @@ func (n NamespaceValue) MarshalFlag() (string, error) {
 type OSArgs struct {
 	Version               []bool         `short:"v" long:"version" description:"version"`
 	DefaultBackendService NamespaceValue `long:"default-backend-service" default:"" description:"default service to serve 404 page. If not specified HAProxy serves http 400"`
+	ACMESolverService     NamespaceValue `long:"acme-solver-service" default:"" description:"service receiving ACME HTTP-01 challenges (/.well-known/acme-challenge/) of all hosts, challenges are not redirected to HTTPS"`
 	DefaultCertificate    NamespaceValue `long:"default-ssl-certificate" default:"" description:"secret name of the certificate"`
 	ConfigMap             NamespaceValue `long:"configmap" description:"configmap designated for HAProxy" default:"default/haproxy-configmap"`
 	ConfigMapTCPServices  NamespaceValue `long:"configmap-tcp-services" description:"configmap used to define tcp services" default:""`
@@ -42,5 +44,27 @@ type OSArgs struct {
 	Test                  bool           `short:"t" description:"simulate running HAProxy"`
 	Help                  []bool         `short:"h" long:"help" description:"show this help message"`
 	IngressClass          string         `long:"ingress.class" default:"haproxy" description:"ingress.class to monitor in multiple controllers environment"`
+	ConfigInclude         string         `long:"config-include" default:"" description:"path of a raw HAProxy configuration file (mounted from a ConfigMap) that is validated and loaded alongside the generated configuration"`
+	ControllerPort        int            `long:"controller-port" default:"0" description:"port of the controller HTTP server exposing /metrics, disabled if 0"`
+	ForceReloadToken      string         `long:"force-reload-token" env:"FORCE_RELOAD_TOKEN" default:"" description:"token required by the force-reload endpoint of the controller server, endpoint is disabled if empty"`
+	RouteQueryToken       string         `long:"route-query-token" env:"ROUTE_QUERY_TOKEN" default:"" description:"token required by the read-only route endpoint of the controller server, endpoint is disabled if empty"`
+	DriftCheckInterval    time.Duration  `long:"drift-check-interval" default:"0s" description:"interval between checks of HAProxy runtime state against desired state, disabled if 0"`
+	AllowCrossNamespace   bool           `long:"allow-cross-namespace-backends" description:"allow canary-service annotation to reference a service of another namespace"`
+	StartupTimeout        time.Duration  `long:"startup-timeout" default:"60s" description:"maximum time to wait at startup for HAProxy configuration and runtime API to be available"`
+	NoHostMatchAction     string         `long:"no-host-match-action" default:"default-backend" description:"response to requests matching no rule when there is no default backend service: default-backend, 404, 421 or absolute path of a file with a custom raw HTTP response"`
+	FrontendConnRateLimit int64          `long:"frontend-conn-rate-limit" default:"0" description:"maximum number of new connections per second accepted by HTTP frontends from all sources, disabled if 0"`
+	TLSHandshakeRateLimit int64          `long:"tls-handshake-rate-limit" default:"0" description:"maximum number of TLS connections per source address in 10 seconds, disabled if 0"`
+	LogFormatSD           string         `long:"log-format-sd" default:"" description:"structured-data log format of RFC5424 syslog messages (log-format-sd)"`
+	RestrictHdrNames      string         `long:"http-restrict-req-hdr-names" default:"delete" description:"handling of request headers with invalid names in HTTP frontends: preserve, delete or reject"`
+	EventWorkers          int            `long:"event-workers" default:"1" description:"number of workers processing ingress, service and endpoints events, events of a namespace are always processed in order"`
+	HAProxyBinary         string         `long:"haproxy-binary" default:"haproxy" description:"path of HAProxy binary used to detect its version, check configurations and run HAProxy (HAPROXY_BINARY of start and reload commands)"`
+	ReloadCommand         string         `long:"reload-command" default:"service haproxy reload" description:"shell command reloading HAProxy, {binary}, {config} and {include} are replaced by HAProxy binary, configuration file and -f option of included file"`
+	StartCommand          string         `long:"start-command" default:"service haproxy start" description:"shell command starting HAProxy, {binary}, {config} and {include} are replaced by HAProxy binary, configuration file and -f option of included file"`
+	TuneMaxAccept         int            `long:"tune-maxaccept" default:"0" description:"maximum number of connections accepted at once by a listener (tune.maxaccept), -1 for unlimited, HAProxy default if 0"`
+	BindBacklog           int            `long:"bind-backlog" default:"0" description:"size of the accept queue of HTTP, HTTPS and TCP services binds (backlog), HAProxy default if 0"`
+	Contstats             bool           `long:"contstats" description:"update traffic counters continuously instead of at session end (option contstats)"`
+	TCPSmartAccept        bool           `long:"tcp-smart-accept" description:"accept connections of HTTP, HTTPS and SSL passthrough frontends when client data is received (option tcp-smart-accept)"`
+	TCPSmartConnect       bool           `long:"tcp-smart-connect" description:"send first client data with the ACK of backend connections (option tcp-smart-connect)"`
+	LogHealthChecks       bool           `long:"log-health-checks" description:"log health check state transitions of servers of all backends (option log-health-checks)"`
 	PublishService        string         `long:"publish-service" default:"" description:"Takes the form namespace/name. The controller mirrors the address of this service's endpoints to the load-balancer status of all Ingress objects it satisfies"`
 }
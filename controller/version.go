@@ -0,0 +1,105 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+)
+
+// HAProxyVersion is the version of the HAProxy binary used by the controller
+type HAProxyVersion struct {
+	Major int
+	Minor int
+	Patch int
+}
+
+// haproxyFeatures are the generated directives requiring a minimal HAProxy version
+var haproxyFeatures = map[string]HAProxyVersion{
+	"seamless-reload":             {Major: 1, Minor: 8},
+	"early-hint":                  {Major: 1, Minor: 9},
+	"server-proto":                {Major: 1, Minor: 9},
+	"htx-default":                 {Major: 2, Minor: 0},
+	"prometheus-exporter":         {Major: 2, Minor: 0},
+	"set-ssl-cert":                {Major: 2, Minor: 1},
+	"http-after-response":         {Major: 2, Minor: 2},
+	"deny-status-any":             {Major: 2, Minor: 2},
+	"normalize-uri":               {Major: 2, Minor: 4},
+	"wait-for-body":               {Major: 2, Minor: 4},
+	"jwt":                         {Major: 2, Minor: 5},
+	"hash-key":                    {Major: 2, Minor: 6},
+	"http-restrict-req-hdr-names": {Major: 2, Minor: 6},
+}
+
+var haproxyVersionRegexp = regexp.MustCompile(`HA-?Proxy version (\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseHAProxyVersion extracts version from "haproxy -v" output,
+// e.g. "HA-Proxy version 2.0.11 2019/12/11 - https://haproxy.org/"
+func parseHAProxyVersion(info string) (version HAProxyVersion, err error) {
+	match := haproxyVersionRegexp.FindStringSubmatch(info)
+	if match == nil {
+		return version, fmt.Errorf("unable to parse HAProxy version from '%s'", info)
+	}
+	version.Major, _ = strconv.Atoi(match[1])
+	version.Minor, _ = strconv.Atoi(match[2])
+	if match[3] != "" {
+		version.Patch, _ = strconv.Atoi(match[3])
+	}
+	return version, nil
+}
+
+// AtLeast returns true if version is greater or equal to major.minor.
+// An unknown (zero) version is considered as older than any release.
+func (v HAProxyVersion) AtLeast(major, minor int) bool {
+	return v.Major > major || (v.Major == major && v.Minor >= minor)
+}
+
+func (v HAProxyVersion) String() string {
+	if v.Major == 0 {
+		return "unknown"
+	}
+	return fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// Supports returns true if the version provides the given feature of haproxyFeatures.
+// An unknown (zero) version supports all features, an undetected binary is expected
+// to be at least the one shipped in the image.
+func (v HAProxyVersion) Supports(feature string) bool {
+	required, ok := haproxyFeatures[feature]
+	if !ok || v.Major == 0 {
+		return true
+	}
+	return v.AtLeast(required.Major, required.Minor)
+}
+
+// featureSupported returns false if the detected HAProxy version is older than
+// the one required by feature, a warning is logged the first time the feature is disabled.
+// Features are not disabled if the version could not be detected, see Supports.
+func (c *HAProxyController) featureSupported(feature string) bool {
+	if c.haproxyVersion.Supports(feature) {
+		return true
+	}
+	if c.disabledFeatures == nil {
+		c.disabledFeatures = map[string]struct{}{}
+	}
+	if _, ok := c.disabledFeatures[feature]; !ok {
+		c.disabledFeatures[feature] = struct{}{}
+		log.Printf("WARNING: %s requires HAProxy %d.%d or newer, running %s, feature is disabled\n",
+			feature, haproxyFeatures[feature].Major, haproxyFeatures[feature].Minor, c.haproxyVersion)
+	}
+	return false
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+)
+
+func TestParseHAProxyVersion(t *testing.T) {
+	tests := []struct {
+		info     string
+		expected HAProxyVersion
+		err      bool
+	}{
+		{"HA-Proxy version 2.0.11 2019/12/11 - https://haproxy.org/", HAProxyVersion{2, 0, 11}, false},
+		{"HAProxy version 2.6.0-a1583e4 2022/05/31 - https://haproxy.org/", HAProxyVersion{2, 6, 0}, false},
+		{"HA-Proxy version 1.8 2017/11/26", HAProxyVersion{1, 8, 0}, false},
+		{"haproxy: command not found", HAProxyVersion{}, true},
+	}
+	for _, test := range tests {
+		version, err := parseHAProxyVersion(test.info)
+		if (err != nil) != test.err {
+			t.Errorf("%s: expected error %t, got %v", test.info, test.err, err)
+		}
+		if version != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.info, test.expected, version)
+		}
+	}
+}
+
+func TestHAProxyVersionSupports(t *testing.T) {
+	tests := []struct {
+		version  HAProxyVersion
+		feature  string
+		expected bool
+	}{
+		{HAProxyVersion{2, 0, 11}, "prometheus-exporter", true},
+		{HAProxyVersion{1, 9, 4}, "prometheus-exporter", false},
+		{HAProxyVersion{1, 8, 0}, "seamless-reload", true},
+		{HAProxyVersion{1, 7, 12}, "seamless-reload", false},
+		{HAProxyVersion{2, 4, 0}, "hash-key", false},
+		{HAProxyVersion{3, 0, 0}, "hash-key", true},
+		{HAProxyVersion{2, 0, 0}, "unknown-feature", true},
+		// undetected version supports all features
+		{HAProxyVersion{}, "hash-key", true},
+		{HAProxyVersion{}, "set-ssl-cert", true},
+	}
+	for _, test := range tests {
+		if supported := test.version.Supports(test.feature); supported != test.expected {
+			t.Errorf("%s %s: expected %t, got %t", test.version, test.feature, test.expected, supported)
+		}
+	}
+}
+
+func TestFeatureSupported(t *testing.T) {
+	c := &HAProxyController{haproxyVersion: HAProxyVersion{1, 7, 12}}
+	for _, feature := range []string{"seamless-reload", "prometheus-exporter", "normalize-uri", "set-ssl-cert", "wait-for-body", "deny-status-any"} {
+		if c.featureSupported(feature) {
+			t.Errorf("%s: expected disabled on %s", feature, c.haproxyVersion)
+		}
+		if _, ok := c.disabledFeatures[feature]; !ok {
+			t.Errorf("%s: expected to be recorded as disabled", feature)
+		}
+	}
+	c = &HAProxyController{}
+	if !c.featureSupported("normalize-uri") || len(c.disabledFeatures) != 0 {
+		t.Error("expected features enabled with undetected version")
+	}
+}
+
+// TestOldVersionDisablesFeatures checks that directives of version gated features
+// are not generated with a simulated old HAProxy version.
+func TestOldVersionDisablesFeatures(t *testing.T) {
+	tests := []struct {
+		version HAProxyVersion
+		enabled bool
+	}{
+		{HAProxyVersion{2, 4, 0}, true},
+		{HAProxyVersion{}, true},
+		{HAProxyVersion{1, 7, 12}, false},
+	}
+	for _, test := range tests {
+		c, cleanup := newTestController(t)
+		c.haproxyVersion = test.version
+		c.cfg.ConfigMap.Annotations = MapStringW{"normalize-uri": &StringW{Value: "path-merge-slashes", Status: ADDED}}
+		config := c.testSync(t, func() {
+			if _, err := c.handleSeamlessReload(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handlePrometheusExporter(); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := c.handleNormalizeURI(); err != nil {
+				t.Fatal(err)
+			}
+		})
+		cleanup()
+		socket := "stats socket /var/run/haproxy-runtime-api.sock level admin"
+		if test.enabled {
+			socket += " expose-fd listeners"
+		}
+		if !testSectionHas(config, "global", socket) {
+			t.Errorf("%s: expected '%s':\n%s", test.version, socket, config)
+		}
+		exporter := testSectionHas(config, "frontend stats", "http-request use-service prometheus-exporter if { path /metrics }")
+		if exporter != test.enabled {
+			t.Errorf("%s: expected prometheus exporter %t:\n%s", test.version, test.enabled, config)
+		}
+		normalize := testSectionHas(config, "frontend http", "http-request normalize-uri path-merge-slashes")
+		if normalize != test.enabled {
+			t.Errorf("%s: expected normalize-uri %t:\n%s", test.version, test.enabled, config)
+		}
+	}
+}
+
+func TestHandleSeamlessReloadStable(t *testing.T) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testSync(t, func() {
+		if reload, _ := c.handleSeamlessReload(); reload {
+			t.Error("expected no reload")
+		}
+		if reload, _ := c.handlePrometheusExporter(); !reload {
+			t.Error("expected reload")
+		}
+	})
+	c.testSync(t, func() {
+		if reload, _ := c.handlePrometheusExporter(); reload {
+			t.Error("expected no reload")
+		}
+	})
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"log"
+	"strconv"
+
+	"github.com/haproxytech/kubernetes-ingress/controller/utils"
+)
+
+// defaultServerWeight is the weight of all servers of backends
+const defaultServerWeight = 128
+
+// handleRebalance normalizes weights of roundrobin backends whose servers were enabled or disabled
+// via runtime API after a scale event. Slots reused by new pods keep the runtime weight of their
+// previous server (e.g. set by an agent check or manually), enabled servers are set back to the
+// same weight so that new pods get an even share of requests.
+func (c *HAProxyController) handleRebalance() {
+	var runtimeState map[string]map[string]runtimeServer
+	for _, namespace := range c.cfg.Namespace {
+		for _, endpoints := range namespace.Endpoints {
+			if !endpoints.Rebalance {
+				continue
+			}
+			endpoints.Rebalance = false
+			if endpoints.BackendName == "" || !c.roundrobinWeights(namespace, endpoints) {
+				continue
+			}
+			if runtimeState == nil {
+				var err error
+				if runtimeState, err = c.runtimeServersState(); err != nil {
+					utils.LogErr(err)
+					return
+				}
+			}
+			servers := runtimeState[endpoints.BackendName]
+			for _, ip := range *endpoints.Addresses {
+				server, ok := servers[ip.HAProxyName]
+				if !ok || ip.Disabled || server.weight == defaultServerWeight {
+					continue
+				}
+				log.Printf("rebalance: server %s/%s weight %d set to %d", endpoints.BackendName, ip.HAProxyName, server.weight, defaultServerWeight)
+				utils.LogErr(c.NativeAPI.Runtime.SetServerWeight(endpoints.BackendName, ip.HAProxyName, strconv.Itoa(defaultServerWeight)))
+			}
+		}
+	}
+}
+
+// roundrobinWeights returns true if servers of the backend are weighted by the controller only,
+// backends with agent checks have weights set by their agent.
+func (c *HAProxyController) roundrobinWeights(namespace *Namespace, endpoints *Endpoints) bool {
+	backend, err := c.backendGet(endpoints.BackendName)
+	if err != nil || (backend.Balance != nil && backend.Balance.Algorithm != nil && *backend.Balance.Algorithm != "roundrobin") {
+		return false
+	}
+	service, ok := namespace.Services[endpoints.Service.Value]
+	if !ok {
+		return false
+	}
+	annAgent, _ := GetValueFromAnnotations("agent-check-port", service.Annotations, c.cfg.ConfigMap.Annotations)
+	return annAgent == nil || annAgent.Status == DELETED || annAgent.Value == ""
+}
@@ -0,0 +1,98 @@
+// Copyright 2019 HAProxy Technologies LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRebalance returns the "set weight" commands sent by handleRebalance after one endpoint is added
+// to the 2 endpoints of service app, runtime weight of servers is given by weights
+func testRebalance(t *testing.T, annotations map[string]string, weights func(ip *EndpointIP) int) (commands []string) {
+	c, cleanup := newTestController(t)
+	defer cleanup()
+	c.testService("default", "app", annotations)
+	ns := c.cfg.GetNamespace("default")
+	c.eventEndpoints(ns, testEndpoints(2))
+	ns.Endpoints["app"].BackendName = "default-app-80"
+	c.cfg.Clean()
+	endpoints := testEndpoints(3)
+	endpoints.Status = MODIFIED
+	// scale event: new pod takes over a disabled slot via runtime API
+	setup := newTestRuntime(t, func(command string) string { return "\n" })
+	c.NativeAPI.Runtime = setup.client(t)
+	c.eventEndpoints(ns, endpoints)
+	setup.close()
+	if !ns.Endpoints["app"].Rebalance {
+		t.Fatal("expected rebalance after scale")
+	}
+
+	state := "1\n# be_id be_name srv_id srv_name srv_addr srv_op_state srv_admin_state srv_uweight srv_iweight\n"
+	for _, ip := range *ns.Endpoints["app"].Addresses {
+		adminState := 0
+		if ip.Disabled {
+			adminState = 1
+		}
+		state += fmt.Sprintf("3 default-app-80 1 %s %s 2 %d %d 1\n", ip.HAProxyName, ip.IP, adminState, weights(ip))
+	}
+	runtime := newTestRuntime(t, func(command string) string {
+		if command == "show servers state" {
+			return state
+		}
+		return "\n"
+	})
+	defer runtime.close()
+	c.NativeAPI.Runtime = runtime.client(t)
+	c.handleRebalance()
+	for _, command := range runtime.received() {
+		if strings.HasPrefix(command, "set server") && strings.Contains(command, " weight ") {
+			commands = append(commands, command)
+		}
+	}
+	sort.Strings(commands)
+	if ns.Endpoints["app"].Rebalance {
+		t.Error("expected rebalance to be done")
+	}
+	return commands
+}
+
+func TestHandleRebalance(t *testing.T) {
+	var reused string
+	commands := testRebalance(t, nil, func(ip *EndpointIP) int {
+		switch {
+		case ip.IP == "10.0.0.3":
+			// weight of the previous server of the slot
+			reused = ip.HAProxyName
+			return 10
+		case ip.Disabled:
+			return 1
+		}
+		return defaultServerWeight
+	})
+	expected := "set server default-app-80/" + reused + " weight 128"
+	if strings.Join(commands, "\n") != expected {
+		t.Errorf("expected '%s', got %v", expected, commands)
+	}
+}
+
+func TestHandleRebalanceAgentCheck(t *testing.T) {
+	commands := testRebalance(t, map[string]string{"agent-check-port": "9999"}, func(ip *EndpointIP) int { return 10 })
+	if len(commands) != 0 {
+		t.Errorf("expected weights set by agent to be kept, got %v", commands)
+	}
+}
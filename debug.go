@@ -36,9 +36,12 @@ func setupTestEnv() {
 	utils.LogErr(err)
 	time.Sleep(2 * time.Second)
 	c.HAProxyCFG = path.Join(TestFolderPath, c.HAProxyCFG)
+	c.HAProxyIncludeCFG = path.Join(TestFolderPath, c.HAProxyIncludeCFG)
 	c.HAProxyCertDir = path.Join(TestFolderPath, c.HAProxyCertDir)
 	c.HAProxyStateDir = path.Join(TestFolderPath, c.HAProxyStateDir)
 	c.HAProxyCaptureDir = path.Join(TestFolderPath, c.HAProxyCaptureDir)
+	c.HAProxyErrorsDir = path.Join(TestFolderPath, c.HAProxyErrorsDir)
+	c.HAProxyJWTDir = path.Join(TestFolderPath, c.HAProxyJWTDir)
 	cmd := exec.Command("pwd")
 	out, err := cmd.CombinedOutput()
 	if err != nil {
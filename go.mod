@@ -3,6 +3,7 @@ module github.com/haproxytech/kubernetes-ingress
 go 1.12
 
 require (
+	github.com/go-openapi/strfmt v0.19.0
 	github.com/haproxytech/client-native v1.2.7-0.20200123135444-6616fd84ebc1
 	github.com/haproxytech/config-parser/v2 v2.0.0-dev6
 	github.com/haproxytech/models v1.2.5-0.20191219083202-da92d6657b87
@@ -29,9 +29,12 @@ import (
 func main() {
 
 	c.HAProxyCFG = "/etc/haproxy/haproxy.cfg"
+	c.HAProxyIncludeCFG = "/etc/haproxy/include.cfg"
 	c.HAProxyCertDir = "/etc/haproxy/certs/"
 	c.HAProxyStateDir = "/var/state/haproxy/"
 	c.HAProxyCaptureDir = "/etc/haproxy/capture/"
+	c.HAProxyErrorsDir = "/etc/haproxy/errors/"
+	c.HAProxyJWTDir = "/etc/haproxy/jwt/"
 
 	var osArgs utils.OSArgs
 	var parser = flags.NewParser(&osArgs, flags.IgnoreUnknown)
@@ -66,7 +69,7 @@ func main() {
 		return
 	}
 
-	log.Println(IngressControllerInfo)
+	log.Print(IngressControllerInfo)
 	log.Printf("HAProxy Ingress Controller %s %s%s\n\n", GitTag, GitCommit, GitDirty)
 	log.Printf("Build from: %s\n", GitRepo)
 	log.Printf("Build date: %s\n\n", BuildTime)